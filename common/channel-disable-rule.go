@@ -0,0 +1,176 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// 渠道错误规则的处理动作
+const (
+	ChannelRuleActionDisable  = "disable"  // 禁用渠道（需开启自动禁用且渠道允许自动禁用）
+	ChannelRuleActionPenalize = "penalize" // 仅降低渠道权重，不禁用
+	ChannelRuleActionIgnore   = "ignore"   // 不禁用也不降权，用于用户请求本身有误等与渠道无关的错误
+)
+
+// ChannelDisableRule 渠道错误规则，非空的条件之间为“且”，同一条件的多个取值之间为“或”，
+// 规则按顺序匹配，命中第一条后停止；均未命中时按 penalize 处理
+type ChannelDisableRule struct {
+	Name         string   `json:"name,omitempty"`
+	ChannelTypes []int    `json:"channel_types,omitempty"`
+	StatusCodes  []int    `json:"status_codes,omitempty"`
+	ErrorTypes   []string `json:"error_types,omitempty"`
+	ErrorCodes   []string `json:"error_codes,omitempty"`
+	MessageRegex string   `json:"message_regex,omitempty"` // RE2 语法
+	Action       string   `json:"action"`
+
+	messageRegexp *regexp.Regexp
+}
+
+// ChannelErrorInfo 用于匹配规则的上游错误信息
+type ChannelErrorInfo struct {
+	ChannelType int
+	StatusCode  int
+	ErrorType   string
+	ErrorCode   string
+	Message     string
+}
+
+// 默认规则与之前硬编码的判断一致
+var defaultChannelDisableRules = []ChannelDisableRule{
+	{Name: "unauthorized", StatusCodes: []int{401}, Action: ChannelRuleActionDisable},
+	{Name: "gemini forbidden", ChannelTypes: []int{ChannelTypeGemini}, StatusCodes: []int{403}, Action: ChannelRuleActionDisable},
+	{Name: "invalid account", ErrorCodes: []string{"invalid_api_key", "account_deactivated", "billing_not_active"}, Action: ChannelRuleActionDisable},
+	// https://docs.anthropic.com/claude/reference/errors
+	{Name: "insufficient quota or permission", ErrorTypes: []string{"insufficient_quota", "insufficient_user_quota", "authentication_error", "permission_error", "forbidden"}, Action: ChannelRuleActionDisable},
+	{Name: "balance or organization message", MessageRegex: `^(Your credit balance is too low|This organization has been disabled\.|You exceeded your current quota|Permission denied)`, Action: ChannelRuleActionDisable},
+	{Name: "invalid credential message", MessageRegex: `The security token included in the request is invalid|Operation not allowed|Your account is not authorized`, Action: ChannelRuleActionDisable},
+}
+
+var channelDisableRules = mustCompileChannelDisableRules(defaultChannelDisableRules)
+var channelDisableRulesLock sync.RWMutex
+
+func mustCompileChannelDisableRules(rules []ChannelDisableRule) []ChannelDisableRule {
+	compiled, err := compileChannelDisableRules(rules)
+	if err != nil {
+		panic(err)
+	}
+	return compiled
+}
+
+func compileChannelDisableRules(rules []ChannelDisableRule) ([]ChannelDisableRule, error) {
+	compiled := make([]ChannelDisableRule, len(rules))
+	for i, rule := range rules {
+		switch rule.Action {
+		case ChannelRuleActionDisable, ChannelRuleActionPenalize, ChannelRuleActionIgnore:
+		default:
+			return nil, fmt.Errorf("rule %d: invalid action %q", i+1, rule.Action)
+		}
+		if len(rule.ChannelTypes) == 0 && len(rule.StatusCodes) == 0 && len(rule.ErrorTypes) == 0 &&
+			len(rule.ErrorCodes) == 0 && rule.MessageRegex == "" {
+			return nil, fmt.Errorf("rule %d: at least one condition is required", i+1)
+		}
+		if rule.MessageRegex != "" {
+			re, err := regexp.Compile(rule.MessageRegex)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: invalid message regex: %s", i+1, err.Error())
+			}
+			rule.messageRegexp = re
+		}
+		compiled[i] = rule
+	}
+	return compiled, nil
+}
+
+// ParseChannelDisableRules 解析并校验 JSON 格式的规则列表
+func ParseChannelDisableRules(jsonStr string) ([]ChannelDisableRule, error) {
+	var rules []ChannelDisableRule
+	if err := json.Unmarshal([]byte(jsonStr), &rules); err != nil {
+		return nil, err
+	}
+	return compileChannelDisableRules(rules)
+}
+
+func ChannelDisableRules2JSONString() string {
+	channelDisableRulesLock.RLock()
+	defer channelDisableRulesLock.RUnlock()
+	jsonBytes, err := json.Marshal(channelDisableRules)
+	if err != nil {
+		SysError("error marshalling channel disable rules: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateChannelDisableRulesByJSONString(jsonStr string) error {
+	rules, err := ParseChannelDisableRules(jsonStr)
+	if err != nil {
+		return err
+	}
+	channelDisableRulesLock.Lock()
+	channelDisableRules = rules
+	channelDisableRulesLock.Unlock()
+	return nil
+}
+
+func CheckChannelDisableRules(jsonStr string) error {
+	_, err := ParseChannelDisableRules(jsonStr)
+	if err != nil {
+		return errors.New("invalid channel disable rules: " + err.Error())
+	}
+	return nil
+}
+
+// GetChannelDisableRules 获取当前生效的规则
+func GetChannelDisableRules() []ChannelDisableRule {
+	channelDisableRulesLock.RLock()
+	defer channelDisableRulesLock.RUnlock()
+	return channelDisableRules
+}
+
+func (rule *ChannelDisableRule) Match(info *ChannelErrorInfo) bool {
+	if len(rule.ChannelTypes) > 0 && !containsValue(rule.ChannelTypes, info.ChannelType) {
+		return false
+	}
+	if len(rule.StatusCodes) > 0 && !containsValue(rule.StatusCodes, info.StatusCode) {
+		return false
+	}
+	if len(rule.ErrorTypes) > 0 && !containsValue(rule.ErrorTypes, info.ErrorType) {
+		return false
+	}
+	if len(rule.ErrorCodes) > 0 && !containsValue(rule.ErrorCodes, info.ErrorCode) {
+		return false
+	}
+	if rule.messageRegexp != nil && !rule.messageRegexp.MatchString(info.Message) {
+		return false
+	}
+	return true
+}
+
+// MatchChannelDisableRule 返回第一条命中的规则的下标，未命中时返回 -1
+func MatchChannelDisableRule(rules []ChannelDisableRule, info *ChannelErrorInfo) int {
+	for i := range rules {
+		if rules[i].Match(info) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ChannelErrorAction 按规则判断错误的处理动作，未命中时为 penalize
+func ChannelErrorAction(rules []ChannelDisableRule, info *ChannelErrorInfo) string {
+	if i := MatchChannelDisableRule(rules, info); i >= 0 {
+		return rules[i].Action
+	}
+	return ChannelRuleActionPenalize
+}
+
+func containsValue[T comparable](values []T, value T) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
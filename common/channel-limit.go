@@ -0,0 +1,71 @@
+package common
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 渠道进行中的请求在 Redis 中以 sorted set 记录，成员为请求标识，分数为开始时间，
+// 超过该时长仍未释放的记录视为节点异常退出遗留，不再计入并发
+const channelInFlightStaleDuration = 30 * time.Minute
+
+const channelInFlightKeyPrefix = "channel_inflight:"
+
+var channelInFlightLock sync.Mutex
+var channelInFlight = make(map[int]int)
+
+var channelAcquireScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - tonumber(ARGV[2]))
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], now, ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+func channelInFlightKey(channelId int) string {
+	return channelInFlightKeyPrefix + strconv.Itoa(channelId)
+}
+
+// AcquireChannelConcurrency 进行中的请求数未达到 max 时占用一个名额，member 用于释放时标识本次请求
+func AcquireChannelConcurrency(channelId int, max int, member string) bool {
+	if RedisEnabled && RDB != nil {
+		ok, err := channelAcquireScript.Run(context.Background(), RDB, []string{channelInFlightKey(channelId)},
+			time.Now().UnixMilli(), channelInFlightStaleDuration.Milliseconds(), max, member).Int()
+		if err != nil {
+			// Redis 异常时不阻塞请求
+			SysError("failed to acquire channel concurrency: " + err.Error())
+			return true
+		}
+		return ok == 1
+	}
+	channelInFlightLock.Lock()
+	defer channelInFlightLock.Unlock()
+	if channelInFlight[channelId] >= max {
+		return false
+	}
+	channelInFlight[channelId]++
+	return true
+}
+
+func ReleaseChannelConcurrency(channelId int, member string) {
+	if RedisEnabled && RDB != nil {
+		if err := RDB.ZRem(context.Background(), channelInFlightKey(channelId), member).Err(); err != nil {
+			SysError("failed to release channel concurrency: " + err.Error())
+		}
+		return
+	}
+	channelInFlightLock.Lock()
+	defer channelInFlightLock.Unlock()
+	if channelInFlight[channelId] <= 1 {
+		delete(channelInFlight, channelId)
+		return
+	}
+	channelInFlight[channelId]--
+}
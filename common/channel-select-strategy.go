@@ -0,0 +1,141 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	ChannelSelectStrategyWeighted      = "weighted"       // 按权重随机（默认）
+	ChannelSelectStrategyLatency       = "latency"        // 首字延迟最低
+	ChannelSelectStrategyLeastInFlight = "least_inflight" // 进行中请求最少
+	ChannelSelectStrategyCheapest      = "cheapest"       // 渠道成本倍率最低
+)
+
+// ChannelSelectStrategy 各分组在同一优先级内选择渠道的策略，未配置的分组使用 weighted
+var ChannelSelectStrategy = map[string]string{}
+var channelSelectStrategyLock sync.RWMutex
+
+func ChannelSelectStrategy2JSONString() string {
+	channelSelectStrategyLock.RLock()
+	defer channelSelectStrategyLock.RUnlock()
+	jsonBytes, err := json.Marshal(ChannelSelectStrategy)
+	if err != nil {
+		SysError("error marshalling channel select strategy: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateChannelSelectStrategyByJSONString(jsonStr string) error {
+	strategy := make(map[string]string)
+	if err := json.Unmarshal([]byte(jsonStr), &strategy); err != nil {
+		return err
+	}
+	channelSelectStrategyLock.Lock()
+	ChannelSelectStrategy = strategy
+	channelSelectStrategyLock.Unlock()
+	return nil
+}
+
+func GetChannelSelectStrategy(group string) string {
+	channelSelectStrategyLock.RLock()
+	defer channelSelectStrategyLock.RUnlock()
+	strategy, ok := ChannelSelectStrategy[group]
+	if !ok || strategy == "" {
+		return ChannelSelectStrategyWeighted
+	}
+	return strategy
+}
+
+func CheckChannelSelectStrategy(jsonStr string) error {
+	strategy := make(map[string]string)
+	if err := json.Unmarshal([]byte(jsonStr), &strategy); err != nil {
+		return err
+	}
+	for group, name := range strategy {
+		switch name {
+		case ChannelSelectStrategyWeighted, ChannelSelectStrategyLatency,
+			ChannelSelectStrategyLeastInFlight, ChannelSelectStrategyCheapest:
+		default:
+			return errors.New("unknown channel select strategy for group " + group + ": " + name)
+		}
+	}
+	return nil
+}
+
+// 延迟使用指数加权移动平均，新样本的权重
+const channelLatencyEWMAAlpha = 0.3
+
+// 上游请求失败时按该延迟计入样本，避免总是失败的渠道因没有样本而一直被选中
+const channelLatencyFailurePenalty = 10 * time.Second
+
+type channelStatKey struct {
+	channelId int
+	model     string
+}
+
+// ChannelStatsTracker 记录本节点各渠道+模型的首字延迟和进行中的请求数，供选择策略使用
+type ChannelStatsTracker struct {
+	latency  map[channelStatKey]float64 // in milliseconds
+	inFlight map[int]int
+	mutex    sync.RWMutex
+}
+
+var ChannelStats = &ChannelStatsTracker{
+	latency:  make(map[channelStatKey]float64),
+	inFlight: make(map[int]int),
+}
+
+func (t *ChannelStatsTracker) RecordLatency(channelId int, model string, latency time.Duration) {
+	if latency <= 0 {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	key := channelStatKey{channelId, model}
+	ms := float64(latency.Milliseconds())
+	if old, ok := t.latency[key]; ok {
+		ms = old*(1-channelLatencyEWMAAlpha) + ms*channelLatencyEWMAAlpha
+	}
+	t.latency[key] = ms
+}
+
+// RecordFailure 记录一次上游失败，按 elapsed 和 channelLatencyFailurePenalty 中较大的值计入延迟
+func (t *ChannelStatsTracker) RecordFailure(channelId int, model string, elapsed time.Duration) {
+	if elapsed < channelLatencyFailurePenalty {
+		elapsed = channelLatencyFailurePenalty
+	}
+	t.RecordLatency(channelId, model, elapsed)
+}
+
+// GetLatency 获取平均首字延迟，ok 为 false 表示还没有样本
+func (t *ChannelStatsTracker) GetLatency(channelId int, model string) (latency float64, ok bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	latency, ok = t.latency[channelStatKey{channelId, model}]
+	return latency, ok
+}
+
+func (t *ChannelStatsTracker) IncInFlight(channelId int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.inFlight[channelId]++
+}
+
+func (t *ChannelStatsTracker) DecInFlight(channelId int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.inFlight[channelId] <= 1 {
+		delete(t.inFlight, channelId)
+		return
+	}
+	t.inFlight[channelId]--
+}
+
+func (t *ChannelStatsTracker) GetInFlight(channelId int) int {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.inFlight[channelId]
+}
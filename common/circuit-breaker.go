@@ -0,0 +1,230 @@
+package common
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	CircuitStateClosed   = "closed"
+	CircuitStateOpen     = "open"
+	CircuitStateHalfOpen = "half_open"
+)
+
+// CircuitBreaker 单个渠道+模型的熔断状态
+type CircuitBreaker struct {
+	ChannelId           int    `json:"channel_id"`
+	Model               string `json:"model"`
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	LastFailureAt       int64  `json:"last_failure_at"`
+	// 熔断开始时间，半开状态下为进入半开的时间
+	OpenedAt int64 `json:"opened_at"`
+	// 半开状态下正在进行和已成功的探测请求数
+	ProbesInFlight int `json:"probes_in_flight"`
+	ProbeSuccesses int `json:"probe_successes"`
+}
+
+type circuitBreakerKey struct {
+	channelId int
+	model     string
+}
+
+// CircuitBreakerManager 按渠道+模型维护熔断器
+//
+// closed: 正常放行，连续失败达到阈值后进入 open
+// open: 选择渠道时完全跳过，冷却时间结束后进入 half_open
+// half_open: 最多同时放行 CircuitBreakerHalfOpenProbes 个探测请求，全部成功后恢复 closed，任一失败重新 open
+type CircuitBreakerManager struct {
+	breakers map[circuitBreakerKey]*CircuitBreaker
+	mutex    sync.Mutex
+}
+
+var ChannelBreakers = NewCircuitBreakerManager()
+
+func NewCircuitBreakerManager() *CircuitBreakerManager {
+	return &CircuitBreakerManager{
+		breakers: make(map[circuitBreakerKey]*CircuitBreaker),
+	}
+}
+
+func circuitBreakerEnabled() bool {
+	return CircuitBreakerFailureThreshold > 0
+}
+
+// refresh 冷却结束的 open 熔断器进入 half_open；探测请求长时间没有结果时重新开放探测名额
+func (m *CircuitBreakerManager) refresh(breaker *CircuitBreaker, now int64) {
+	if now-breaker.OpenedAt < int64(CircuitBreakerCooldown) {
+		return
+	}
+	switch breaker.State {
+	case CircuitStateOpen:
+		breaker.State = CircuitStateHalfOpen
+		breaker.OpenedAt = now
+		breaker.ProbesInFlight = 0
+		breaker.ProbeSuccesses = 0
+		SysLog(fmt.Sprintf("circuit breaker half open: channelId=%d, model=%s", breaker.ChannelId, breaker.Model))
+	case CircuitStateHalfOpen:
+		breaker.OpenedAt = now
+		breaker.ProbesInFlight = 0
+	}
+}
+
+// Allow 判断渠道+模型当前是否可以被选择，只读判断，不占用探测名额
+func (m *CircuitBreakerManager) Allow(channelId int, model string) bool {
+	if !circuitBreakerEnabled() {
+		return true
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	breaker, ok := m.breakers[circuitBreakerKey{channelId, model}]
+	if !ok {
+		return true
+	}
+	m.refresh(breaker, GetTimestamp())
+	switch breaker.State {
+	case CircuitStateOpen:
+		return false
+	case CircuitStateHalfOpen:
+		return breaker.ProbesInFlight < CircuitBreakerHalfOpenProbes
+	}
+	return true
+}
+
+// OnSelected 渠道被选中时调用，半开状态下占用一个探测名额
+func (m *CircuitBreakerManager) OnSelected(channelId int, model string) {
+	if !circuitBreakerEnabled() {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	breaker, ok := m.breakers[circuitBreakerKey{channelId, model}]
+	if ok && breaker.State == CircuitStateHalfOpen {
+		breaker.ProbesInFlight++
+	}
+}
+
+// RecordSuccess 记录一次上游成功
+func (m *CircuitBreakerManager) RecordSuccess(channelId int, model string) {
+	if !circuitBreakerEnabled() {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	key := circuitBreakerKey{channelId, model}
+	breaker, ok := m.breakers[key]
+	if !ok {
+		return
+	}
+	switch breaker.State {
+	case CircuitStateHalfOpen:
+		if breaker.ProbesInFlight > 0 {
+			breaker.ProbesInFlight--
+		}
+		breaker.ProbeSuccesses++
+		if breaker.ProbeSuccesses >= CircuitBreakerHalfOpenProbes {
+			delete(m.breakers, key)
+			SysLog(fmt.Sprintf("circuit breaker closed: channelId=%d, model=%s", channelId, model))
+		}
+	case CircuitStateClosed:
+		delete(m.breakers, key)
+	}
+}
+
+// RecordFailure 记录一次上游失败
+func (m *CircuitBreakerManager) RecordFailure(channelId int, model string) {
+	if !circuitBreakerEnabled() {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	key := circuitBreakerKey{channelId, model}
+	breaker, ok := m.breakers[key]
+	if !ok {
+		breaker = &CircuitBreaker{
+			ChannelId: channelId,
+			Model:     model,
+			State:     CircuitStateClosed,
+		}
+		m.breakers[key] = breaker
+	}
+	now := GetTimestamp()
+	breaker.ConsecutiveFailures++
+	breaker.LastFailureAt = now
+	switch breaker.State {
+	case CircuitStateClosed:
+		if breaker.ConsecutiveFailures >= CircuitBreakerFailureThreshold {
+			breaker.State = CircuitStateOpen
+			breaker.OpenedAt = now
+			SysLog(fmt.Sprintf("circuit breaker open: channelId=%d, model=%s, failures=%d", channelId, model, breaker.ConsecutiveFailures))
+		}
+	case CircuitStateHalfOpen:
+		breaker.State = CircuitStateOpen
+		breaker.OpenedAt = now
+		breaker.ProbesInFlight = 0
+		breaker.ProbeSuccesses = 0
+		SysLog(fmt.Sprintf("circuit breaker reopen: channelId=%d, model=%s", channelId, model))
+	}
+}
+
+// Release 请求因本地原因结束（未得到上游结果）时释放占用的探测名额
+func (m *CircuitBreakerManager) Release(channelId int, model string) {
+	if !circuitBreakerEnabled() {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	breaker, ok := m.breakers[circuitBreakerKey{channelId, model}]
+	if ok && breaker.State == CircuitStateHalfOpen && breaker.ProbesInFlight > 0 {
+		breaker.ProbesInFlight--
+	}
+}
+
+// Reset 清除渠道所有模型的熔断状态
+func (m *CircuitBreakerManager) Reset(channelId int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for key := range m.breakers {
+		if key.channelId == channelId {
+			delete(m.breakers, key)
+		}
+	}
+}
+
+// GetStates 获取熔断器状态，channelId 为 0 时返回所有渠道
+func (m *CircuitBreakerManager) GetStates(channelId int) []CircuitBreaker {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := GetTimestamp()
+	states := make([]CircuitBreaker, 0)
+	for key, breaker := range m.breakers {
+		if channelId != 0 && key.channelId != channelId {
+			continue
+		}
+		m.refresh(breaker, now)
+		states = append(states, *breaker)
+	}
+	return states
+}
+
+func init() {
+	// 定期清理已恢复但没有再收到请求的记录，避免长期占用内存
+	ticker := time.NewTicker(10 * time.Minute)
+	go func() {
+		for range ticker.C {
+			ChannelBreakers.cleanup()
+		}
+	}()
+}
+
+func (m *CircuitBreakerManager) cleanup() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := GetTimestamp()
+	for key, breaker := range m.breakers {
+		if breaker.State == CircuitStateClosed && now-breaker.LastFailureAt > 600 {
+			delete(m.breakers, key)
+		}
+	}
+}
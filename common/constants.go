@@ -30,7 +30,7 @@ var DefaultCollapseSidebar = false // default value of collapse sidebar
 // Any options with "Secret", "Token" in its key won't be return by GetOptions
 
 var SessionSecret = uuid.New().String()
-var CryptoSecret = uuid.New().String()
+var CryptoSecret = "" // 由 CRYPTO_SECRET 或 SESSION_SECRET 设置，为空时不能使用审计日志和用户 webhook 签名密钥
 
 var OptionMap map[string]string
 var OptionMapRWMutex sync.RWMutex
@@ -115,6 +115,11 @@ var BatchUpdateInterval = GetEnvOrDefault("BATCH_UPDATE_INTERVAL", 5)
 
 var RelayTimeout = GetEnvOrDefault("RELAY_TIMEOUT", 0) // unit is second
 
+// 熔断：同一渠道同一模型连续失败达到阈值后熔断，冷却结束后放行少量探测请求，阈值为 0 时不启用
+var CircuitBreakerFailureThreshold = GetEnvOrDefault("CIRCUIT_BREAKER_FAILURE_THRESHOLD", 5)
+var CircuitBreakerCooldown = GetEnvOrDefault("CIRCUIT_BREAKER_COOLDOWN", 60) // unit is second
+var CircuitBreakerHalfOpenProbes = GetEnvOrDefault("CIRCUIT_BREAKER_HALF_OPEN_PROBES", 1)
+
 var GeminiSafetySetting = GetEnvOrDefaultString("GEMINI_SAFETY_SETTING", "BLOCK_NONE")
 
 // https://docs.cohere.com/docs/safety-modes Type; NONE/CONTEXTUAL/STRICT
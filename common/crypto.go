@@ -1,6 +1,15 @@
 package common
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 func Password2Hash(password string) (string, error) {
 	passwordBytes := []byte(password)
@@ -12,3 +21,59 @@ func ValidatePasswordAndHash(password string, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+var ErrCryptoSecretNotSet = errors.New("未设置 CRYPTO_SECRET 或 SESSION_SECRET，无法加密保存数据")
+
+// CryptoSecretConfigured 是否配置了固定的加密密钥
+func CryptoSecretConfigured() bool {
+	return CryptoSecret != ""
+}
+
+// cryptoKey 由 CryptoSecret 派生的 AES-256 密钥
+func cryptoKey() []byte {
+	key := sha256.Sum256([]byte(CryptoSecret))
+	return key[:]
+}
+
+// EncryptWithSecret 使用 CryptoSecret 进行 AES-GCM 加密，返回 base64 编码的 nonce+密文
+func EncryptWithSecret(data []byte) (string, error) {
+	if !CryptoSecretConfigured() {
+		return "", ErrCryptoSecretNotSet
+	}
+	block, err := aes.NewCipher(cryptoKey())
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, data, nil)), nil
+}
+
+// DecryptWithSecret 解密 EncryptWithSecret 的结果，CryptoSecret 变更后无法解密
+func DecryptWithSecret(encrypted string) ([]byte, error) {
+	if !CryptoSecretConfigured() {
+		return nil, ErrCryptoSecretNotSet
+	}
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cryptoKey())
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
@@ -0,0 +1,107 @@
+package common
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// 运维事件类型
+const (
+	EventChannelDisabled    = "channel.disabled"
+	EventChannelEnabled     = "channel.enabled"
+	EventChannelBalanceLow  = "channel.balance_low"
+	EventUserQuotaLow       = "user.quota_low"
+	EventUserQuotaExhausted = "user.quota_exhausted"
+	EventTopUpCompleted     = "topup.completed"
+	EventTaskFailed         = "task.failed"
+	EventTokenExhausted     = "token.exhausted"
+)
+
+// EventTypes 所有事件类型，渠道相关事件只推送到全局 webhook
+var EventTypes = []string{
+	EventChannelDisabled,
+	EventChannelEnabled,
+	EventChannelBalanceLow,
+	EventUserQuotaLow,
+	EventUserQuotaExhausted,
+	EventTopUpCompleted,
+	EventTaskFailed,
+	EventTokenExhausted,
+}
+
+// UserEventTypes 用户可以订阅的事件类型
+var UserEventTypes = []string{
+	EventUserQuotaLow,
+	EventUserQuotaExhausted,
+	EventTopUpCompleted,
+	EventTaskFailed,
+	EventTokenExhausted,
+}
+
+func IsUserEventType(eventType string) bool {
+	for _, t := range UserEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func IsEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type Event struct {
+	Id        string         `json:"id"`
+	Type      string         `json:"type"`
+	UserId    int            `json:"user_id,omitempty"` // 事件所属用户，系统事件为 0
+	CreatedAt int64          `json:"created_at"`
+	Data      map[string]any `json:"data"`
+}
+
+type EventHandler func(event *Event)
+
+var eventHandlers []EventHandler
+var eventHandlersLock sync.RWMutex
+
+// SubscribeEvent 注册事件处理函数，所有事件都会异步分发给每个处理函数
+func SubscribeEvent(handler EventHandler) {
+	eventHandlersLock.Lock()
+	defer eventHandlersLock.Unlock()
+	eventHandlers = append(eventHandlers, handler)
+}
+
+// PublishEvent 发布事件，不会阻塞调用方
+func PublishEvent(eventType string, userId int, data map[string]any) {
+	eventHandlersLock.RLock()
+	handlers := eventHandlers
+	eventHandlersLock.RUnlock()
+	if len(handlers) == 0 {
+		return
+	}
+	event := &Event{
+		Id:        GetUUID(),
+		Type:      eventType,
+		UserId:    userId,
+		CreatedAt: GetTimestamp(),
+		Data:      data,
+	}
+	for _, handler := range handlers {
+		handler := handler
+		gopool.Go(func() {
+			defer func() {
+				if r := recover(); r != nil {
+					SysError(fmt.Sprintf("event handler panic: %v", r))
+				}
+			}()
+			handler(event)
+		})
+	}
+}
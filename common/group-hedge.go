@@ -0,0 +1,53 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// GroupHedgeDelay 启用对冲请求的分组及其等待首字的时间（毫秒），超过该时间仍未收到响应时向第二个渠道发送相同请求
+var GroupHedgeDelay = map[string]int{}
+var groupHedgeDelayLock sync.RWMutex
+
+func GroupHedgeDelay2JSONString() string {
+	groupHedgeDelayLock.RLock()
+	defer groupHedgeDelayLock.RUnlock()
+	jsonBytes, err := json.Marshal(GroupHedgeDelay)
+	if err != nil {
+		SysError("error marshalling group hedge delay: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateGroupHedgeDelayByJSONString(jsonStr string) error {
+	delays := make(map[string]int)
+	if err := json.Unmarshal([]byte(jsonStr), &delays); err != nil {
+		return err
+	}
+	groupHedgeDelayLock.Lock()
+	GroupHedgeDelay = delays
+	groupHedgeDelayLock.Unlock()
+	return nil
+}
+
+// GetGroupHedgeDelay 获取分组的对冲等待时间，未启用时返回 0
+func GetGroupHedgeDelay(group string) time.Duration {
+	groupHedgeDelayLock.RLock()
+	defer groupHedgeDelayLock.RUnlock()
+	return time.Duration(GroupHedgeDelay[group]) * time.Millisecond
+}
+
+func CheckGroupHedgeDelay(jsonStr string) error {
+	delays := make(map[string]int)
+	if err := json.Unmarshal([]byte(jsonStr), &delays); err != nil {
+		return err
+	}
+	for name, delay := range delays {
+		if delay < 0 {
+			return errors.New("group hedge delay must be not less than 0: " + name)
+		}
+	}
+	return nil
+}
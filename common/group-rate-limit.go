@@ -0,0 +1,56 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// GroupRateLimitConfig 分组内每个用户的每分钟请求数和 token 数上限，0 表示不限制
+type GroupRateLimitConfig struct {
+	RPM int `json:"rpm"`
+	TPM int `json:"tpm"`
+}
+
+var GroupRateLimit = map[string]GroupRateLimitConfig{}
+var groupRateLimitLock sync.RWMutex
+
+func GroupRateLimit2JSONString() string {
+	groupRateLimitLock.RLock()
+	defer groupRateLimitLock.RUnlock()
+	jsonBytes, err := json.Marshal(GroupRateLimit)
+	if err != nil {
+		SysError("error marshalling group rate limit: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateGroupRateLimitByJSONString(jsonStr string) error {
+	limits := make(map[string]GroupRateLimitConfig)
+	if err := json.Unmarshal([]byte(jsonStr), &limits); err != nil {
+		return err
+	}
+	groupRateLimitLock.Lock()
+	GroupRateLimit = limits
+	groupRateLimitLock.Unlock()
+	return nil
+}
+
+func GetGroupRateLimit(group string) GroupRateLimitConfig {
+	groupRateLimitLock.RLock()
+	defer groupRateLimitLock.RUnlock()
+	return GroupRateLimit[group]
+}
+
+func CheckGroupRateLimit(jsonStr string) error {
+	limits := make(map[string]GroupRateLimitConfig)
+	if err := json.Unmarshal([]byte(jsonStr), &limits); err != nil {
+		return err
+	}
+	for name, limit := range limits {
+		if limit.RPM < 0 || limit.TPM < 0 {
+			return errors.New("group rate limit must be not less than 0: " + name)
+		}
+	}
+	return nil
+}
@@ -45,6 +45,16 @@ func init() {
 			SessionSecret = ss
 		}
 	}
+	// 用于加密审计日志等数据，未设置时使用 SESSION_SECRET；两者都未设置时不使用随机密钥，
+	// 否则重启后或其他节点无法解密已加密的数据，此时审计日志和用户 webhook 签名密钥不可用
+	if os.Getenv("CRYPTO_SECRET") != "" {
+		CryptoSecret = os.Getenv("CRYPTO_SECRET")
+	} else if os.Getenv("SESSION_SECRET") != "" {
+		CryptoSecret = SessionSecret
+	} else {
+		log.Println("WARNING: neither CRYPTO_SECRET nor SESSION_SECRET is set, audit logging and user webhook secrets are disabled.")
+		log.Println("警告：CRYPTO_SECRET 和 SESSION_SECRET 均未设置，审计日志和用户 webhook 签名密钥不可用。")
+	}
 	if os.Getenv("SQLITE_PATH") != "" {
 		SQLitePath = os.Getenv("SQLITE_PATH")
 	}
@@ -5,55 +5,120 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/natefinch/lumberjack.v2"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"sync"
+	"strings"
 	"time"
 )
 
 const (
+	loggerDebug = "DEBUG"
 	loggerINFO  = "INFO"
 	loggerWarn  = "WARN"
 	loggerError = "ERR"
 )
 
-const maxLogCount = 1000000
-
-var logCount int
-var setupLogLock sync.Mutex
-var setupLogWorking bool
+var logLevel = new(slog.LevelVar)
+var logJSONEnabled bool
+var jsonLogger *slog.Logger
+var jsonErrorLogger *slog.Logger
 
+// SetupLogger 读取日志配置：
+// LOG_LEVEL 日志级别 debug/info/warn/error，默认 info；
+// LOG_FORMAT 为 json 时每行输出一个 JSON 对象，默认为文本格式；
+// 指定日志目录时写入 oneapi.log，超过 LOG_MAX_SIZE（MB，默认 100）时轮转，
+// 保留 LOG_MAX_AGE 天（默认 7，0 为不限）内最多 LOG_MAX_BACKUPS 个（默认 0 为不限）旧文件，LOG_COMPRESS 为 true 时压缩旧文件
 func SetupLogger() {
+	logLevel.Set(parseLogLevel(os.Getenv("LOG_LEVEL")))
+	logJSONEnabled = strings.ToLower(os.Getenv("LOG_FORMAT")) == "json"
 	if *LogDir != "" {
-		ok := setupLogLock.TryLock()
-		if !ok {
-			log.Println("setup log is already working")
-			return
-		}
-		defer func() {
-			setupLogLock.Unlock()
-			setupLogWorking = false
-		}()
-		logPath := filepath.Join(*LogDir, fmt.Sprintf("oneapi-%s.log", time.Now().Format("20060102")))
-		fd, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			log.Fatal("failed to open log file")
+		fileWriter := &lumberjack.Logger{
+			Filename:   filepath.Join(*LogDir, "oneapi.log"),
+			MaxSize:    GetEnvOrDefault("LOG_MAX_SIZE", 100),
+			MaxAge:     GetEnvOrDefault("LOG_MAX_AGE", 7),
+			MaxBackups: GetEnvOrDefault("LOG_MAX_BACKUPS", 0),
+			LocalTime:  true,
+			Compress:   GetEnvOrDefaultBool("LOG_COMPRESS", false),
 		}
-		gin.DefaultWriter = io.MultiWriter(os.Stdout, fd)
-		gin.DefaultErrorWriter = io.MultiWriter(os.Stderr, fd)
+		gin.DefaultWriter = io.MultiWriter(os.Stdout, fileWriter)
+		gin.DefaultErrorWriter = io.MultiWriter(os.Stderr, fileWriter)
+	}
+	handlerOptions := &slog.HandlerOptions{Level: logLevel}
+	jsonLogger = slog.New(slog.NewJSONHandler(gin.DefaultWriter, handlerOptions))
+	jsonErrorLogger = slog.New(slog.NewJSONHandler(gin.DefaultErrorWriter, handlerOptions))
+}
+
+func parseLogLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
 	}
 }
 
+func toSlogLevel(level string) slog.Level {
+	switch level {
+	case loggerDebug:
+		return slog.LevelDebug
+	case loggerWarn:
+		return slog.LevelWarn
+	case loggerError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// LogJSONEnabled 是否输出 JSON 格式的日志
+func LogJSONEnabled() bool {
+	return logJSONEnabled && jsonLogger != nil
+}
+
+// LogLevelEnabled 该级别的日志是否需要输出
+func LogLevelEnabled(level slog.Level) bool {
+	return level >= logLevel.Level()
+}
+
 func SysLog(s string) {
-	t := time.Now()
-	_, _ = fmt.Fprintf(gin.DefaultWriter, "[SYS] %v | %s \n", t.Format("2006/01/02 - 15:04:05"), s)
+	sysLogHelper(loggerINFO, s)
 }
 
 func SysError(s string) {
+	sysLogHelper(loggerError, s)
+}
+
+func sysLogHelper(level string, s string) {
+	slogLevel := toSlogLevel(level)
+	if !LogLevelEnabled(slogLevel) {
+		return
+	}
+	if LogJSONEnabled() {
+		logger := jsonErrorLogger
+		if level == loggerINFO {
+			logger = jsonLogger
+		}
+		logger.LogAttrs(context.Background(), slogLevel, s, slog.String("component", "sys"))
+		return
+	}
+	writer := gin.DefaultErrorWriter
+	if level == loggerINFO {
+		writer = gin.DefaultWriter
+	}
 	t := time.Now()
-	_, _ = fmt.Fprintf(gin.DefaultErrorWriter, "[SYS] %v | %s \n", t.Format("2006/01/02 - 15:04:05"), s)
+	_, _ = fmt.Fprintf(writer, "[SYS] %v | %s \n", t.Format("2006/01/02 - 15:04:05"), s)
+}
+
+func LogDebug(ctx context.Context, msg string) {
+	logHelper(ctx, loggerDebug, msg)
 }
 
 func LogInfo(ctx context.Context, msg string) {
@@ -68,25 +133,64 @@ func LogError(ctx context.Context, msg string) {
 	logHelper(ctx, loggerError, msg)
 }
 
+// LogContextAttrs 从请求上下文中取出请求 ID、用户、渠道、模型和 trace id
+func LogContextAttrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs := make([]slog.Attr, 0, 5)
+	if id, ok := ctx.Value(RequestIdKey).(string); ok && id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	if userId, ok := ctx.Value("id").(int); ok && userId != 0 {
+		attrs = append(attrs, slog.Int("user_id", userId))
+	}
+	if channelId, ok := ctx.Value("channel_id").(int); ok && channelId != 0 {
+		attrs = append(attrs, slog.Int("channel_id", channelId))
+	}
+	if modelName, ok := ctx.Value("original_model").(string); ok && modelName != "" {
+		attrs = append(attrs, slog.String("model", modelName))
+	}
+	traceCtx := ctx
+	if c, ok := ctx.(*gin.Context); ok && c.Request != nil {
+		traceCtx = c.Request.Context()
+	}
+	if spanContext := trace.SpanContextFromContext(traceCtx); spanContext.IsValid() {
+		attrs = append(attrs, slog.String("trace_id", spanContext.TraceID().String()))
+	}
+	return attrs
+}
+
 func logHelper(ctx context.Context, level string, msg string) {
+	slogLevel := toSlogLevel(level)
+	if !LogLevelEnabled(slogLevel) {
+		return
+	}
+	if LogJSONEnabled() {
+		logger := jsonErrorLogger
+		if level == loggerINFO || level == loggerDebug {
+			logger = jsonLogger
+		}
+		logger.LogAttrs(context.Background(), slogLevel, msg, LogContextAttrs(ctx)...)
+		return
+	}
 	writer := gin.DefaultErrorWriter
-	if level == loggerINFO {
+	if level == loggerINFO || level == loggerDebug {
 		writer = gin.DefaultWriter
 	}
-	id := ctx.Value(RequestIdKey)
+	var id any
+	if ctx != nil {
+		id = ctx.Value(RequestIdKey)
+	}
 	now := time.Now()
 	_, _ = fmt.Fprintf(writer, "[%s] %v | %s | %s \n", level, now.Format("2006/01/02 - 15:04:05"), id, msg)
-	logCount++ // we don't need accurate count, so no lock here
-	if logCount > maxLogCount && !setupLogWorking {
-		logCount = 0
-		setupLogWorking = true
-		go func() {
-			SetupLogger()
-		}()
-	}
 }
 
 func FatalLog(v ...any) {
+	if LogJSONEnabled() {
+		jsonErrorLogger.LogAttrs(context.Background(), slog.LevelError, fmt.Sprint(v...), slog.String("component", "sys"), slog.Bool("fatal", true))
+		os.Exit(1)
+	}
 	t := time.Now()
 	_, _ = fmt.Fprintf(gin.DefaultErrorWriter, "[FATAL] %v | %v \n", t.Format("2006/01/02 - 15:04:05"), v)
 	os.Exit(1)
@@ -0,0 +1,100 @@
+package common
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Prometheus 指标，通过 /metrics 暴露，渠道使用渠道 ID 作为标签以控制标签基数
+
+var (
+	MetricRelayRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "one_api_relay_requests_total",
+		Help: "Total number of relay requests by final status code.",
+	}, []string{"model", "channel", "group", "code"})
+
+	MetricRelayDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "one_api_relay_request_duration_seconds",
+		Help:    "Relay request duration including retries.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"model", "group"})
+
+	MetricRelayFirstToken = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "one_api_relay_first_token_seconds",
+		Help:    "Time from sending the upstream request to the first response byte.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 10, 20, 30, 60},
+	}, []string{"model", "channel"})
+
+	MetricUpstreamResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "one_api_upstream_responses_total",
+		Help: "Upstream responses by status code, one per relay attempt.",
+	}, []string{"model", "channel", "code"})
+
+	MetricRelayRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "one_api_relay_retries_total",
+		Help: "Number of retries on other channels.",
+	}, []string{"model", "group"})
+
+	MetricRelayTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "one_api_relay_tokens_total",
+		Help: "Billed tokens by type (prompt or completion).",
+	}, []string{"model", "channel", "group", "type"})
+
+	MetricRelayQuota = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "one_api_relay_quota_total",
+		Help: "Consumed quota.",
+	}, []string{"model", "channel", "group"})
+
+	MetricRelayInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "one_api_relay_in_flight_requests",
+		Help: "Upstream requests currently in flight on this instance.",
+	}, []string{"channel"})
+
+	MetricChannelCacheSyncDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "one_api_channel_cache_sync_duration_seconds",
+		Help:    "Duration of syncing the channel cache from the database.",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		MetricRelayRequests,
+		MetricRelayDuration,
+		MetricRelayFirstToken,
+		MetricUpstreamResponses,
+		MetricRelayRetries,
+		MetricRelayTokens,
+		MetricRelayQuota,
+		MetricRelayInFlight,
+		MetricChannelCacheSyncDuration,
+	)
+}
+
+// RecordRelayMetrics 记录一次中继请求的最终结果，retries 为在其他渠道上的重试次数
+func RecordRelayMetrics(modelName string, channelId int, group string, code int, duration time.Duration, retries int) {
+	MetricRelayRequests.WithLabelValues(modelName, strconv.Itoa(channelId), group, strconv.Itoa(code)).Inc()
+	MetricRelayDuration.WithLabelValues(modelName, group).Observe(duration.Seconds())
+	if retries > 0 {
+		MetricRelayRetries.WithLabelValues(modelName, group).Add(float64(retries))
+	}
+}
+
+// RecordUpstreamMetrics 记录一次上游请求的状态码和首字时间，firstToken 为 0 时不记录首字时间
+func RecordUpstreamMetrics(modelName string, channelId int, code int, firstToken time.Duration) {
+	channel := strconv.Itoa(channelId)
+	MetricUpstreamResponses.WithLabelValues(modelName, channel, strconv.Itoa(code)).Inc()
+	if firstToken > 0 {
+		MetricRelayFirstToken.WithLabelValues(modelName, channel).Observe(firstToken.Seconds())
+	}
+}
+
+// RecordUsageMetrics 记录计费的 token 数和额度
+func RecordUsageMetrics(modelName string, channelId int, group string, promptTokens int, completionTokens int, quota int) {
+	channel := strconv.Itoa(channelId)
+	MetricRelayTokens.WithLabelValues(modelName, channel, group, "prompt").Add(float64(promptTokens))
+	MetricRelayTokens.WithLabelValues(modelName, channel, group, "completion").Add(float64(completionTokens))
+	MetricRelayQuota.WithLabelValues(modelName, channel, group).Add(float64(quota))
+}
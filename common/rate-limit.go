@@ -5,8 +5,14 @@ import (
 	"time"
 )
 
+type rateLimitWeight struct {
+	time   int64
+	weight int64
+}
+
 type InMemoryRateLimiter struct {
 	store              map[string]*[]int64
+	weightStore        map[string]*[]rateLimitWeight
 	mutex              sync.Mutex
 	expirationDuration time.Duration
 }
@@ -16,6 +22,7 @@ func (l *InMemoryRateLimiter) Init(expirationDuration time.Duration) {
 		l.mutex.Lock()
 		if l.store == nil {
 			l.store = make(map[string]*[]int64)
+			l.weightStore = make(map[string]*[]rateLimitWeight)
 			l.expirationDuration = expirationDuration
 			if expirationDuration > 0 {
 				go l.clearExpiredItems()
@@ -37,6 +44,13 @@ func (l *InMemoryRateLimiter) clearExpiredItems() {
 				delete(l.store, key)
 			}
 		}
+		for key := range l.weightStore {
+			queue := l.weightStore[key]
+			size := len(*queue)
+			if size == 0 || now-(*queue)[size-1].time > int64(l.expirationDuration.Seconds()) {
+				delete(l.weightStore, key)
+			}
+		}
 		l.mutex.Unlock()
 	}
 }
@@ -68,3 +82,62 @@ func (l *InMemoryRateLimiter) Request(key string, maxRequestNum int, duration in
 	}
 	return true
 }
+
+// Count 返回 key 在最近 duration 秒内的请求数以及其中最早一次请求的时间
+func (l *InMemoryRateLimiter) Count(key string, duration int64) (int, int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now().Unix()
+	queue, ok := l.store[key]
+	if !ok {
+		return 0, now
+	}
+	count := 0
+	oldest := now
+	for _, t := range *queue {
+		if now-t < duration {
+			if count == 0 {
+				oldest = t
+			}
+			count++
+		}
+	}
+	return count, oldest
+}
+
+// AddWeight 记录 key 在当前时间的一次带权重的用量，如 token 数
+func (l *InMemoryRateLimiter) AddWeight(key string, weight int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	queue, ok := l.weightStore[key]
+	if !ok {
+		s := make([]rateLimitWeight, 0)
+		queue = &s
+		l.weightStore[key] = queue
+	}
+	*queue = append(*queue, rateLimitWeight{time: time.Now().Unix(), weight: weight})
+}
+
+// SumWeight 返回 key 在最近 duration 秒内的用量之和以及其中最早一次用量的时间，同时清理窗口外的记录
+func (l *InMemoryRateLimiter) SumWeight(key string, duration int64) (int64, int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now().Unix()
+	queue, ok := l.weightStore[key]
+	if !ok {
+		return 0, now
+	}
+	i := 0
+	for i < len(*queue) && now-(*queue)[i].time >= duration {
+		i++
+	}
+	*queue = (*queue)[i:]
+	var sum int64
+	for _, w := range *queue {
+		sum += w.weight
+	}
+	if len(*queue) == 0 {
+		return 0, now
+	}
+	return sum, (*queue)[0].time
+}
@@ -0,0 +1,163 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RPM/TPM 限流使用一分钟的滑动窗口
+const UsageRateLimitWindow = 60 // unit is second
+
+// RateLimitTokenKeysKey 需要累计 TPM 用量的限流 key 列表在 gin.Context 中的 key
+const RateLimitTokenKeysKey = "rate_limit_token_keys"
+
+var usageRateLimiter InMemoryRateLimiter
+
+type UsageRateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Duration
+}
+
+// 窗口内请求数未达上限时记录本次请求，返回 {是否允许, 窗口内请求数, 最早一次请求时间}
+var requestRateLimitScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', KEYS[1], window)
+local oldest = now
+local first = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if #first > 0 then
+	oldest = tonumber(first[2])
+end
+return {allowed, count, oldest}
+`)
+
+// 成员格式为 "<唯一标识>:<token 数>"，返回 {窗口内 token 总数, 最早一次用量时间}
+var tokenRateLimitScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local members = redis.call('ZRANGE', KEYS[1], 0, -1, 'WITHSCORES')
+local sum = 0
+local oldest = now
+for i = 1, #members, 2 do
+	sum = sum + tonumber(string.match(members[i], ':(%d+)$'))
+	if i == 1 then
+		oldest = tonumber(members[2])
+	end
+end
+return {sum, oldest}
+`)
+
+func usageRateLimitRedisEnabled() bool {
+	return RedisEnabled && RDB != nil
+}
+
+func rateLimitReset(oldest int64, now int64, window int64) time.Duration {
+	reset := oldest + window - now
+	if reset < 0 {
+		reset = 0
+	}
+	return time.Duration(reset) * time.Millisecond
+}
+
+// RateLimitRequests 在 key 的滑动窗口内计入一次请求，超过 limit 时不计入并返回不允许
+func RateLimitRequests(key string, limit int) (*UsageRateLimitResult, error) {
+	result := &UsageRateLimitResult{Limit: limit}
+	now := time.Now().UnixMilli()
+	window := int64(UsageRateLimitWindow * 1000)
+	var count int
+	var oldest int64
+	if usageRateLimitRedisEnabled() {
+		values, err := requestRateLimitScript.Run(context.Background(), RDB, []string{"rpm:" + key},
+			now, window, limit, fmt.Sprintf("%d:%s", now, GetRandomString(8))).Int64Slice()
+		if err != nil {
+			return nil, err
+		}
+		result.Allowed = values[0] == 1
+		count = int(values[1])
+		oldest = values[2]
+	} else {
+		usageRateLimiter.Init(RateLimitKeyExpirationDuration)
+		result.Allowed = usageRateLimiter.Request("rpm:"+key, limit, UsageRateLimitWindow)
+		var oldestSecond int64
+		count, oldestSecond = usageRateLimiter.Count("rpm:"+key, UsageRateLimitWindow)
+		oldest = oldestSecond * 1000
+	}
+	result.Remaining = limit - count
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+	result.Reset = rateLimitReset(oldest, now, window)
+	return result, nil
+}
+
+// RateLimitTokens 检查 key 在滑动窗口内已使用的 token 数是否达到 limit，实际用量在请求结束后通过 RecordRateLimitTokens 计入
+func RateLimitTokens(key string, limit int) (*UsageRateLimitResult, error) {
+	result := &UsageRateLimitResult{Limit: limit}
+	now := time.Now().UnixMilli()
+	window := int64(UsageRateLimitWindow * 1000)
+	var used int64
+	var oldest int64
+	if usageRateLimitRedisEnabled() {
+		values, err := tokenRateLimitScript.Run(context.Background(), RDB, []string{"tpm:" + key}, now, window).Int64Slice()
+		if err != nil {
+			return nil, err
+		}
+		used = values[0]
+		oldest = values[1]
+	} else {
+		usageRateLimiter.Init(RateLimitKeyExpirationDuration)
+		var oldestSecond int64
+		used, oldestSecond = usageRateLimiter.SumWeight("tpm:"+key, UsageRateLimitWindow)
+		oldest = oldestSecond * 1000
+	}
+	result.Allowed = used < int64(limit)
+	result.Remaining = limit - int(used)
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+	result.Reset = rateLimitReset(oldest, now, window)
+	return result, nil
+}
+
+// RecordRateLimitTokens 将本次请求的 token 用量计入限流中间件记录在上下文中的所有 TPM key
+func RecordRateLimitTokens(ctx context.Context, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	keys, ok := ctx.Value(RateLimitTokenKeysKey).([]string)
+	if !ok || len(keys) == 0 {
+		return
+	}
+	now := time.Now().UnixMilli()
+	for _, key := range keys {
+		if usageRateLimitRedisEnabled() {
+			pipe := RDB.Pipeline()
+			pipe.ZAdd(context.Background(), "tpm:"+key, &redis.Z{
+				Score:  float64(now),
+				Member: fmt.Sprintf("%d%s:%d", now, GetRandomString(8), tokens),
+			})
+			pipe.PExpire(context.Background(), "tpm:"+key, UsageRateLimitWindow*time.Second)
+			if _, err := pipe.Exec(context.Background()); err != nil {
+				SysError("failed to record rate limit tokens: " + err.Error())
+			}
+		} else {
+			usageRateLimiter.Init(RateLimitKeyExpirationDuration)
+			usageRateLimiter.AddWeight("tpm:"+key, int64(tokens))
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package common
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/go-redis/redis/v8"
 )
 
 // ChannelFailureRecord 记录渠道失败信息
@@ -35,8 +39,46 @@ func NewChannelWeightManager(recoveryDuration time.Duration, basePenalty int) *C
 	}
 }
 
+// ChannelWeightState 渠道当前的降权状态，用于管理接口展示
+type ChannelWeightState struct {
+	ChannelId       int   `json:"channel_id"`
+	FailureCount    int   `json:"failure_count"`
+	PenaltyWeight   int   `json:"penalty_weight"`
+	CurrentPenalty  int   `json:"current_penalty"`
+	LastFailureTime int64 `json:"last_failure_time"`
+}
+
+// 启用 Redis 时，失败记录保存在 Redis 中由所有节点共享，本地 map 作为读缓存定期从 Redis 同步，
+// 选择渠道时只读本地缓存，避免每次选择都访问 Redis
+const (
+	channelWeightKeyPrefix    = "channel_weight:"
+	channelWeightChannelsKey  = "channel_weight:channels"
+	channelWeightSyncInterval = 5 * time.Second
+)
+
+// 原子地累加失败次数和降权值，并在恢复时间后自动过期
+var channelWeightRecordScript = redis.NewScript(`
+local count = redis.call('HINCRBY', KEYS[1], 'failure_count', 1)
+local penalty = redis.call('HINCRBY', KEYS[1], 'penalty_weight', tonumber(ARGV[1]) * count)
+redis.call('HSET', KEYS[1], 'last_failure_time', ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+redis.call('SADD', KEYS[2], ARGV[4])
+return {count, penalty}
+`)
+
+func channelWeightKey(channelID int) string {
+	return channelWeightKeyPrefix + strconv.Itoa(channelID)
+}
+
 // RecordFailure 记录渠道失败
 func (m *ChannelWeightManager) RecordFailure(channelID int) {
+	if RedisEnabled && RDB != nil {
+		err := m.recordFailureRedis(channelID)
+		if err == nil {
+			return
+		}
+		SysError(fmt.Sprintf("RecordFailure: record to redis failed, fallback to memory: %s", err.Error()))
+	}
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
@@ -91,6 +133,111 @@ func (m *ChannelWeightManager) GetPenaltyWeight(channelID, maxPenaltyWeight int)
 	return reducedPenalty
 }
 
+func (m *ChannelWeightManager) recordFailureRedis(channelID int) error {
+	now := time.Now()
+	result, err := channelWeightRecordScript.Run(context.Background(), RDB,
+		[]string{channelWeightKey(channelID), channelWeightChannelsKey},
+		m.basePenalty, now.UnixMilli(), m.recoveryDuration.Milliseconds(), channelID).Int64Slice()
+	if err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	m.failureRecords[channelID] = &ChannelFailureRecord{
+		FailureCount:    int(result[0]),
+		LastFailureTime: now,
+		PenaltyWeight:   int(result[1]),
+	}
+	m.mutex.Unlock()
+	SysLog(fmt.Sprintf("RecordFailure: channelId=%d, failureCount=%d, lastFailureTime=%v", channelID, result[0], now))
+	return nil
+}
+
+// SyncFromRedis 从 Redis 加载所有节点共享的失败记录，替换本地缓存
+func (m *ChannelWeightManager) SyncFromRedis() error {
+	ctx := context.Background()
+	members, err := RDB.SMembers(ctx, channelWeightChannelsKey).Result()
+	if err != nil {
+		return err
+	}
+	pipe := RDB.Pipeline()
+	cmds := make(map[int]*redis.StringStringMapCmd, len(members))
+	for _, member := range members {
+		channelID, err := strconv.Atoi(member)
+		if err != nil {
+			continue
+		}
+		cmds[channelID] = pipe.HGetAll(ctx, channelWeightKey(channelID))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return err
+	}
+
+	records := make(map[int]*ChannelFailureRecord, len(cmds))
+	expired := make([]interface{}, 0)
+	for channelID, cmd := range cmds {
+		values := cmd.Val()
+		if len(values) == 0 {
+			// 已过期，完全恢复
+			expired = append(expired, strconv.Itoa(channelID))
+			continue
+		}
+		failureCount, _ := strconv.Atoi(values["failure_count"])
+		penaltyWeight, _ := strconv.Atoi(values["penalty_weight"])
+		lastFailureTime, _ := strconv.ParseInt(values["last_failure_time"], 10, 64)
+		records[channelID] = &ChannelFailureRecord{
+			FailureCount:    failureCount,
+			LastFailureTime: time.UnixMilli(lastFailureTime),
+			PenaltyWeight:   penaltyWeight,
+		}
+	}
+	if len(expired) > 0 {
+		RDB.SRem(ctx, channelWeightChannelsKey, expired...)
+	}
+
+	m.mutex.Lock()
+	m.failureRecords = records
+	m.mutex.Unlock()
+	return nil
+}
+
+// ResetChannel 清除渠道的失败记录，立即恢复权重
+func (m *ChannelWeightManager) ResetChannel(channelID int) error {
+	if RedisEnabled && RDB != nil {
+		ctx := context.Background()
+		if err := RDB.Del(ctx, channelWeightKey(channelID)).Err(); err != nil {
+			return err
+		}
+		RDB.SRem(ctx, channelWeightChannelsKey, strconv.Itoa(channelID))
+	}
+	m.mutex.Lock()
+	delete(m.failureRecords, channelID)
+	m.mutex.Unlock()
+	return nil
+}
+
+// GetAllStates 获取所有渠道当前的降权状态
+func (m *ChannelWeightManager) GetAllStates() []ChannelWeightState {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	now := time.Now()
+	states := make([]ChannelWeightState, 0, len(m.failureRecords))
+	for channelID, record := range m.failureRecords {
+		recoveryFactor := float64(now.Sub(record.LastFailureTime)) / float64(m.recoveryDuration)
+		if recoveryFactor >= 1.0 {
+			continue
+		}
+		states = append(states, ChannelWeightState{
+			ChannelId:       channelID,
+			FailureCount:    record.FailureCount,
+			PenaltyWeight:   record.PenaltyWeight,
+			CurrentPenalty:  int(float64(record.PenaltyWeight) * (1.0 - recoveryFactor)),
+			LastFailureTime: record.LastFailureTime.Unix(),
+		})
+	}
+	return states
+}
+
 // 清理长期未使用的记录
 func (m *ChannelWeightManager) CleanupOldRecords(maxAge time.Duration) {
 	m.mutex.Lock()
@@ -114,6 +261,18 @@ func setupCleanupTask() {
 			ChannelWeights.CleanupOldRecords(30 * time.Minute) // 清理半个小时未使用的记录
 		}
 	}()
+	// 启用 Redis 时定期同步其他节点记录的失败信息
+	syncTicker := time.NewTicker(channelWeightSyncInterval)
+	go func() {
+		for range syncTicker.C {
+			if !RedisEnabled || RDB == nil {
+				continue
+			}
+			if err := ChannelWeights.SyncFromRedis(); err != nil {
+				SysError("sync channel weights from redis failed: " + err.Error())
+			}
+		}
+	}()
 }
 
 func init() {
@@ -0,0 +1,29 @@
+package constant
+
+import "strings"
+
+// AuditLogGroups 对这些分组下的所有令牌记录完整的请求和响应，令牌也可以单独开启
+var AuditLogGroups = []string{}
+
+func AuditLogGroupsToString() string {
+	return strings.Join(AuditLogGroups, ",")
+}
+
+func AuditLogGroupsFromString(s string) {
+	AuditLogGroups = []string{}
+	for _, group := range strings.Split(s, ",") {
+		group = strings.TrimSpace(group)
+		if group != "" {
+			AuditLogGroups = append(AuditLogGroups, group)
+		}
+	}
+}
+
+func IsAuditLogGroup(group string) bool {
+	for _, g := range AuditLogGroups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
@@ -19,6 +19,39 @@ var GetMediaTokenNotStream = common.GetEnvOrDefaultBool("GET_MEDIA_TOKEN_NOT_STR
 
 var UpdateTask = common.GetEnvOrDefaultBool("UPDATE_TASK", true)
 
+// BatchConcurrency 每个批处理任务同时执行的请求数
+var BatchConcurrency = common.GetEnvOrDefault("BATCH_CONCURRENCY", 5)
+
+// BatchMaxRunning 本节点同时执行的批处理任务数，超出的任务保持排队，在之后的轮询中启动
+var BatchMaxRunning = common.GetEnvOrDefault("BATCH_MAX_RUNNING", 2)
+
+// ResponseCacheTTL 响应缓存有效期，单位秒
+var ResponseCacheTTL = common.GetEnvOrDefault("RESPONSE_CACHE_TTL", 3600)
+
+// ResponseCacheSize 未启用 Redis 时内存中最多缓存的响应数
+var ResponseCacheSize = common.GetEnvOrDefault("RESPONSE_CACHE_SIZE", 1000)
+
+// ResponseCacheMaxBytes 单个响应超过该大小时不缓存，单位 KB
+var ResponseCacheMaxBytes = common.GetEnvOrDefault("RESPONSE_CACHE_MAX_BYTES", 1024)
+
+// FileMaxSize /v1/files 上传文件大小上限，单位 MB
+var FileMaxSize = common.GetEnvOrDefault("FILE_MAX_SIZE", 100)
+
+// ResponsesRetentionDays /v1/responses 保存的对话上下文保留天数
+var ResponsesRetentionDays = common.GetEnvOrDefault("RESPONSES_RETENTION_DAYS", 30)
+
+// AuditLogRetentionDays 审计日志保留天数
+var AuditLogRetentionDays = common.GetEnvOrDefault("AUDIT_LOG_RETENTION_DAYS", 30)
+
+// AuditLogMaxBytes 单个请求或响应超过该大小时截断后再记录，单位 KB
+var AuditLogMaxBytes = common.GetEnvOrDefault("AUDIT_LOG_MAX_BYTES", 10240)
+
+// MetricsEnabled 是否暴露 /metrics 供 Prometheus 抓取，默认关闭
+var MetricsEnabled = common.GetEnvOrDefaultBool("METRICS_ENABLED", false)
+
+// MetricsToken 设置后抓取 /metrics 需要携带 Authorization: Bearer <token>，未设置时只允许 root 用户访问
+var MetricsToken = os.Getenv("METRICS_TOKEN")
+
 var GeminiModelMap = map[string]string{
 	"gemini-1.0-pro": "v1",
 }
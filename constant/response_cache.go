@@ -0,0 +1,32 @@
+package constant
+
+import "strings"
+
+// ResponseCacheGroups 对这些分组下的所有令牌启用响应缓存，令牌也可以单独开启
+var ResponseCacheGroups = []string{}
+
+// ResponseCacheQuotaRatio 命中响应缓存时按正常费用的该比例计费，0 表示不计费
+var ResponseCacheQuotaRatio = 0.0
+
+func ResponseCacheGroupsToString() string {
+	return strings.Join(ResponseCacheGroups, ",")
+}
+
+func ResponseCacheGroupsFromString(s string) {
+	ResponseCacheGroups = []string{}
+	for _, group := range strings.Split(s, ",") {
+		group = strings.TrimSpace(group)
+		if group != "" {
+			ResponseCacheGroups = append(ResponseCacheGroups, group)
+		}
+	}
+}
+
+func IsResponseCacheGroup(group string) bool {
+	for _, g := range ResponseCacheGroups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package constant
+
+import "strings"
+
+// 全局 webhook，所有事件（包括渠道事件）都会推送到该地址
+var WebhookEnabled = false
+var WebhookUrl = ""
+var WebhookSecret = ""
+
+// WebhookEvents 全局 webhook 订阅的事件类型，为空时订阅全部事件
+var WebhookEvents = []string{}
+
+// ChannelBalanceLowThreshold 渠道余额（美元）低于该值时触发 channel.balance_low 事件，0 为不启用
+var ChannelBalanceLowThreshold = 0.0
+
+func WebhookEventsToString() string {
+	return strings.Join(WebhookEvents, ",")
+}
+
+func WebhookEventsFromString(s string) {
+	WebhookEvents = ParseWebhookEvents(s)
+}
+
+// ParseWebhookEvents 解析逗号分隔的事件类型列表
+func ParseWebhookEvents(s string) []string {
+	events := []string{}
+	for _, event := range strings.Split(s, ",") {
+		event = strings.TrimSpace(event)
+		if event != "" {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// WebhookEventSubscribed 事件是否在订阅列表中，列表为空时订阅全部事件
+func WebhookEventSubscribed(events []string, eventType string) bool {
+	if len(events) == 0 {
+		return true
+	}
+	for _, e := range events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
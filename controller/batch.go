@@ -0,0 +1,504 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/middleware"
+	"one-api/model"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// 批处理任务保存在 Task 表中（Platform 为 batch），由 UpdateTaskBulk 轮询启动，
+// 每一行请求以提交者的令牌走完整的 TokenAuth -> TokenRateLimit -> Distribute -> AuditLog -> Relay 流程，按正常请求计费
+
+const batchCancelledReason = "cancelled by user"
+
+var batchEndpoints = map[string]bool{
+	"/v1/chat/completions": true,
+	"/v1/completions":      true,
+	"/v1/embeddings":       true,
+}
+
+var (
+	batchEngine     *gin.Engine
+	batchEngineOnce sync.Once
+	batchRunning    sync.Map
+	// batchRunningCount 本节点正在执行的批处理任务数，不超过 constant.BatchMaxRunning
+	batchRunningCount atomic.Int32
+
+	batchInterruptedChecked atomic.Bool
+)
+
+func getBatchEngine() *gin.Engine {
+	batchEngineOnce.Do(func() {
+		batchEngine = gin.New()
+		batchEngine.Use(middleware.RequestId(), middleware.RelayPanicRecover())
+		// 与 relay 路由使用相同的中间件，批处理请求同样受令牌限流和审计日志约束
+		for endpoint := range batchEndpoints {
+			batchEngine.POST(endpoint, middleware.TokenAuth(), middleware.TokenRateLimit(), middleware.Distribute(), middleware.AuditLog(), Relay)
+		}
+	})
+	return batchEngine
+}
+
+func batchFromTask(task *model.Task) (*dto.BatchTaskData, error) {
+	data := &dto.BatchTaskData{}
+	err := json.Unmarshal(task.Data, data)
+	if err != nil {
+		return nil, err
+	}
+	if task.FailReason == batchCancelledReason && data.Status == "in_progress" {
+		data.Status = "cancelling"
+	}
+	return data, nil
+}
+
+func CreateBatch(c *gin.Context) {
+	var request dto.BatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		openAIErrorJSON(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if !batchEndpoints[request.Endpoint] {
+		openAIErrorJSON(c, http.StatusBadRequest, "invalid_endpoint", fmt.Sprintf("unsupported endpoint: %s", request.Endpoint))
+		return
+	}
+	if request.CompletionWindow != "24h" {
+		openAIErrorJSON(c, http.StatusBadRequest, "invalid_completion_window", "completion_window must be 24h")
+		return
+	}
+	userId := c.GetInt("id")
+	if _, err := model.GetFileByFileId(userId, request.InputFileId); err != nil {
+		openAIErrorJSON(c, http.StatusBadRequest, "file_not_found", fmt.Sprintf("No such File object: %s", request.InputFileId))
+		return
+	}
+
+	now := common.GetTimestamp()
+	data := dto.BatchTaskData{
+		Batch: dto.Batch{
+			Id:               "batch_" + common.GetUUID(),
+			Object:           "batch",
+			Endpoint:         request.Endpoint,
+			InputFileId:      request.InputFileId,
+			CompletionWindow: request.CompletionWindow,
+			Status:           "validating",
+			CreatedAt:        now,
+			ExpiresAt:        now + 24*60*60,
+			Metadata:         request.Metadata,
+		},
+		TokenId:  c.GetInt("token_id"),
+		ClientIp: c.ClientIP(),
+	}
+	task := &model.Task{
+		TaskID:     data.Id,
+		Platform:   constant.TaskPlatformBatch,
+		UserId:     userId,
+		Action:     request.Endpoint,
+		Status:     model.TaskStatusSubmitted,
+		SubmitTime: now,
+		Progress:   "0%",
+		Properties: model.Properties{Input: request.InputFileId},
+	}
+	task.SetData(data)
+	if err := task.Insert(); err != nil {
+		openAIErrorJSON(c, http.StatusInternalServerError, "create_batch_failed", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, data.Batch)
+}
+
+func RetrieveBatch(c *gin.Context) {
+	task, exist, err := model.GetByTaskId(c.GetInt("id"), c.Param("id"))
+	if err != nil || !exist || task.Platform != constant.TaskPlatformBatch {
+		openAIErrorJSON(c, http.StatusNotFound, "batch_not_found", fmt.Sprintf("No such Batch object: %s", c.Param("id")))
+		return
+	}
+	data, err := batchFromTask(task)
+	if err != nil {
+		openAIErrorJSON(c, http.StatusInternalServerError, "invalid_batch_data", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, data.Batch)
+}
+
+func ListBatches(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	tasks := model.TaskGetAllUserTask(c.GetInt("id"), 0, limit, model.SyncTaskQueryParams{
+		Platform: constant.TaskPlatformBatch,
+	})
+	batches := make([]dto.Batch, 0, len(tasks))
+	for _, task := range tasks {
+		data, err := batchFromTask(task)
+		if err != nil {
+			continue
+		}
+		batches = append(batches, data.Batch)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object":   "list",
+		"data":     batches,
+		"has_more": false,
+	})
+}
+
+func CancelBatch(c *gin.Context) {
+	task, exist, err := model.GetByTaskId(c.GetInt("id"), c.Param("id"))
+	if err != nil || !exist || task.Platform != constant.TaskPlatformBatch {
+		openAIErrorJSON(c, http.StatusNotFound, "batch_not_found", fmt.Sprintf("No such Batch object: %s", c.Param("id")))
+		return
+	}
+	data, err := batchFromTask(task)
+	if err != nil {
+		openAIErrorJSON(c, http.StatusInternalServerError, "invalid_batch_data", err.Error())
+		return
+	}
+	now := common.GetTimestamp()
+	switch data.Status {
+	case "validating":
+		// 尚未开始执行，直接取消
+		data.Status = "cancelled"
+		data.CancellingAt = now
+		data.CancelledAt = now
+		task.SetData(data)
+		task.Status = model.TaskStatusFailure
+		task.FailReason = batchCancelledReason
+		task.Progress = "100%"
+		task.FinishTime = now
+		err = task.Update()
+	case "in_progress":
+		// 执行中的任务由执行方检测到取消标记后停止
+		data.Status = "cancelling"
+		data.CancellingAt = now
+		err = model.TaskBulkUpdate([]string{task.TaskID}, map[string]any{
+			"fail_reason": batchCancelledReason,
+		})
+	default:
+		openAIErrorJSON(c, http.StatusBadRequest, "invalid_batch_status", fmt.Sprintf("cannot cancel batch with status %s", data.Status))
+		return
+	}
+	if err != nil {
+		openAIErrorJSON(c, http.StatusInternalServerError, "cancel_batch_failed", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, data.Batch)
+}
+
+// UpdateBatchTaskAll 启动待执行的批处理任务，执行过程中被中断（如服务重启）的任务标记为失败
+func UpdateBatchTaskAll(ctx context.Context, taskM map[string]*model.Task) error {
+	// 只有启动后的第一次轮询时本进程还没有运行任何批处理任务，此时未完成的任务才是被中断的；
+	// 之后的轮询中未完成的任务可能刚刚执行完，快照已过期
+	checkInterrupted := !batchInterruptedChecked.Swap(true)
+	for _, task := range taskM {
+		if _, running := batchRunning.Load(task.TaskID); running {
+			continue
+		}
+		switch task.Status {
+		case model.TaskStatusSubmitted:
+			if int(batchRunningCount.Load()) >= constant.BatchMaxRunning {
+				continue
+			}
+			batchRunningCount.Add(1)
+			batchRunning.Store(task.TaskID, true)
+			t := task
+			gopool.Go(func() {
+				defer func() {
+					batchRunning.Delete(t.TaskID)
+					batchRunningCount.Add(-1)
+				}()
+				runBatchTask(t)
+			})
+		default:
+			if !checkInterrupted {
+				continue
+			}
+			// 重新读取任务，避免用过期的快照覆盖已经结束的任务
+			current, exist, err := model.GetByTaskId(task.UserId, task.TaskID)
+			if err != nil || !exist || current.Status != task.Status {
+				continue
+			}
+			task = current
+			data, err := batchFromTask(task)
+			if err != nil {
+				common.LogError(ctx, fmt.Sprintf("batch %s: invalid data: %s", task.TaskID, err.Error()))
+				continue
+			}
+			finishBatchTask(task, data, "failed", "batch execution interrupted")
+		}
+	}
+	return nil
+}
+
+func finishBatchTask(task *model.Task, data *dto.BatchTaskData, status string, reason string) {
+	now := common.GetTimestamp()
+	data.Status = status
+	switch status {
+	case "completed":
+		data.CompletedAt = now
+		task.Status = model.TaskStatusSuccess
+	case "cancelled":
+		data.CancelledAt = now
+		task.Status = model.TaskStatusFailure
+	case "expired":
+		data.ExpiredAt = now
+		task.Status = model.TaskStatusFailure
+	default:
+		data.FailedAt = now
+		task.Status = model.TaskStatusFailure
+	}
+	if reason != "" {
+		task.FailReason = reason
+	}
+	task.SetData(data)
+	task.Progress = "100%"
+	task.FinishTime = now
+	if err := task.Update(); err != nil {
+		common.SysError(fmt.Sprintf("batch %s: update task failed: %s", task.TaskID, err.Error()))
+	}
+	if status == "failed" || status == "expired" {
+		publishTaskFailedEvent(task)
+	}
+}
+
+// parseBatchInput 解析并校验输入文件，返回每一行请求和校验错误
+func parseBatchInput(content string, endpoint string) ([]dto.BatchInputLine, []dto.BatchError) {
+	var lines []dto.BatchInputLine
+	var errs []dto.BatchError
+	customIds := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var line dto.BatchInputLine
+		if err := json.Unmarshal([]byte(text), &line); err != nil {
+			errs = append(errs, dto.BatchError{Code: "invalid_json_line", Message: err.Error(), Line: lineNumber})
+			continue
+		}
+		if line.CustomId == "" || customIds[line.CustomId] {
+			errs = append(errs, dto.BatchError{Code: "duplicate_custom_id", Message: "custom_id must be unique and not empty", Line: lineNumber})
+			continue
+		}
+		if line.Method != http.MethodPost || line.Url != endpoint {
+			errs = append(errs, dto.BatchError{Code: "mismatched_endpoint", Message: fmt.Sprintf("request must be POST %s", endpoint), Line: lineNumber})
+			continue
+		}
+		customIds[line.CustomId] = true
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, dto.BatchError{Code: "invalid_file", Message: err.Error()})
+	}
+	if len(lines) == 0 && len(errs) == 0 {
+		errs = append(errs, dto.BatchError{Code: "empty_file", Message: "input file is empty"})
+	}
+	return lines, errs
+}
+
+// executeBatchLine 以提交者令牌执行一行请求，流式参数会被移除
+func executeBatchLine(tokenKey string, clientIp string, line dto.BatchInputLine) *dto.BatchOutputLine {
+	output := &dto.BatchOutputLine{
+		Id:       "batch_req_" + common.GetUUID(),
+		CustomId: line.CustomId,
+	}
+	body := make(map[string]any)
+	if err := json.Unmarshal(line.Body, &body); err != nil {
+		output.Error = &dto.BatchError{Code: "invalid_body", Message: err.Error()}
+		return output
+	}
+	delete(body, "stream")
+	delete(body, "stream_options")
+	jsonData, _ := json.Marshal(body)
+
+	req, err := http.NewRequest(http.MethodPost, line.Url, bytes.NewReader(jsonData))
+	if err != nil {
+		output.Error = &dto.BatchError{Code: "invalid_request", Message: err.Error()}
+		return output
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer sk-"+tokenKey)
+	req.RemoteAddr = net.JoinHostPort(clientIp, "0")
+	w := httptest.NewRecorder()
+	getBatchEngine().ServeHTTP(w, req)
+
+	output.Response = &dto.BatchOutputResponse{
+		StatusCode: w.Code,
+		RequestId:  w.Header().Get(common.RequestIdKey),
+		Body:       w.Body.Bytes(),
+	}
+	if !json.Valid(output.Response.Body) {
+		output.Response.Body, _ = json.Marshal(w.Body.String())
+	}
+	return output
+}
+
+func saveBatchOutputFile(userId int, batchId string, suffix string, outputs []*dto.BatchOutputLine) (string, error) {
+	var buffer bytes.Buffer
+	for _, output := range outputs {
+		data, err := json.Marshal(output)
+		if err != nil {
+			return "", err
+		}
+		buffer.Write(data)
+		buffer.WriteByte('\n')
+	}
+	file := &model.File{
+		FileId:    "file-" + common.GetUUID(),
+		UserId:    userId,
+		Purpose:   "batch_output",
+		Filename:  fmt.Sprintf("%s_%s.jsonl", batchId, suffix),
+		Bytes:     int64(buffer.Len()),
+		Content:   buffer.String(),
+		CreatedAt: common.GetTimestamp(),
+	}
+	return file.FileId, file.Insert()
+}
+
+func batchCancelled(taskId string) bool {
+	task, exist, err := model.GetByOnlyTaskId(taskId)
+	return err == nil && exist && task.FailReason == batchCancelledReason
+}
+
+func runBatchTask(task *model.Task) {
+	data, err := batchFromTask(task)
+	if err != nil {
+		common.SysError(fmt.Sprintf("batch %s: invalid data: %s", task.TaskID, err.Error()))
+		return
+	}
+	if common.GetTimestamp() > data.ExpiresAt {
+		finishBatchTask(task, data, "expired", "")
+		return
+	}
+	inputFile, err := model.GetFileByFileId(task.UserId, data.InputFileId)
+	if err != nil {
+		data.Errors = &dto.BatchErrors{Object: "list", Data: []dto.BatchError{{Code: "file_not_found", Message: "input file not found"}}}
+		finishBatchTask(task, data, "failed", "input file not found")
+		return
+	}
+	token, err := model.GetTokenById(data.TokenId)
+	if err != nil {
+		data.Errors = &dto.BatchErrors{Object: "list", Data: []dto.BatchError{{Code: "token_not_found", Message: "token not found"}}}
+		finishBatchTask(task, data, "failed", "token not found")
+		return
+	}
+	lines, errs := parseBatchInput(inputFile.Content, data.Endpoint)
+	if len(errs) > 0 {
+		data.Errors = &dto.BatchErrors{Object: "list", Data: errs}
+		finishBatchTask(task, data, "failed", "invalid input file")
+		return
+	}
+
+	data.Status = "in_progress"
+	data.InProgressAt = common.GetTimestamp()
+	data.RequestCounts.Total = len(lines)
+	task.Status = model.TaskStatusInProgress
+	task.StartTime = data.InProgressAt
+	task.SetData(data)
+	if err := task.Update(); err != nil {
+		common.SysError(fmt.Sprintf("batch %s: update task failed: %s", task.TaskID, err.Error()))
+		return
+	}
+	common.SysLog(fmt.Sprintf("batch %s started, %d requests", task.TaskID, len(lines)))
+
+	outputs := make([]*dto.BatchOutputLine, len(lines))
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	concurrency := constant.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	semaphore := make(chan struct{}, concurrency)
+	cancelled := false
+	lastCheck := time.Now()
+	for i, line := range lines {
+		if time.Since(lastCheck) > 5*time.Second {
+			lastCheck = time.Now()
+			if batchCancelled(task.TaskID) {
+				cancelled = true
+				break
+			}
+			mutex.Lock()
+			progress := fmt.Sprintf("%d%%", (data.RequestCounts.Completed+data.RequestCounts.Failed)*100/len(lines))
+			task.SetData(data)
+			mutex.Unlock()
+			_ = model.TaskBulkUpdateByID([]int64{task.ID}, map[string]any{
+				"progress": progress,
+				"data":     task.Data,
+			})
+		}
+		semaphore <- struct{}{}
+		wg.Add(1)
+		index, line := i, line
+		gopool.Go(func() {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			output := executeBatchLine(token.Key, data.ClientIp, line)
+			mutex.Lock()
+			outputs[index] = output
+			if output.Error == nil && output.Response.StatusCode == http.StatusOK {
+				data.RequestCounts.Completed++
+			} else {
+				data.RequestCounts.Failed++
+			}
+			mutex.Unlock()
+		})
+	}
+	wg.Wait()
+
+	data.FinalizingAt = common.GetTimestamp()
+	var succeeded, failed []*dto.BatchOutputLine
+	for _, output := range outputs {
+		if output == nil {
+			continue
+		}
+		if output.Error == nil && output.Response.StatusCode == http.StatusOK {
+			succeeded = append(succeeded, output)
+		} else {
+			failed = append(failed, output)
+		}
+	}
+	if len(succeeded) > 0 {
+		data.OutputFileId, err = saveBatchOutputFile(task.UserId, data.Id, "output", succeeded)
+		if err != nil {
+			common.SysError(fmt.Sprintf("batch %s: save output file failed: %s", task.TaskID, err.Error()))
+		}
+	}
+	if len(failed) > 0 {
+		data.ErrorFileId, err = saveBatchOutputFile(task.UserId, data.Id, "error", failed)
+		if err != nil {
+			common.SysError(fmt.Sprintf("batch %s: save error file failed: %s", task.TaskID, err.Error()))
+		}
+	}
+	status := "completed"
+	reason := ""
+	if cancelled {
+		status = "cancelled"
+		reason = batchCancelledReason
+	}
+	finishBatchTask(task, data, status, reason)
+	common.SysLog(fmt.Sprintf("batch %s %s, completed: %d, failed: %d", task.TaskID, status, data.RequestCounts.Completed, data.RequestCounts.Failed))
+}
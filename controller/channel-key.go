@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type channelKeyItem struct {
+	*model.ChannelKey
+	MaskedKey string                `json:"masked_key"`
+	Stats     model.ChannelKeyStats `json:"stats"` // 当前节点的调用统计，重启后清零
+}
+
+// GetChannelKeys 获取渠道 Key 池中的 Key，Key 只显示首尾几位
+func GetChannelKeys(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	keys, err := model.GetChannelKeys(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	items := make([]channelKeyItem, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, channelKeyItem{
+			ChannelKey: key,
+			MaskedKey:  key.MaskedKey(),
+			Stats:      model.GetChannelKeyStats(key.Id),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    items,
+	})
+}
+
+type AddChannelKeysRequest struct {
+	Keys string `json:"keys"` // 多个 Key 用换行分隔
+}
+
+// AddChannelKeys 向渠道的 Key 池中添加 Key，已存在的 Key 会被跳过；渠道没有设置 Key 选择方式时设为 round_robin
+func AddChannelKeys(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	var req AddChannelKeysRequest
+	err = json.NewDecoder(c.Request.Body).Decode(&req)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	if _, err = model.GetChannelById(id, false); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	var keys []string
+	for _, key := range strings.Split(req.Keys, "\n") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "密钥不能为空",
+		})
+		return
+	}
+	count, err := model.AddChannelKeys(id, keys)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    count,
+	})
+}
+
+// DeleteChannelKey 从渠道的 Key 池中删除 Key，Key 池为空后渠道使用自身的 Key
+func DeleteChannelKey(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	keyId, _ := strconv.Atoi(c.Param("key_id"))
+	err := model.DeleteChannelKey(id, keyId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+type UpdateChannelKeyStatusRequest struct {
+	Status int `json:"status"`
+}
+
+// UpdateChannelKeyStatus 启用或手动禁用 Key，不会修改渠道的状态
+func UpdateChannelKeyStatus(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	keyId, _ := strconv.Atoi(c.Param("key_id"))
+	var req UpdateChannelKeyStatusRequest
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	if err != nil || (req.Status != common.ChannelStatusEnabled && req.Status != common.ChannelStatusManuallyDisabled) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	remaining, err := model.UpdateChannelKeyStatus(id, keyId, req.Status, "")
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"enabled_keys": remaining,
+		},
+	})
+}
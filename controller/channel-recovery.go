@@ -0,0 +1,269 @@
+package controller
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// 自动禁用渠道的恢复探测：只探测自动禁用的渠道，每次失败后等待时间翻倍，
+// 使用渠道的测试模型以及触发禁用的模型测试，全部通过后重新启用渠道；
+// Key 池中自动禁用的 Key 按同样的退避单独探测，通过后重新启用该 Key
+var (
+	channelRecoveryInterval    = common.GetEnvOrDefault("CHANNEL_RECOVERY_INTERVAL", 30)     // 扫描间隔，单位秒，0 为不启用
+	channelRecoveryBaseBackoff = common.GetEnvOrDefault("CHANNEL_RECOVERY_BASE_BACKOFF", 60) // 禁用后首次探测的等待时间，单位秒
+	channelRecoveryMaxBackoff  = common.GetEnvOrDefault("CHANNEL_RECOVERY_MAX_BACKOFF", 3600)
+	channelRecoveryConcurrency = common.GetEnvOrDefault("CHANNEL_RECOVERY_CONCURRENCY", 4)
+)
+
+// channelRecoveryState 渠道的恢复探测进度，disabledAt 变化说明渠道被重新禁用过，需要重新开始退避
+type channelRecoveryState struct {
+	disabledAt  int64
+	attempts    int
+	nextProbeAt int64
+}
+
+var channelRecoveryStates = make(map[int]*channelRecoveryState)
+var channelRecoveryLock sync.Mutex
+
+// ChannelRecoveryStatus 渠道的恢复探测进度，用于管理接口展示
+type ChannelRecoveryStatus struct {
+	Attempts    int   `json:"attempts"`
+	NextProbeAt int64 `json:"next_probe_at"`
+}
+
+// GetChannelRecoveryStatus 获取渠道的恢复探测进度，只有主节点上有数据
+func GetChannelRecoveryStatus(channelId int) *ChannelRecoveryStatus {
+	channelRecoveryLock.Lock()
+	defer channelRecoveryLock.Unlock()
+	state, ok := channelRecoveryStates[channelId]
+	if !ok {
+		return nil
+	}
+	return &ChannelRecoveryStatus{Attempts: state.attempts, NextProbeAt: state.nextProbeAt}
+}
+
+func channelRecoveryBackoff(attempts int) int64 {
+	backoff := int64(channelRecoveryBaseBackoff)
+	for i := 0; i < attempts && backoff < int64(channelRecoveryMaxBackoff); i++ {
+		backoff *= 2
+	}
+	if backoff > int64(channelRecoveryMaxBackoff) {
+		backoff = int64(channelRecoveryMaxBackoff)
+	}
+	return backoff
+}
+
+// isRecoveryDue 更新 id 对应的探测进度并判断是否到了探测时间，调用方需持有 channelRecoveryLock
+func isRecoveryDue(states map[int]*channelRecoveryState, id int, disabledAt int64, now int64) bool {
+	state, ok := states[id]
+	if !ok || state.disabledAt != disabledAt {
+		startAt := disabledAt
+		if startAt == 0 {
+			startAt = now
+		}
+		state = &channelRecoveryState{
+			disabledAt:  disabledAt,
+			nextProbeAt: startAt + channelRecoveryBackoff(0),
+		}
+		states[id] = state
+	}
+	return state.nextProbeAt <= now
+}
+
+// dueRecoveryChannels 返回到了探测时间的自动禁用渠道，并清理已不是自动禁用状态的渠道的进度；
+// Key 池中已没有启用的 Key 的渠道由 Key 的恢复探测负责
+func dueRecoveryChannels(channels []*model.Channel) []*model.Channel {
+	now := common.GetTimestamp()
+	channelRecoveryLock.Lock()
+	defer channelRecoveryLock.Unlock()
+	disabled := make(map[int]bool, len(channels))
+	var due []*model.Channel
+	for _, channel := range channels {
+		if channel.Type == common.ChannelTypeMidjourney || channel.Type == common.ChannelTypeSunoAPI {
+			continue // 不支持测试
+		}
+		if !channel.HasAvailableKey() {
+			continue
+		}
+		disabled[channel.Id] = true
+		if isRecoveryDue(channelRecoveryStates, channel.Id, channel.GetStatusTime(), now) {
+			due = append(due, channel)
+		}
+	}
+	for id := range channelRecoveryStates {
+		if !disabled[id] {
+			delete(channelRecoveryStates, id)
+		}
+	}
+	return due
+}
+
+// probeChannel 依次用渠道的测试模型和触发禁用的模型测试渠道，全部成功时返回 true
+func probeChannel(channel *model.Channel, attempt int) bool {
+	testModels := []string{""}
+	if failedModel := channel.GetStatusFailedModel(); failedModel != "" && failedModel != defaultTestModel(channel) {
+		testModels = append(testModels, failedModel)
+	}
+	for _, testModel := range testModels {
+		tik := time.Now()
+		err, openaiErr := testChannel(channel, testModel)
+		probe := recordChannelTest(channel, model.ChannelProbeSourceRecovery, testModel, "", attempt, time.Since(tik), err, openaiErr)
+		if !service.ShouldEnableChannel(err, openaiErr, channel.Status) {
+			common.SysLog(fmt.Sprintf("channel #%d recovery probe %d failed with model %s: %s", channel.Id, attempt, probe.ModelName, probe.Message))
+			return false
+		}
+	}
+	return true
+}
+
+func recoverChannels() {
+	channels, err := model.GetAutoDisabledChannels()
+	if err != nil {
+		common.SysError("failed to get auto disabled channels: " + err.Error())
+		return
+	}
+	due := dueRecoveryChannels(channels)
+	if len(due) == 0 {
+		return
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, channelRecoveryConcurrency)
+	for _, channel := range due {
+		channel := channel
+		wg.Add(1)
+		sem <- struct{}{}
+		gopool.Go(func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			channelRecoveryLock.Lock()
+			state := channelRecoveryStates[channel.Id]
+			state.attempts++
+			attempt := state.attempts
+			channelRecoveryLock.Unlock()
+
+			if probeChannel(channel, attempt) {
+				service.EnableChannel(channel.Id, channel.Name)
+				common.SysLog(fmt.Sprintf("channel #%d recovered after %d probes", channel.Id, attempt))
+				return
+			}
+			channelRecoveryLock.Lock()
+			state.nextProbeAt = common.GetTimestamp() + channelRecoveryBackoff(attempt)
+			channelRecoveryLock.Unlock()
+		})
+	}
+	wg.Wait()
+}
+
+var channelKeyRecoveryStates = make(map[int]*channelRecoveryState) // Key ID 到探测进度
+
+// dueRecoveryKeys 返回到了探测时间的自动禁用 Key，并清理已不是自动禁用状态的 Key 的进度
+func dueRecoveryKeys(keys []*model.ChannelKey) []*model.ChannelKey {
+	now := common.GetTimestamp()
+	channelRecoveryLock.Lock()
+	defer channelRecoveryLock.Unlock()
+	disabled := make(map[int]bool, len(keys))
+	var due []*model.ChannelKey
+	for _, key := range keys {
+		disabled[key.Id] = true
+		if isRecoveryDue(channelKeyRecoveryStates, key.Id, key.StatusTime, now) {
+			due = append(due, key)
+		}
+	}
+	for id := range channelKeyRecoveryStates {
+		if !disabled[id] {
+			delete(channelKeyRecoveryStates, id)
+		}
+	}
+	return due
+}
+
+// probeChannelKey 用 Key 池中的单个 Key 测试渠道，成功时重新启用该 Key，渠道因 Key 全部禁用而被自动禁用时一并启用渠道
+func probeChannelKey(key *model.ChannelKey, attempt int) bool {
+	channel, err := model.GetChannelById(key.ChannelId, true)
+	if err != nil || channel.Status == common.ChannelStatusManuallyDisabled {
+		return false
+	}
+	if channel.Type == common.ChannelTypeMidjourney || channel.Type == common.ChannelTypeSunoAPI {
+		return false
+	}
+	tik := time.Now()
+	err, openaiErr := testChannelEndpointWithKey(channel, "", "", key)
+	record := recordChannelTest(channel, model.ChannelProbeSourceRecovery, "", "", attempt, time.Since(tik), err, openaiErr)
+	if !service.ShouldEnableChannel(err, openaiErr, common.ChannelStatusAutoDisabled) {
+		common.SysLog(fmt.Sprintf("key #%d of channel #%d recovery probe %d failed: %s", key.Id, key.ChannelId, attempt, record.Message))
+		return false
+	}
+	if _, err := model.UpdateChannelKeyStatus(key.ChannelId, key.Id, common.ChannelStatusEnabled, ""); err != nil {
+		common.SysError(fmt.Sprintf("failed to enable key #%d of channel #%d: %s", key.Id, key.ChannelId, err.Error()))
+		return false
+	}
+	common.SysLog(fmt.Sprintf("key #%d of channel #%d recovered after %d probes", key.Id, key.ChannelId, attempt))
+	if channel.Status == common.ChannelStatusAutoDisabled {
+		service.EnableChannel(channel.Id, channel.Name)
+	}
+	return true
+}
+
+func recoverChannelKeys() {
+	keys, err := model.GetAutoDisabledChannelKeys()
+	if err != nil {
+		common.SysError("failed to get auto disabled channel keys: " + err.Error())
+		return
+	}
+	due := dueRecoveryKeys(keys)
+	if len(due) == 0 {
+		return
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, channelRecoveryConcurrency)
+	for _, key := range due {
+		key := key
+		wg.Add(1)
+		sem <- struct{}{}
+		gopool.Go(func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			channelRecoveryLock.Lock()
+			state := channelKeyRecoveryStates[key.Id]
+			state.attempts++
+			attempt := state.attempts
+			channelRecoveryLock.Unlock()
+
+			if probeChannelKey(key, attempt) {
+				return
+			}
+			channelRecoveryLock.Lock()
+			state.nextProbeAt = common.GetTimestamp() + channelRecoveryBackoff(attempt)
+			channelRecoveryLock.Unlock()
+		})
+	}
+	wg.Wait()
+}
+
+// AutomaticallyRecoverChannels 定期探测自动禁用的渠道，需要开启自动启用渠道
+func AutomaticallyRecoverChannels() {
+	if channelRecoveryInterval <= 0 {
+		return
+	}
+	if channelRecoveryConcurrency <= 0 {
+		channelRecoveryConcurrency = 1
+	}
+	for {
+		time.Sleep(time.Duration(channelRecoveryInterval) * time.Second)
+		if !common.AutomaticEnableChannelEnabled {
+			continue
+		}
+		recoverChannels()
+		recoverChannelKeys()
+	}
+}
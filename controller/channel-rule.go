@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ChannelRuleDryRunRequest struct {
+	Rules          json.RawMessage `json:"rules"`           // 待评估的规则，格式与 ChannelDisableRules 选项相同
+	Limit          int             `json:"limit"`           // 回放最近的错误日志条数，默认 500，最多 5000
+	StartTimestamp int64           `json:"start_timestamp"` // 只回放该时间之后的错误日志
+}
+
+type channelRuleDryRunChange struct {
+	LogId          int    `json:"log_id"`
+	CreatedAt      int64  `json:"created_at"`
+	ChannelId      int    `json:"channel_id"`
+	ChannelType    int    `json:"channel_type"`
+	StatusCode     int    `json:"status_code"`
+	ErrorType      string `json:"error_type"`
+	ErrorCode      string `json:"error_code"`
+	Message        string `json:"message"`
+	CurrentAction  string `json:"current_action"`
+	ProposedAction string `json:"proposed_action"`
+	ProposedRule   int    `json:"proposed_rule"` // 命中的规则下标，未命中为 -1
+}
+
+const channelRuleDryRunMaxChanges = 100
+
+// DryRunChannelDisableRules 用最近的错误日志回放待评估的规则，并与当前生效的规则对比，不会修改任何渠道
+// 错误日志只记录每个请求最终的错误，且只统计非本地错误
+func DryRunChannelDisableRules(c *gin.Context) {
+	var req ChannelRuleDryRunRequest
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	rules, err := common.ParseChannelDisableRules(string(req.Rules))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = 500
+	} else if req.Limit > 5000 {
+		req.Limit = 5000
+	}
+	logs, err := model.GetRecentErrorLogs(req.StartTimestamp, req.Limit)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var missingTypeIds []int
+	others := make([]map[string]interface{}, len(logs))
+	for i, log := range logs {
+		others[i] = common.StrToMap(log.Other)
+		if _, ok := others[i]["channel_type"]; !ok && log.ChannelId != 0 {
+			missingTypeIds = append(missingTypeIds, log.ChannelId)
+		}
+	}
+	channelTypes, err := model.GetChannelTypesByIds(missingTypeIds)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	currentRules := common.GetChannelDisableRules()
+	currentCount := map[string]int{}
+	proposedCount := map[string]int{}
+	ruleHits := make([]int, len(rules))
+	disabledChannels := map[int]bool{}
+	changes := make([]channelRuleDryRunChange, 0)
+	changedCount := 0
+	evaluated := 0
+	for i, log := range logs {
+		other := others[i]
+		if local, _ := other["local_error"].(bool); local || log.ChannelId == 0 {
+			continue
+		}
+		evaluated++
+		info := &common.ChannelErrorInfo{
+			ChannelType: channelTypes[log.ChannelId],
+			StatusCode:  log.StatusCode,
+			Message:     log.Content,
+		}
+		if channelType, ok := other["channel_type"].(float64); ok {
+			info.ChannelType = int(channelType)
+		}
+		if errorType, ok := other["error_type"].(string); ok {
+			info.ErrorType = errorType
+		}
+		if errorCode, ok := other["error_code"]; ok && errorCode != nil {
+			info.ErrorCode = fmt.Sprintf("%v", errorCode)
+		}
+
+		currentAction := common.ChannelErrorAction(currentRules, info)
+		proposedRule := common.MatchChannelDisableRule(rules, info)
+		proposedAction := common.ChannelRuleActionPenalize
+		if proposedRule >= 0 {
+			proposedAction = rules[proposedRule].Action
+			ruleHits[proposedRule]++
+		}
+		currentCount[currentAction]++
+		proposedCount[proposedAction]++
+		if proposedAction == common.ChannelRuleActionDisable {
+			disabledChannels[log.ChannelId] = true
+		}
+		if currentAction != proposedAction {
+			changedCount++
+			if len(changes) < channelRuleDryRunMaxChanges {
+				changes = append(changes, channelRuleDryRunChange{
+					LogId:          log.Id,
+					CreatedAt:      log.CreatedAt,
+					ChannelId:      log.ChannelId,
+					ChannelType:    info.ChannelType,
+					StatusCode:     info.StatusCode,
+					ErrorType:      info.ErrorType,
+					ErrorCode:      info.ErrorCode,
+					Message:        info.Message,
+					CurrentAction:  currentAction,
+					ProposedAction: proposedAction,
+					ProposedRule:   proposedRule,
+				})
+			}
+		}
+	}
+	disabledChannelIds := make([]int, 0, len(disabledChannels))
+	for id := range disabledChannels {
+		disabledChannelIds = append(disabledChannelIds, id)
+	}
+	sort.Ints(disabledChannelIds)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"total":             len(logs),
+			"evaluated":         evaluated,
+			"current":           currentCount,
+			"proposed":          proposedCount,
+			"rule_hits":         ruleHits,
+			"disabled_channels": disabledChannelIds,
+			"changed":           changedCount,
+			"changes":           changes,
+		},
+	})
+}
+
+// GetChannelDisableRules 获取当前生效的渠道错误规则
+func GetChannelDisableRules(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    common.GetChannelDisableRules(),
+	})
+}
@@ -2,12 +2,14 @@ package controller
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/bytedance/gopkg/util/gopool"
 	"io"
 	"math"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -16,6 +18,7 @@ import (
 	"one-api/middleware"
 	"one-api/model"
 	"one-api/relay"
+	relaychannel "one-api/relay/channel"
 	relaycommon "one-api/relay/common"
 	"one-api/relay/constant"
 	"one-api/service"
@@ -27,7 +30,67 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// defaultTestModel 渠道未指定测试模型时使用第一个模型
+func defaultTestModel(channel *model.Channel) string {
+	if channel.TestModel != nil && *channel.TestModel != "" {
+		return *channel.TestModel
+	}
+	if len(channel.GetModels()) > 0 {
+		return channel.GetModels()[0]
+	}
+	return "gpt-3.5-turbo"
+}
+
+// 渠道测试请求的接口类型
+const (
+	channelTestEndpointChat          = "chat"
+	channelTestEndpointEmbedding     = "embedding"
+	channelTestEndpointImage         = "image"
+	channelTestEndpointRerank        = "rerank"
+	channelTestEndpointSpeech        = "speech"
+	channelTestEndpointTranscription = "transcription"
+)
+
+var channelTestEndpointPaths = map[string]string{
+	channelTestEndpointChat:          "/v1/chat/completions",
+	channelTestEndpointEmbedding:     "/v1/embeddings",
+	channelTestEndpointImage:         "/v1/images/generations",
+	channelTestEndpointRerank:        "/v1/rerank",
+	channelTestEndpointSpeech:        "/v1/audio/speech",
+	channelTestEndpointTranscription: "/v1/audio/transcriptions",
+}
+
+// detectTestEndpoint 根据模型名判断测试时使用的接口，避免用对话请求测试向量、绘图等模型导致渠道被误禁用
+func detectTestEndpoint(modelName string) string {
+	name := strings.ToLower(modelName)
+	switch {
+	case strings.Contains(name, "rerank"):
+		return channelTestEndpointRerank
+	case strings.Contains(name, "embedding") || strings.HasPrefix(name, "bge-") || strings.HasPrefix(name, "m3e"):
+		return channelTestEndpointEmbedding
+	case strings.HasPrefix(name, "dall-e") || strings.HasPrefix(name, "gpt-image") || strings.HasPrefix(name, "imagen") ||
+		strings.Contains(name, "flux") || strings.Contains(name, "stable-diffusion") || strings.Contains(name, "sdxl"):
+		return channelTestEndpointImage
+	case strings.HasPrefix(name, "tts-") || strings.HasSuffix(name, "-tts"):
+		return channelTestEndpointSpeech
+	case strings.HasPrefix(name, "whisper") || strings.Contains(name, "transcribe"):
+		return channelTestEndpointTranscription
+	default:
+		return channelTestEndpointChat
+	}
+}
+
 func testChannel(channel *model.Channel, testModel string) (err error, openAIErrorWithStatusCode *dto.OpenAIErrorWithStatusCode) {
+	return testChannelEndpoint(channel, testModel, "")
+}
+
+// testChannelEndpoint 用指定接口测试渠道，endpoint 为空时根据模型名判断
+func testChannelEndpoint(channel *model.Channel, testModel string, endpoint string) (err error, openAIErrorWithStatusCode *dto.OpenAIErrorWithStatusCode) {
+	return testChannelEndpointWithKey(channel, testModel, endpoint, nil)
+}
+
+// testChannelEndpointWithKey key 不为空时使用 Key 池中的指定 Key 测试，否则按渠道的选择方式选择 Key
+func testChannelEndpointWithKey(channel *model.Channel, testModel string, endpoint string, key *model.ChannelKey) (err error, openAIErrorWithStatusCode *dto.OpenAIErrorWithStatusCode) {
 	tik := time.Now()
 	if channel.Type == common.ChannelTypeMidjourney {
 		return errors.New("midjourney channel test is not supported"), nil
@@ -35,25 +98,9 @@ func testChannel(channel *model.Channel, testModel string) (err error, openAIErr
 	if channel.Type == common.ChannelTypeSunoAPI {
 		return errors.New("suno channel test is not supported"), nil
 	}
-	w := httptest.NewRecorder()
-	c, _ := gin.CreateTestContext(w)
-	c.Request = &http.Request{
-		Method: "POST",
-		URL:    &url.URL{Path: "/v1/chat/completions"},
-		Body:   nil,
-		Header: make(http.Header),
-	}
 
 	if testModel == "" {
-		if channel.TestModel != nil && *channel.TestModel != "" {
-			testModel = *channel.TestModel
-		} else {
-			if len(channel.GetModels()) > 0 {
-				testModel = channel.GetModels()[0]
-			} else {
-				testModel = "gpt-3.5-turbo"
-			}
-		}
+		testModel = defaultTestModel(channel)
 	} else {
 		modelMapping := *channel.ModelMapping
 		if modelMapping != "" && modelMapping != "{}" {
@@ -67,6 +114,22 @@ func testChannel(channel *model.Channel, testModel string) (err error, openAIErr
 			}
 		}
 	}
+	if endpoint == "" {
+		endpoint = detectTestEndpoint(testModel)
+	}
+	path, ok := channelTestEndpointPaths[endpoint]
+	if !ok {
+		return fmt.Errorf("invalid test endpoint: %s", endpoint), nil
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = &http.Request{
+		Method: "POST",
+		URL:    &url.URL{Path: path},
+		Body:   nil,
+		Header: make(http.Header),
+	}
 
 	c.Request.Header.Set("Authorization", "Bearer "+channel.Key)
 	c.Request.Header.Set("Content-Type", "application/json")
@@ -74,6 +137,17 @@ func testChannel(channel *model.Channel, testModel string) (err error, openAIErr
 	c.Set("base_url", channel.GetBaseURL())
 
 	middleware.SetupContextForSelectedChannel(c, channel, testModel)
+	if key != nil {
+		c.Request.Header.Set("Authorization", "Bearer "+key.Key)
+		c.Set("channel_key_id", key.Id)
+	}
+
+	if endpoint == channelTestEndpointTranscription {
+		// 转写接口从表单中读取音频文件
+		if err = setupTranscriptionTestRequest(c, testModel); err != nil {
+			return err, nil
+		}
+	}
 
 	meta := relaycommon.GenRelayInfo(c)
 	apiType, _ := constant.ChannelType2APIType(channel.Type)
@@ -82,22 +156,15 @@ func testChannel(channel *model.Channel, testModel string) (err error, openAIErr
 		return fmt.Errorf("invalid api type: %d, adaptor is nil", apiType), nil
 	}
 
-	request := buildTestRequest(testModel)
 	meta.UpstreamModelName = testModel
-	common.SysLog(fmt.Sprintf("testing channel %d with model %s", channel.Id, testModel))
+	common.SysLog(fmt.Sprintf("testing channel %d with model %s (%s)", channel.Id, testModel, endpoint))
 
 	adaptor.Init(meta)
 
-	convertedRequest, err := adaptor.ConvertRequest(c, meta, request)
+	requestBody, err := buildTestRequestBody(c, adaptor, meta, endpoint, testModel)
 	if err != nil {
 		return err, nil
 	}
-	jsonData, err := json.Marshal(convertedRequest)
-	if err != nil {
-		return err, nil
-	}
-	requestBody := bytes.NewBuffer(jsonData)
-	c.Request.Body = io.NopCloser(requestBody)
 	resp, err := adaptor.DoRequest(c, meta, requestBody)
 	if err != nil {
 		return err, nil
@@ -114,10 +181,14 @@ func testChannel(channel *model.Channel, testModel string) (err error, openAIErr
 	if respErr != nil {
 		return fmt.Errorf("%s", respErr.Error.Message), respErr
 	}
-	if usageA == nil {
-		return errors.New("usage is nil"), nil
+	usage, _ := usageA.(*dto.Usage)
+	if usage == nil {
+		if endpoint == channelTestEndpointChat {
+			return errors.New("usage is nil"), nil
+		}
+		// 绘图等接口不返回用量
+		usage = &dto.Usage{}
 	}
-	usage := usageA.(*dto.Usage)
 	result := w.Result()
 	respBody, err := io.ReadAll(result.Body)
 	if err != nil {
@@ -142,10 +213,102 @@ func testChannel(channel *model.Channel, testModel string) (err error, openAIErr
 	consumedTime := float64(milliseconds) / 1000.0
 	other := service.GenerateTextOtherInfo(c, meta, modelRatio, 1, completionRatio, modelPrice)
 	model.RecordConsumeLog(c, 1, channel.Id, usage.PromptTokens, usage.CompletionTokens, 0, testModel, "模型测试", quota, "模型测试", 0, quota, int(consumedTime), false, other)
-	common.SysLog(fmt.Sprintf("testing channel #%d, response: \n%s", channel.Id, string(respBody)))
+	if endpoint == channelTestEndpointSpeech {
+		common.SysLog(fmt.Sprintf("testing channel #%d, response: %d bytes of audio", channel.Id, len(respBody)))
+	} else {
+		common.SysLog(fmt.Sprintf("testing channel #%d, response: \n%s", channel.Id, string(respBody)))
+	}
 	return nil, nil
 }
 
+// buildTestRequestBody 构造对应接口的测试请求并转换为上游格式
+func buildTestRequestBody(c *gin.Context, adaptor relaychannel.Adaptor, meta *relaycommon.RelayInfo, endpoint string, testModel string) (io.Reader, error) {
+	var convertedRequest any
+	var err error
+	switch endpoint {
+	case channelTestEndpointEmbedding:
+		convertedRequest, err = adaptor.ConvertRequest(c, meta, &dto.GeneralOpenAIRequest{
+			Model: testModel,
+			Input: "hi",
+		})
+	case channelTestEndpointImage:
+		convertedRequest, err = adaptor.ConvertImageRequest(c, meta, dto.ImageRequest{
+			Model:  testModel,
+			Prompt: "a white circle",
+			N:      1,
+		})
+	case channelTestEndpointRerank:
+		convertedRequest, err = adaptor.ConvertRerankRequest(c, meta.RelayMode, dto.RerankRequest{
+			Model:     testModel,
+			Query:     "hi",
+			Documents: []any{"hi", "hello"},
+			TopN:      1,
+		})
+	case channelTestEndpointSpeech, channelTestEndpointTranscription:
+		return adaptor.ConvertAudioRequest(c, meta, dto.AudioRequest{
+			Model: testModel,
+			Input: "hi",
+			Voice: "alloy",
+		})
+	default:
+		convertedRequest, err = adaptor.ConvertRequest(c, meta, buildTestRequest(testModel))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if reader, ok := convertedRequest.(io.Reader); ok {
+		return reader, nil
+	}
+	jsonData, err := json.Marshal(convertedRequest)
+	if err != nil {
+		return nil, err
+	}
+	requestBody := bytes.NewBuffer(jsonData)
+	c.Request.Body = io.NopCloser(bytes.NewReader(jsonData))
+	return requestBody, nil
+}
+
+// setupTranscriptionTestRequest 构造带有一段静音音频的转写表单请求
+func setupTranscriptionTestRequest(c *gin.Context, testModel string) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	_ = writer.WriteField("model", testModel)
+	part, err := writer.CreateFormFile("file", "test.wav")
+	if err != nil {
+		return err
+	}
+	if _, err = part.Write(silentWav(time.Second)); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	c.Request.Body = io.NopCloser(&body)
+	return c.Request.ParseMultipartForm(1 << 20)
+}
+
+// silentWav 生成 16kHz 单声道 16 位的静音 WAV 文件
+func silentWav(duration time.Duration) []byte {
+	const sampleRate = 16000
+	dataSize := int(duration.Seconds()*sampleRate) * 2
+	buf := bytes.NewBuffer(make([]byte, 0, 44+dataSize))
+	buf.WriteString("RIFF")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(36+dataSize))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(1)) // PCM
+	_ = binary.Write(buf, binary.LittleEndian, uint16(1)) // mono
+	_ = binary.Write(buf, binary.LittleEndian, uint32(sampleRate))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(sampleRate*2))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(2))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(dataSize))
+	buf.Write(make([]byte, dataSize))
+	return buf.Bytes()
+}
+
 func buildTestRequest(model string) *dto.GeneralOpenAIRequest {
 	testRequest := &dto.GeneralOpenAIRequest{
 		Model:  "", // this will be set later
@@ -168,6 +331,60 @@ func buildTestRequest(model string) *dto.GeneralOpenAIRequest {
 	return testRequest
 }
 
+// recordChannelTest 保存一次渠道测试的结果，testModel 为空时记录渠道的默认测试模型
+func recordChannelTest(channel *model.Channel, source string, testModel string, endpoint string, attempt int, elapsed time.Duration, err error, openaiErr *dto.OpenAIErrorWithStatusCode) *model.ChannelProbe {
+	if testModel == "" {
+		testModel = defaultTestModel(channel)
+	}
+	if endpoint == "" {
+		endpoint = detectTestEndpoint(testModel)
+	}
+	probe := &model.ChannelProbe{
+		ChannelId:    channel.Id,
+		Source:       source,
+		ModelName:    testModel,
+		Endpoint:     endpoint,
+		Success:      err == nil && openaiErr == nil,
+		ResponseTime: elapsed.Milliseconds(),
+		Attempt:      attempt,
+	}
+	if openaiErr != nil {
+		probe.StatusCode = openaiErr.StatusCode
+	}
+	if err != nil {
+		probe.Message = err.Error()
+	}
+	model.RecordChannelProbe(probe)
+	return probe
+}
+
+// 测试渠道所有模型时的并发数
+const channelTestAllModelsConcurrency = 4
+
+// testChannelAllModels 测试渠道的每个模型，各模型根据模型名使用对应的接口
+func testChannelAllModels(channel *model.Channel) []*model.ChannelProbe {
+	models := channel.GetModels()
+	results := make([]*model.ChannelProbe, len(models))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, channelTestAllModelsConcurrency)
+	for i, modelName := range models {
+		i, modelName := i, modelName
+		wg.Add(1)
+		sem <- struct{}{}
+		gopool.Go(func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			tik := time.Now()
+			err, openaiErr := testChannel(channel, modelName)
+			results[i] = recordChannelTest(channel, model.ChannelProbeSourceManual, modelName, "", 0, time.Since(tik), err, openaiErr)
+		})
+	}
+	wg.Wait()
+	return results
+}
+
 func TestChannel(c *gin.Context) {
 	channelId, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -185,12 +402,28 @@ func TestChannel(c *gin.Context) {
 		})
 		return
 	}
+	if c.Query("all_models") == "true" {
+		results := testChannelAllModels(channel)
+		success := true
+		for _, result := range results {
+			success = success && result.Success
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "",
+			"passed":  success,
+			"data":    results,
+		})
+		return
+	}
 	testModel := c.Query("model")
+	endpoint := c.Query("endpoint")
 	tik := time.Now()
-	err, _ = testChannel(channel, testModel)
+	err, openaiErr := testChannelEndpoint(channel, testModel, endpoint)
 	tok := time.Now()
 	milliseconds := tok.Sub(tik).Milliseconds()
 	go channel.UpdateResponseTime(milliseconds)
+	go recordChannelTest(channel, model.ChannelProbeSourceManual, testModel, endpoint, 0, tok.Sub(tik), err, openaiErr)
 	consumedTime := float64(milliseconds) / 1000.0
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -208,6 +441,35 @@ func TestChannel(c *gin.Context) {
 	return
 }
 
+// GetChannelTestHistory 获取渠道最近的测试记录，包括恢复探测
+func GetChannelTestHistory(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
+	probes, err := model.GetChannelProbes(channelId, limit)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    probes,
+	})
+}
+
 var testAllChannelsLock sync.Mutex
 var testAllChannelsRunning bool = false
 
@@ -237,6 +499,7 @@ func testAllChannels(notify bool) error {
 			err, openaiWithStatusErr := testChannel(channel, "")
 			tok := time.Now()
 			milliseconds := tok.Sub(tik).Milliseconds()
+			recordChannelTest(channel, model.ChannelProbeSourceScheduled, "", "", 0, tok.Sub(tik), err, openaiWithStatusErr)
 
 			shouldBanChannel := false
 
@@ -254,7 +517,7 @@ func testAllChannels(notify bool) error {
 
 			// disable channel
 			if isChannelEnabled && shouldBanChannel && channel.GetAutoBan() {
-				service.DisableChannel(channel.Id, channel.Name, err.Error())
+				service.DisableChannel(channel.Id, channel.Name, err.Error(), "")
 			}
 
 			// enable channel
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/model"
+	"one-api/service"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -201,10 +203,17 @@ func GetChannel(c *gin.Context) {
 		})
 		return
 	}
+	probes, err := model.GetChannelProbes(id, 20)
+	if err != nil {
+		common.SysError("failed to get channel test history: " + err.Error())
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "",
-		"data":    channel,
+		"success":          true,
+		"message":          "",
+		"data":             channel,
+		"circuit_breakers": common.ChannelBreakers.GetStates(id),
+		"probe_history":    probes,
+		"recovery":         GetChannelRecoveryStatus(id),
 	})
 	return
 }
@@ -243,6 +252,25 @@ func AddChannel(c *gin.Context) {
 		}
 		keys = []string{channel.Key}
 	}
+	if !isValidKeySelectMode(channel.KeySelectMode) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的 Key 选择方式",
+		})
+		return
+	}
+	if _, err = service.ParseChannelTransportSetting(channel.GetTransportSetting()); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if channel.KeySelectMode != "" && channel.Type != common.ChannelTypeVertexAi {
+		// 设置了 Key 选择方式时，多个 Key 放入同一个渠道的 Key 池，而不是每个 Key 创建一个渠道
+		addChannelWithKeyPool(c, channel, keys)
+		return
+	}
 	channels := make([]model.Channel, 0, len(keys))
 	for _, key := range keys {
 		if key == "" {
@@ -267,6 +295,43 @@ func AddChannel(c *gin.Context) {
 	return
 }
 
+func isValidKeySelectMode(mode string) bool {
+	return mode == "" || mode == model.ChannelKeySelectRoundRobin || mode == model.ChannelKeySelectRandom
+}
+
+func addChannelWithKeyPool(c *gin.Context, channel model.Channel, keys []string) {
+	poolKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key != "" {
+			poolKeys = append(poolKeys, key)
+		}
+	}
+	if len(poolKeys) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "密钥不能为空",
+		})
+		return
+	}
+	channel.Key = poolKeys[0]
+	err := channel.Insert()
+	if err == nil {
+		_, err = model.AddChannelKeys(channel.Id, poolKeys)
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
 // DeleteChannel 删除指定的频道
 // 参数: c *gin.Context - Gin框架的上下文对象，用于处理HTTP请求和响应
 // 该函数从请求参数中获取频道ID，然后调用模型删除对应的频道
@@ -463,6 +528,20 @@ func UpdateChannel(c *gin.Context) {
 			}
 		}
 	}
+	if !isValidKeySelectMode(channel.KeySelectMode) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的 Key 选择方式",
+		})
+		return
+	}
+	if _, err = service.ParseChannelTransportSetting(channel.GetTransportSetting()); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	err = channel.Update()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -478,3 +557,78 @@ func UpdateChannel(c *gin.Context) {
 	})
 	return
 }
+
+// GetChannelWeights 获取各渠道当前的失败降权状态
+func GetChannelWeights(c *gin.Context) {
+	states := common.ChannelWeights.GetAllStates()
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].ChannelId < states[j].ChannelId
+	})
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    states,
+	})
+	return
+}
+
+// ResetChannelWeight 清除渠道的失败记录，立即恢复其权重
+func ResetChannelWeight(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	err = common.ChannelWeights.ResetChannel(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+// GetChannelCircuitBreakers 获取渠道+模型的熔断状态，可通过 channel_id 参数筛选
+func GetChannelCircuitBreakers(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	states := common.ChannelBreakers.GetStates(channelId)
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].ChannelId != states[j].ChannelId {
+			return states[i].ChannelId < states[j].ChannelId
+		}
+		return states[i].Model < states[j].Model
+	})
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    states,
+	})
+	return
+}
+
+// ResetChannelCircuitBreakers 手动关闭渠道所有模型的熔断器
+func ResetChannelCircuitBreakers(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	common.ChannelBreakers.Reset(id)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func openAIErrorJSON(c *gin.Context, statusCode int, code string, message string) {
+	c.JSON(statusCode, gin.H{
+		"error": dto.OpenAIError{
+			Message: message,
+			Type:    "invalid_request_error",
+			Code:    code,
+		},
+	})
+}
+
+func file2OpenAIFile(file *model.File) dto.OpenAIFile {
+	return dto.OpenAIFile{
+		Id:        file.FileId,
+		Object:    "file",
+		Bytes:     file.Bytes,
+		CreatedAt: file.CreatedAt,
+		Filename:  file.Filename,
+		Purpose:   file.Purpose,
+	}
+}
+
+func UploadFile(c *gin.Context) {
+	purpose := c.PostForm("purpose")
+	if purpose != "batch" {
+		openAIErrorJSON(c, http.StatusBadRequest, "invalid_purpose", "only purpose 'batch' is supported")
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		openAIErrorJSON(c, http.StatusBadRequest, "invalid_file", err.Error())
+		return
+	}
+	if fileHeader.Size > int64(constant.FileMaxSize)<<20 {
+		openAIErrorJSON(c, http.StatusBadRequest, "file_too_large", fmt.Sprintf("file size exceeds %d MB", constant.FileMaxSize))
+		return
+	}
+	f, err := fileHeader.Open()
+	if err != nil {
+		openAIErrorJSON(c, http.StatusBadRequest, "invalid_file", err.Error())
+		return
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		openAIErrorJSON(c, http.StatusBadRequest, "invalid_file", err.Error())
+		return
+	}
+	file := &model.File{
+		FileId:    "file-" + common.GetUUID(),
+		UserId:    c.GetInt("id"),
+		Purpose:   purpose,
+		Filename:  fileHeader.Filename,
+		Bytes:     int64(len(content)),
+		Content:   string(content),
+		CreatedAt: common.GetTimestamp(),
+	}
+	if err := file.Insert(); err != nil {
+		openAIErrorJSON(c, http.StatusInternalServerError, "save_file_failed", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, file2OpenAIFile(file))
+}
+
+func ListFiles(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	// 多取一条用于判断是否还有下一页
+	files, err := model.GetUserFiles(c.GetInt("id"), c.Query("purpose"), c.Query("after"), limit+1)
+	if err != nil {
+		openAIErrorJSON(c, http.StatusInternalServerError, "list_files_failed", err.Error())
+		return
+	}
+	hasMore := len(files) > limit
+	if hasMore {
+		files = files[:limit]
+	}
+	data := make([]dto.OpenAIFile, 0, len(files))
+	for _, file := range files {
+		data = append(data, file2OpenAIFile(file))
+	}
+	var firstId, lastId string
+	if len(data) > 0 {
+		firstId = data[0].Id
+		lastId = data[len(data)-1].Id
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object":   "list",
+		"data":     data,
+		"first_id": firstId,
+		"last_id":  lastId,
+		"has_more": hasMore,
+	})
+}
+
+func RetrieveFile(c *gin.Context) {
+	file, err := model.GetFileByFileId(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		openAIErrorJSON(c, http.StatusNotFound, "file_not_found", fmt.Sprintf("No such File object: %s", c.Param("id")))
+		return
+	}
+	c.JSON(http.StatusOK, file2OpenAIFile(file))
+}
+
+func RetrieveFileContent(c *gin.Context) {
+	file, err := model.GetFileByFileId(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		openAIErrorJSON(c, http.StatusNotFound, "file_not_found", fmt.Sprintf("No such File object: %s", c.Param("id")))
+		return
+	}
+	c.Data(http.StatusOK, "application/octet-stream", []byte(file.Content))
+}
+
+func DeleteFile(c *gin.Context) {
+	fileId := c.Param("id")
+	if _, err := model.GetFileByFileId(c.GetInt("id"), fileId); err != nil {
+		openAIErrorJSON(c, http.StatusNotFound, "file_not_found", fmt.Sprintf("No such File object: %s", fileId))
+		return
+	}
+	if err := model.DeleteFileByFileId(c.GetInt("id"), fileId); err != nil {
+		openAIErrorJSON(c, http.StatusInternalServerError, "delete_file_failed", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      fileId,
+		"object":  "file",
+		"deleted": true,
+	})
+}
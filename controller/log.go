@@ -9,6 +9,15 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+func getLogFilter(c *gin.Context) model.LogFilter {
+	statusCode, _ := strconv.Atoi(c.Query("status_code"))
+	return model.LogFilter{
+		RequestId:  c.Query("request_id"),
+		Ip:         c.Query("ip"),
+		StatusCode: statusCode,
+	}
+}
+
 func GetAllLogs(c *gin.Context) {
 	p, _ := strconv.Atoi(c.Query("p"))
 	pageSize, _ := strconv.Atoi(c.Query("page_size"))
@@ -25,7 +34,7 @@ func GetAllLogs(c *gin.Context) {
 	tokenName := c.Query("token_name")
 	modelName := c.Query("model_name")
 	channel, _ := strconv.Atoi(c.Query("channel"))
-	logs, total, err := model.GetAllLogs(logType, startTimestamp, endTimestamp, modelName, username, tokenName, (p-1)*pageSize, pageSize, channel)
+	logs, total, err := model.GetAllLogs(logType, startTimestamp, endTimestamp, modelName, username, tokenName, (p-1)*pageSize, pageSize, channel, getLogFilter(c))
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -63,7 +72,7 @@ func GetUserLogs(c *gin.Context) {
 	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
 	tokenName := c.Query("token_name")
 	modelName := c.Query("model_name")
-	logs, total, err := model.GetUserLogs(userId, logType, startTimestamp, endTimestamp, modelName, tokenName, (p-1)*pageSize, pageSize)
+	logs, total, err := model.GetUserLogs(userId, logType, startTimestamp, endTimestamp, modelName, tokenName, (p-1)*pageSize, pageSize, getLogFilter(c))
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -245,3 +254,28 @@ func GetTokenCacheHitStats(c *gin.Context) {
 	})
 	return
 }
+
+// GetAuditLog 获取请求的审计日志，返回解密后的请求和响应
+func GetAuditLog(c *gin.Context) {
+	logs, requests, responses, err := model.GetAuditLogsByRequestId(c.Param("request_id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	items := make([]gin.H, 0, len(logs))
+	for i, log := range logs {
+		items = append(items, gin.H{
+			"log":      log,
+			"request":  string(requests[i]),
+			"response": string(responses[i]),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    items,
+	})
+}
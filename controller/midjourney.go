@@ -150,6 +150,13 @@ func UpdateMidjourneyTaskBulk() {
 				if (task.Progress != "100%" && responseItem.FailReason != "") || (task.Progress == "100%" && task.Status == "FAILURE") {
 					common.LogInfo(ctx, task.MjId+" 构建失败，"+task.FailReason)
 					task.Progress = "100%"
+					common.PublishEvent(common.EventTaskFailed, task.UserId, map[string]any{
+						"task_id":     task.MjId,
+						"platform":    constant.TaskPlatformMidjourney,
+						"action":      task.Action,
+						"fail_reason": task.FailReason,
+						"quota":       task.Quota,
+					})
 					if task.Quota != 0 {
 						shouldReturnQuota = true
 					}
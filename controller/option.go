@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"one-api/common"
+	"one-api/constant"
 	"one-api/model"
+	"one-api/service"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -91,6 +93,76 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "GroupRateLimit":
+		err = common.CheckGroupRateLimit(option.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "GroupHedgeDelay":
+		err = common.CheckGroupHedgeDelay(option.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "WebhookEnabled":
+		if option.Value == "true" && constant.WebhookUrl == "" {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无法启用 Webhook 通知，请先填入 Webhook 地址！",
+			})
+			return
+		}
+	case "AuditLogGroups":
+		if strings.Trim(option.Value, ", ") != "" && !common.CryptoSecretConfigured() {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "开启审计日志前请先设置 CRYPTO_SECRET 或 SESSION_SECRET",
+			})
+			return
+		}
+	case "WebhookUrl":
+		err = service.CheckWebhookUrl(option.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "WebhookEvents":
+		err = service.CheckWebhookEvents(option.Value, false)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "ChannelDisableRules":
+		err = common.CheckChannelDisableRules(option.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "ChannelSelectStrategy":
+		err = common.CheckChannelSelectStrategy(option.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 	}
 	err = model.UpdateOption(option.Key, option.Value)
 	if err != nil {
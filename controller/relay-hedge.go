@@ -0,0 +1,292 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/middleware"
+	"one-api/model"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+var errHedgeLost = errors.New("hedged request lost the race")
+
+// hedgeRace 记录对冲请求中最先产生响应的一方
+type hedgeRace struct {
+	mutex  sync.Mutex
+	winner int
+	won    chan struct{}
+}
+
+func newHedgeRace() *hedgeRace {
+	return &hedgeRace{winner: -1, won: make(chan struct{})}
+}
+
+func (r *hedgeRace) claim(id int) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.winner == -1 {
+		r.winner = id
+		close(r.won)
+		return true
+	}
+	return r.winner == id
+}
+
+func (r *hedgeRace) getWinner() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.winner
+}
+
+// hedgeWriter 在第一次写出响应内容前只在本地记录响应头和状态码，
+// 第一次写出时参与竞争，胜出后才把响应写给客户端，落败时写入失败使该请求尽快结束
+type hedgeWriter struct {
+	gin.ResponseWriter
+	ctx       *gin.Context
+	race      *hedgeRace
+	id        int
+	header    http.Header
+	status    int
+	committed bool
+}
+
+func newHedgeWriter(ctx *gin.Context, writer gin.ResponseWriter, race *hedgeRace, id int) *hedgeWriter {
+	return &hedgeWriter{
+		ResponseWriter: writer,
+		ctx:            ctx,
+		race:           race,
+		id:             id,
+		header:         http.Header{},
+		status:         http.StatusOK,
+	}
+}
+
+func (w *hedgeWriter) commit() error {
+	if w.committed {
+		return nil
+	}
+	if !w.race.claim(w.id) {
+		// 落败的请求不计费，在写入时标记以免与取消信号竞争
+		w.ctx.Set("hedge_cancelled", true)
+		return errHedgeLost
+	}
+	header := w.ResponseWriter.Header()
+	for key, values := range w.header {
+		header[key] = values
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.committed = true
+	return nil
+}
+
+func (w *hedgeWriter) Header() http.Header {
+	if w.committed {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *hedgeWriter) WriteHeader(code int) {
+	if w.committed {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *hedgeWriter) WriteHeaderNow() {
+	if w.committed {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *hedgeWriter) Status() int {
+	if w.committed {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *hedgeWriter) Size() int {
+	if w.committed {
+		return w.ResponseWriter.Size()
+	}
+	return -1
+}
+
+func (w *hedgeWriter) Written() bool {
+	return w.committed && w.ResponseWriter.Written()
+}
+
+func (w *hedgeWriter) Flush() {
+	if w.committed {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *hedgeWriter) Write(data []byte) (int, error) {
+	if err := w.commit(); err != nil {
+		return 0, err
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *hedgeWriter) WriteString(s string) (int, error) {
+	if err := w.commit(); err != nil {
+		return 0, err
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+type hedgeAttempt struct {
+	channel *model.Channel
+	ctx     *gin.Context
+	cancel  context.CancelFunc
+}
+
+type hedgeResult struct {
+	id  int
+	err *dto.OpenAIErrorWithStatusCode
+}
+
+// shouldHedge 仅对配置了对冲的分组的文本生成请求启用，指定渠道时不启用
+func shouldHedge(c *gin.Context, relayMode int, group string) bool {
+	if relayMode != relayconstant.RelayModeChatCompletions && relayMode != relayconstant.RelayModeCompletions {
+		return false
+	}
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return false
+	}
+	return common.GetGroupHedgeDelay(group) > 0
+}
+
+// pickHedgeChannel 为对冲请求选择一个与首个渠道不同的渠道，没有其他可用渠道时返回 nil
+func pickHedgeChannel(group, originalModel string, limitsMap map[string]bool, excludeId int) *model.Channel {
+	for i := 0; i < 3; i++ {
+		channel, err := model.CacheGetRandomSatisfiedChannel(group, originalModel, limitsMap, 0)
+		if err != nil {
+			return nil
+		}
+		if channel.Id != excludeId {
+			return channel
+		}
+		common.ChannelBreakers.Release(channel.Id, originalModel)
+	}
+	return nil
+}
+
+// hedgedRelay 先向 channel 发送请求，若在分组配置的时间内没有产生首字，再向另一个渠道发送相同请求，
+// 使用最先产生响应的一方并取消另一方。落败的请求通过 hedge_cancelled 标记跳过计费和日志，
+// 在胜负确定前失败的请求按普通失败处理。两方都失败时返回最后一个错误，由外层继续重试
+func hedgedRelay(c *gin.Context, relayMode int, channel *model.Channel, group, originalModel string, limitsMap map[string]bool) *dto.OpenAIErrorWithStatusCode {
+	race := newHedgeRace()
+	results := make(chan hedgeResult, 2)
+	var attempts []*hedgeAttempt
+
+	start := func(ch *model.Channel) {
+		id := len(attempts)
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		cp := c.Copy()
+		// 每个请求使用独立的 Header，SetupContextForSelectedChannel 会改写 Authorization
+		cp.Request = c.Request.Clone(ctx)
+		cp.Writer = newHedgeWriter(cp, c.Writer, race, id)
+		if id > 0 {
+			middleware.SetupContextForSelectedChannel(cp, ch, originalModel)
+			common.LogInfo(c, fmt.Sprintf("no first byte within hedge delay, sending hedged request to channel #%d", ch.Id))
+		}
+		attempts = append(attempts, &hedgeAttempt{channel: ch, ctx: cp, cancel: cancel})
+		gopool.Go(func() {
+			var err *dto.OpenAIErrorWithStatusCode
+			defer func() {
+				// gopool 会吞掉 panic，这里必须保证总是返回结果，否则外层会一直等待
+				if r := recover(); r != nil {
+					common.SysError(fmt.Sprintf("panic detected in hedged request: %v", r))
+					common.SysError(fmt.Sprintf("stacktrace from panic: %s", string(debug.Stack())))
+					err = service.OpenAIErrorWrapperLocal(fmt.Errorf("panic detected: %v", r), "new_api_panic", http.StatusInternalServerError)
+				}
+				results <- hedgeResult{id: id, err: err}
+			}()
+			release, ok := acquireChannelSlot(ch, originalModel)
+			if !ok {
+				err = service.OpenAIErrorWrapperLocal(errors.New("channel is saturated"), "channel_saturated", http.StatusTooManyRequests)
+				return
+			}
+			defer release()
+			err = relayRequest(cp, relayMode, ch)
+		})
+	}
+
+	start(channel)
+	timer := time.NewTimer(common.GetGroupHedgeDelay(group))
+	defer timer.Stop()
+	won := race.won
+	running := 1
+	var lastErr *dto.OpenAIErrorWithStatusCode
+	for running > 0 {
+		select {
+		case <-timer.C:
+			if second := pickHedgeChannel(group, originalModel, limitsMap, channel.Id); second != nil {
+				start(second)
+				running++
+			}
+		case <-won:
+			won = nil
+			timer.Stop()
+			winner := race.getWinner()
+			for i, attempt := range attempts {
+				if i != winner {
+					attempt.ctx.Set("hedge_cancelled", true)
+					attempt.cancel()
+				}
+			}
+		case result := <-results:
+			running--
+			attempt := attempts[result.id]
+			winner := race.getWinner()
+			if winner != -1 && winner != result.id {
+				// 落败的请求已被取消
+				common.ChannelBreakers.Release(attempt.channel.Id, originalModel)
+				continue
+			}
+			if winner == result.id {
+				recordChannelBreaker(attempt.ctx, attempt.channel.Id, originalModel, result.err)
+				attempt.cancel()
+				c.Set("use_channel", attempt.ctx.GetStringSlice("use_channel"))
+				c.Set("channel_id", attempt.ctx.GetInt("channel_id"))
+				return result.err
+			}
+			// 胜负确定前失败
+			attempt.cancel()
+			lastErr = result.err
+			if service.IsClientCancelled(c) {
+				common.ChannelBreakers.Release(attempt.channel.Id, originalModel)
+				return lastErr
+			}
+			// 并发或 RPM 已满的错误是本地错误，同样会释放选择渠道时占用的半开探测名额
+			recordChannelBreaker(attempt.ctx, attempt.channel.Id, originalModel, result.err)
+			if !result.err.LocalError {
+				go processChannelError(attempt.ctx, attempt.channel.Id, attempt.channel.Type, attempt.channel.Name, attempt.ctx.GetInt("channel_key_id"), attempt.channel.GetAutoBan(), result.err)
+				penalizeChannel(attempt.channel, result.err)
+			}
+			if len(attempts) == 1 {
+				// 首个请求在对冲之前就失败了，交给外层按普通流程重试
+				c.Set("use_channel", attempt.ctx.GetStringSlice("use_channel"))
+				return lastErr
+			}
+		}
+	}
+	return lastErr
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
 	"io"
 	"log"
 	"net/http"
@@ -14,16 +15,23 @@ import (
 	"one-api/middleware"
 	"one-api/model"
 	"one-api/relay"
+	relaycommon "one-api/relay/common"
 	"one-api/relay/constant"
 	relayconstant "one-api/relay/constant"
 	"one-api/service"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func relayHandler(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode {
 	var err *dto.OpenAIErrorWithStatusCode
 	switch relayMode {
 	case relayconstant.RelayModeImagesGenerations:
+		fallthrough
+	case relayconstant.RelayModeImagesEdits:
+		fallthrough
+	case relayconstant.RelayModeImagesVariations:
 		err = relay.ImageHelper(c, relayMode)
 	case relayconstant.RelayModeAudioSpeech:
 		fallthrough
@@ -33,6 +41,12 @@ func relayHandler(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode
 		err = relay.AudioHelper(c)
 	case relayconstant.RelayModeRerank:
 		err = relay.RerankHelper(c, relayMode)
+	case relayconstant.RelayModeClaudeMessages:
+		err = relay.ClaudeHelper(c)
+	case relayconstant.RelayModeGemini:
+		err = relay.GeminiHelper(c)
+	case relayconstant.RelayModeResponses:
+		err = relay.ResponsesHelper(c)
 	default:
 		err = relay.TextHelper(c)
 	}
@@ -113,6 +127,22 @@ func Relay(c *gin.Context) {
 	} else {
 		tokenModelLimit = map[string]bool{}
 	}
+	startTime := time.Now()
+	channelMatched := false
+	defer func() {
+		code := c.Writer.Status()
+		if service.IsClientCancelled(c) {
+			code = 499
+		} else if openaiErr != nil {
+			code = openaiErr.StatusCode
+		}
+		// 没有匹配到渠道时模型名来自客户端，使用固定的标签避免产生无限多的时间序列
+		metricsModel := originalModel
+		if !channelMatched {
+			metricsModel = "unknown"
+		}
+		common.RecordRelayMetrics(metricsModel, c.GetInt("channel_id"), group, code, time.Since(startTime), len(c.GetStringSlice("use_channel"))-1)
+	}()
 	for i := 0; i <= common.RetryTimes; i++ {
 		channel, err := getChannel(c, group, originalModel, tokenModelLimit, i)
 		if err != nil {
@@ -120,16 +150,52 @@ func Relay(c *gin.Context) {
 			openaiErr = service.OpenAIErrorWrapperLocal(err, "get_channel_failed", http.StatusInternalServerError)
 			break
 		}
+		if channel.Id != 0 {
+			channelMatched = true
+		}
 
-		openaiErr = relayRequest(c, relayMode, channel)
+		release, ok := acquireChannelSlot(channel, originalModel)
+		if !ok {
+			// 渠道并发或 RPM 已满，换一个渠道重试，不计入渠道失败
+			openaiErr = service.OpenAIErrorWrapperLocal(fmt.Errorf("channel #%d is saturated", channel.Id), "channel_saturated", http.StatusTooManyRequests)
+			// 选择渠道时可能占用了熔断器的半开探测名额
+			common.ChannelBreakers.Release(channel.Id, originalModel)
+			if _, ok := c.Get("specific_channel_id"); ok {
+				break
+			}
+			continue
+		}
+		if i == 0 && shouldHedge(c, relayMode, group) {
+			// 对冲请求自行占用渠道额度并记录各渠道的失败
+			release()
+			openaiErr = hedgedRelay(c, relayMode, channel, group, originalModel, tokenModelLimit)
+			if openaiErr == nil || service.IsClientCancelled(c) {
+				return
+			}
+			if !shouldRetry(c, openaiErr, common.RetryTimes-i) {
+				break
+			}
+			continue
+		}
+		openaiErr = func() *dto.OpenAIErrorWithStatusCode {
+			// panic 被 RelayPanicRecover 恢复时也要释放渠道额度
+			defer release()
+			return relayRequest(c, relayMode, channel)
+		}()
+		if openaiErr != nil && service.IsClientCancelled(c) {
+			// 客户端已断开，错误来自被取消的上游请求，不计入渠道失败也不再重试
+			common.ChannelBreakers.Release(channel.Id, originalModel)
+			common.LogInfo(c, "client cancelled, stop relaying")
+			return
+		}
+		recordChannelBreaker(c, channel.Id, originalModel, openaiErr)
 
 		if openaiErr == nil {
 			return // 成功处理请求，直接返回
 		}
 
-		go processChannelError(c, channel.Id, channel.Type, channel.Name, channel.GetAutoBan(), openaiErr)
-
-		common.ChannelWeights.RecordFailure(channel.Id)
+		go processChannelError(c, channel.Id, channel.Type, channel.Name, c.GetInt("channel_key_id"), channel.GetAutoBan(), openaiErr)
+		penalizeChannel(channel, openaiErr)
 
 		if !shouldRetry(c, openaiErr, common.RetryTimes-i) {
 			break
@@ -142,10 +208,38 @@ func Relay(c *gin.Context) {
 	}
 
 	if openaiErr != nil {
+		// 先用上游的原始错误信息记录日志，规则试运行会回放这些日志
+		recordRelayErrorLog(c, originalModel, openaiErr, startTime)
+		if c.Writer.Written() {
+			// 错误已经在响应流中告知客户端，不再追加错误体
+			return
+		}
 		if openaiErr.StatusCode == http.StatusTooManyRequests {
 			openaiErr.Error.Message = "当前分组上游负载已饱和，请稍后再试"
 		}
 		openaiErr.Error.Message = common.MessageWithRequestId(openaiErr.Error.Message, requestId)
+		if relayMode == relayconstant.RelayModeClaudeMessages {
+			// /v1/messages 使用 Anthropic 的错误格式
+			c.JSON(openaiErr.StatusCode, gin.H{
+				"type": "error",
+				"error": gin.H{
+					"type":    openaiErr.Error.Type,
+					"message": openaiErr.Error.Message,
+				},
+			})
+			return
+		}
+		if relayMode == relayconstant.RelayModeGemini {
+			// gemini 使用 google api 的错误格式
+			c.JSON(openaiErr.StatusCode, gin.H{
+				"error": gin.H{
+					"code":    openaiErr.StatusCode,
+					"message": openaiErr.Error.Message,
+					"status":  openaiErr.Error.Type,
+				},
+			})
+			return
+		}
 		c.JSON(openaiErr.StatusCode, gin.H{
 			"error": openaiErr.Error,
 		})
@@ -176,6 +270,7 @@ func WssRelay(c *gin.Context) {
 	group := c.GetString("group")
 	//wss://api.openai.com/v1/realtime?model=gpt-4o-realtime-preview-2024-10-01
 	originalModel := c.GetString("original_model")
+	startTime := time.Now()
 	var openaiErr *dto.OpenAIErrorWithStatusCode
 	var tokenModelLimit map[string]bool
 	s, ok := c.Get("token_model_limit")
@@ -192,14 +287,28 @@ func WssRelay(c *gin.Context) {
 			break
 		}
 
-		openaiErr = wssRequest(c, ws, relayMode, channel)
+		release, ok := acquireChannelSlot(channel, originalModel)
+		if !ok {
+			openaiErr = service.OpenAIErrorWrapperLocal(fmt.Errorf("channel #%d is saturated", channel.Id), "channel_saturated", http.StatusTooManyRequests)
+			// 选择渠道时可能占用了熔断器的半开探测名额
+			common.ChannelBreakers.Release(channel.Id, originalModel)
+			if _, ok := c.Get("specific_channel_id"); ok {
+				break
+			}
+			continue
+		}
+		openaiErr = func() *dto.OpenAIErrorWithStatusCode {
+			defer release()
+			return wssRequest(c, ws, relayMode, channel)
+		}()
+		recordChannelBreaker(c, channel.Id, originalModel, openaiErr)
 
 		if openaiErr == nil {
 			return // 成功处理请求，直接返回
 		}
 
-		go processChannelError(c, channel.Id, channel.Type, channel.Name, channel.GetAutoBan(), openaiErr)
-		common.ChannelWeights.RecordFailure(channel.Id)
+		go processChannelError(c, channel.Id, channel.Type, channel.Name, c.GetInt("channel_key_id"), channel.GetAutoBan(), openaiErr)
+		penalizeChannel(channel, openaiErr)
 		if !shouldRetry(c, openaiErr, common.RetryTimes-i) {
 			break
 		}
@@ -211,6 +320,8 @@ func WssRelay(c *gin.Context) {
 	}
 
 	if openaiErr != nil {
+		// 先用上游的原始错误信息记录日志，规则试运行会回放这些日志
+		recordRelayErrorLog(c, originalModel, openaiErr, startTime)
 		if openaiErr.StatusCode == http.StatusTooManyRequests {
 			openaiErr.Error.Message = "当前分组上游负载已饱和，请稍后再试"
 		}
@@ -221,16 +332,88 @@ func WssRelay(c *gin.Context) {
 
 func relayRequest(c *gin.Context, relayMode int, channel *model.Channel) *dto.OpenAIErrorWithStatusCode {
 	addUsedChannel(c, channel.Id)
+	span, endSpan := common.StartRequestSpan(c, "relay.attempt",
+		attribute.Int("attempt", len(c.GetStringSlice("use_channel"))),
+		attribute.Int("channel.id", channel.Id),
+		attribute.Int("channel.type", channel.Type),
+		attribute.String("model", c.GetString("original_model")),
+	)
+	defer endSpan()
 	requestBody, _ := common.GetRequestBody(c)
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
-	return relayHandler(c, relayMode)
+	startTime := time.Now()
+	common.ChannelStats.IncInFlight(channel.Id)
+	defer common.ChannelStats.DecInFlight(channel.Id)
+	inFlight := common.MetricRelayInFlight.WithLabelValues(strconv.Itoa(channel.Id))
+	inFlight.Inc()
+	defer inFlight.Dec()
+	openaiErr := relayHandler(c, relayMode)
+	recordChannelKeyResult(c, openaiErr)
+	if openaiErr != nil {
+		span.SetAttributes(attribute.Int("error.status_code", openaiErr.StatusCode))
+		common.SetSpanError(span, errors.New(openaiErr.Error.Message))
+	}
+	if c.GetBool("response_cache_hit") || c.GetBool("hedge_cancelled") {
+		return openaiErr
+	}
+	originalModel := c.GetString("original_model")
+	if openaiErr == nil {
+		if info, ok := c.Get(relaycommon.RelayInfoKey); ok {
+			latency := info.(*relaycommon.RelayInfo).GetFirstResponseLatency()
+			common.ChannelStats.RecordLatency(channel.Id, originalModel, latency)
+			common.RecordUpstreamMetrics(originalModel, channel.Id, http.StatusOK, latency)
+		}
+	} else if !openaiErr.LocalError {
+		common.RecordUpstreamMetrics(originalModel, channel.Id, openaiErr.StatusCode, 0)
+		if isChannelHealthError(openaiErr) && !service.IsClientCancelled(c) {
+			common.ChannelStats.RecordFailure(channel.Id, originalModel, time.Since(startTime))
+		}
+	}
+	return openaiErr
 }
 
 func wssRequest(c *gin.Context, ws *websocket.Conn, relayMode int, channel *model.Channel) *dto.OpenAIErrorWithStatusCode {
 	addUsedChannel(c, channel.Id)
 	requestBody, _ := common.GetRequestBody(c)
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
-	return relay.WssHelper(c, ws)
+	common.ChannelStats.IncInFlight(channel.Id)
+	defer common.ChannelStats.DecInFlight(channel.Id)
+	openaiErr := relay.WssHelper(c, ws)
+	recordChannelKeyResult(c, openaiErr)
+	return openaiErr
+}
+
+// acquireChannelSlot 占用渠道的并发和模型 RPM 额度，重试时的渠道来自 getChannel 构造，需要从缓存中取完整的渠道配置
+func acquireChannelSlot(channel *model.Channel, modelName string) (func(), bool) {
+	fullChannel, err := model.CacheGetChannel(channel.Id)
+	if err != nil {
+		return func() {}, true
+	}
+	return fullChannel.AcquireSlot(modelName)
+}
+
+// recordChannelBreaker 将上游请求结果计入渠道+模型的熔断器，本地错误、缓存命中和调用方引起的错误不计入
+func recordChannelBreaker(c *gin.Context, channelId int, modelName string, openaiErr *dto.OpenAIErrorWithStatusCode) {
+	if c.GetBool("response_cache_hit") {
+		// 命中响应缓存，没有请求上游
+		common.ChannelBreakers.Release(channelId, modelName)
+	} else if openaiErr == nil {
+		common.ChannelBreakers.RecordSuccess(channelId, modelName)
+	} else if openaiErr.LocalError || !isChannelHealthError(openaiErr) {
+		common.ChannelBreakers.Release(channelId, modelName)
+	} else {
+		common.ChannelBreakers.RecordFailure(channelId, modelName)
+	}
+}
+
+// isChannelHealthError 错误是否说明渠道本身有问题：5xx、超时、连接错误和鉴权失败；
+// 其他 4xx（如 400、413、422、429）通常由调用方的请求引起，不应让一个用户的错误请求熔断所有人共用的渠道
+func isChannelHealthError(openaiErr *dto.OpenAIErrorWithStatusCode) bool {
+	code := openaiErr.StatusCode
+	if code >= 400 && code < 500 {
+		return code == http.StatusUnauthorized || code == http.StatusForbidden || code == http.StatusRequestTimeout
+	}
+	return true
 }
 
 func addUsedChannel(c *gin.Context, channelId int) {
@@ -268,6 +451,10 @@ func shouldRetry(c *gin.Context, openaiErr *dto.OpenAIErrorWithStatusCode, retry
 	if openaiErr.LocalError {
 		return false
 	}
+	if c.Writer.Written() {
+		// 响应已经开始写给客户端（如流式响应中途出错），无法换渠道重试
+		return false
+	}
 	if retryTimes <= 0 {
 		return false
 	}
@@ -304,13 +491,64 @@ func shouldRetry(c *gin.Context, openaiErr *dto.OpenAIErrorWithStatusCode, retry
 	return true
 }
 
-func processChannelError(c *gin.Context, channelId int, channelType int, channelName string, autoBan bool, err *dto.OpenAIErrorWithStatusCode) {
+// recordRelayErrorLog 所有重试都失败后记录错误日志，客户端主动断开的请求不记录
+func recordRelayErrorLog(c *gin.Context, originalModel string, openaiErr *dto.OpenAIErrorWithStatusCode, startTime time.Time) {
+	isStream := false
+	if info, ok := c.Get(relaycommon.RelayInfoKey); ok {
+		isStream = info.(*relaycommon.RelayInfo).IsStream
+	}
+	other := map[string]interface{}{
+		"error_type":   openaiErr.Error.Type,
+		"error_code":   openaiErr.Error.Code,
+		"channel_type": c.GetInt("channel_type"),
+	}
+	if openaiErr.LocalError {
+		other["local_error"] = true
+	}
+	model.RecordErrorLog(c, c.GetInt("id"), c.GetInt("channel_id"), originalModel, c.GetString("token_name"), c.GetInt("token_id"),
+		openaiErr.StatusCode, openaiErr.Error.Message, int(time.Since(startTime).Seconds()), isStream, other)
+}
+
+// penalizeChannel 降低出错渠道的权重，规则动作为 ignore 的错误不降权
+func penalizeChannel(channel *model.Channel, err *dto.OpenAIErrorWithStatusCode) {
+	if service.ChannelErrorAction(channel.Type, err) != common.ChannelRuleActionIgnore {
+		common.ChannelWeights.RecordFailure(channel.Id)
+	}
+}
+
+// recordChannelKeyResult 记录本次请求使用的渠道 Key 的调用结果，被取消的请求和本地错误不计入
+func recordChannelKeyResult(c *gin.Context, openaiErr *dto.OpenAIErrorWithStatusCode) {
+	if c.GetBool("response_cache_hit") || c.GetBool("hedge_cancelled") || service.IsClientCancelled(c) {
+		return
+	}
+	if openaiErr == nil {
+		model.RecordChannelKeyResult(c.GetInt("channel_key_id"), "")
+	} else if !openaiErr.LocalError {
+		model.RecordChannelKeyResult(c.GetInt("channel_key_id"), openaiErr.Error.Message)
+	}
+}
+
+// processChannelError keyId 为本次请求使用的 Key 池中的 Key，为 0 表示使用渠道自身的 Key；
+// 使用 Key 池时只禁用出错的 Key，Key 池中没有启用的 Key 后才禁用渠道
+func processChannelError(c *gin.Context, channelId int, channelType int, channelName string, keyId int, autoBan bool, err *dto.OpenAIErrorWithStatusCode) {
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
 	// do not use context to get channel info, there may be inconsistent channel info when processing asynchronously
 	common.LogError(c, fmt.Sprintf("relay error (channel #%d, status code: %d): %s", channelId, err.StatusCode, err.Error.Message))
-	if service.ShouldDisableChannel(channelType, err) && autoBan {
-		service.DisableChannel(channelId, channelName, err.Error.Message)
+	if !service.ShouldDisableChannel(channelType, err) || !autoBan {
+		return
+	}
+	if keyId != 0 {
+		remaining, keyErr := model.UpdateChannelKeyStatus(channelId, keyId, common.ChannelStatusAutoDisabled, err.Error.Message)
+		if keyErr != nil {
+			common.SysError(fmt.Sprintf("failed to disable key #%d of channel #%d: %s", keyId, channelId, keyErr.Error()))
+			return
+		}
+		common.SysLog(fmt.Sprintf("key #%d of channel #%d has been disabled, %d enabled keys left, reason: %s", keyId, channelId, remaining, err.Error.Message))
+		if remaining > 0 {
+			return
+		}
 	}
+	service.DisableChannel(channelId, channelName, err.Error.Message, c.GetString("original_model"))
 }
 
 func RelayMidjourney(c *gin.Context) {
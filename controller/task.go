@@ -74,6 +74,8 @@ func UpdateTaskByPlatform(platform constant.TaskPlatform, taskChannelM map[int][
 		//_ = UpdateMidjourneyTaskAll(context.Background(), tasks)
 	case constant.TaskPlatformSuno:
 		_ = UpdateSunoTaskAll(context.Background(), taskChannelM, taskM)
+	case constant.TaskPlatformBatch:
+		_ = UpdateBatchTaskAll(context.Background(), taskM)
 	default:
 		common.SysLog("未知平台")
 	}
@@ -153,6 +155,7 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 		if responseItem.FailReason != "" || task.Status == model.TaskStatusFailure {
 			common.LogInfo(ctx, task.TaskID+" 构建失败，"+task.FailReason)
 			task.Progress = "100%"
+			publishTaskFailedEvent(task)
 			err = model.CacheUpdateUserQuota(task.UserId)
 			if err != nil {
 				common.LogError(ctx, "error update user quota cache: "+err.Error())
@@ -181,6 +184,16 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 	return nil
 }
 
+func publishTaskFailedEvent(task *model.Task) {
+	common.PublishEvent(common.EventTaskFailed, task.UserId, map[string]any{
+		"task_id":     task.TaskID,
+		"platform":    task.Platform,
+		"action":      task.Action,
+		"fail_reason": task.FailReason,
+		"quota":       task.Quota,
+	})
+}
+
 func checkTaskNeedUpdate(oldTask *model.Task, newTask dto.SunoDataResponse) bool {
 
 	if oldTask.SubmitTime != newTask.SubmitTime {
@@ -145,6 +145,9 @@ func AddToken(c *gin.Context) {
 		ModelLimits:        token.ModelLimits,
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
+		RpmLimit:           token.RpmLimit,
+		TpmLimit:           token.TpmLimit,
+		ResponseCache:      token.ResponseCache,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -234,6 +237,9 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.ModelLimits = token.ModelLimits
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
+		cleanToken.RpmLimit = token.RpmLimit
+		cleanToken.TpmLimit = token.TpmLimit
+		cleanToken.ResponseCache = token.ResponseCache
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -250,3 +256,40 @@ func UpdateToken(c *gin.Context) {
 	})
 	return
 }
+
+type UpdateTokenAuditLogRequest struct {
+	Id       int  `json:"id"`
+	AuditLog bool `json:"audit_log"`
+}
+
+// UpdateTokenAuditLog 管理员开启或关闭令牌的审计日志，用户自己无法修改
+func UpdateTokenAuditLog(c *gin.Context) {
+	var req UpdateTokenAuditLogRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil || req.Id == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	if req.AuditLog && !common.CryptoSecretConfigured() {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "开启审计日志前请先设置 CRYPTO_SECRET 或 SESSION_SECRET",
+		})
+		return
+	}
+	err = model.UpdateTokenAuditLog(req.Id, req.AuditLog)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
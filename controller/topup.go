@@ -218,6 +218,12 @@ func EpayNotify(c *gin.Context) {
 			}
 			log.Printf("易支付回调更新用户成功 %v", topUp)
 			model.RecordLog(topUp.UserId, model.LogTypeTopup, fmt.Sprintf("使用在线充值成功，充值金额: %v，支付金额：%f", common.LogQuota(topUp.Amount*int(common.QuotaPerUnit)), topUp.Money))
+			common.PublishEvent(common.EventTopUpCompleted, topUp.UserId, map[string]any{
+				"method":   "epay",
+				"trade_no": topUp.TradeNo,
+				"quota":    topUp.Amount * int(common.QuotaPerUnit),
+				"money":    topUp.Money,
+			})
 		}
 	} else {
 		log.Printf("易支付异常回调: %v", verifyInfo)
@@ -2,10 +2,12 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"one-api/common"
 	"one-api/model"
+	"one-api/service"
 	"strconv"
 	"strings"
 	"sync"
@@ -441,6 +443,70 @@ func GetSelf(c *gin.Context) {
 	return
 }
 
+func GetSelfWebhook(c *gin.Context) {
+	webhookUrl, secret, events, err := model.GetUserWebhook(c.GetInt("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"webhook_url":      webhookUrl,
+			"webhook_events":   events,
+			"has_secret":       secret != "",
+			"available_events": common.UserEventTypes,
+		},
+	})
+}
+
+type UpdateWebhookRequest struct {
+	WebhookUrl    string   `json:"webhook_url"`
+	WebhookSecret *string  `json:"webhook_secret"` // 不传时保留原密钥，传空字符串时清除
+	WebhookEvents []string `json:"webhook_events"` // 为空时订阅全部用户事件
+}
+
+func UpdateSelfWebhook(c *gin.Context) {
+	var req UpdateWebhookRequest
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	events := strings.Join(req.WebhookEvents, ",")
+	if len(req.WebhookUrl) > 512 || len(events) > 512 {
+		err = errors.New("Webhook 地址或事件列表过长")
+	} else if err = service.CheckUserWebhookUrl(req.WebhookUrl); err == nil {
+		err = service.CheckWebhookEvents(events, true)
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	err = model.UpdateUserWebhook(c.GetInt("id"), req.WebhookUrl, req.WebhookSecret, events)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
 func GetUserModels(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
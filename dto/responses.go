@@ -0,0 +1,145 @@
+package dto
+
+import "encoding/json"
+
+// ResponsesRequest /v1/responses 请求
+type ResponsesRequest struct {
+	Model              string          `json:"model"`
+	Input              json.RawMessage `json:"input,omitempty"`
+	Instructions       string          `json:"instructions,omitempty"`
+	PreviousResponseId string          `json:"previous_response_id,omitempty"`
+	Store              *bool           `json:"store,omitempty"`
+	Stream             bool            `json:"stream,omitempty"`
+	MaxOutputTokens    uint            `json:"max_output_tokens,omitempty"`
+	Temperature        float64         `json:"temperature,omitempty"`
+	TopP               float64         `json:"top_p,omitempty"`
+	Tools              []ResponsesTool `json:"tools,omitempty"`
+	ToolChoice         any             `json:"tool_choice,omitempty"`
+	Text               *ResponsesText  `json:"text,omitempty"`
+	Reasoning          *struct {
+		Effort string `json:"effort,omitempty"`
+	} `json:"reasoning,omitempty"`
+	User     string `json:"user,omitempty"`
+	Metadata any    `json:"metadata,omitempty"`
+}
+
+// ShouldStore 是否需要保存本次响应，未指定时默认保存
+func (r ResponsesRequest) ShouldStore() bool {
+	return r.Store == nil || *r.Store
+}
+
+// ParseInput 将 input 统一解析为 item 列表，字符串输入视为一条用户消息
+func (r ResponsesRequest) ParseInput() ([]ResponsesItem, error) {
+	if len(r.Input) == 0 {
+		return nil, nil
+	}
+	var stringInput string
+	if err := json.Unmarshal(r.Input, &stringInput); err == nil {
+		content, _ := json.Marshal(stringInput)
+		return []ResponsesItem{{Type: "message", Role: "user", Content: content}}, nil
+	}
+	var items []ResponsesItem
+	err := json.Unmarshal(r.Input, &items)
+	return items, err
+}
+
+type ResponsesTool struct {
+	Type        string `json:"type"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+	Strict      any    `json:"strict,omitempty"`
+}
+
+type ResponsesText struct {
+	Format *struct {
+		Type   string `json:"type"`
+		Name   string `json:"name,omitempty"`
+		Schema any    `json:"schema,omitempty"`
+		Strict any    `json:"strict,omitempty"`
+	} `json:"format,omitempty"`
+}
+
+// ResponsesItem 输入输出共用的 item：message、function_call、function_call_output 等
+type ResponsesItem struct {
+	Type    string          `json:"type,omitempty"`
+	Id      string          `json:"id,omitempty"`
+	Status  string          `json:"status,omitempty"`
+	Role    string          `json:"role,omitempty"`
+	Content json.RawMessage `json:"content,omitempty"`
+	// function_call / function_call_output
+	CallId    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	Output    string `json:"output,omitempty"`
+}
+
+// ResponsesContent message item 中的内容
+type ResponsesContent struct {
+	Type        string `json:"type"`
+	Text        string `json:"text,omitempty"`
+	ImageUrl    string `json:"image_url,omitempty"`
+	Annotations any    `json:"annotations,omitempty"`
+}
+
+// ParseContent 解析 message item 的内容，字符串内容视为一段文本
+func (i ResponsesItem) ParseContent() []ResponsesContent {
+	var stringContent string
+	if err := json.Unmarshal(i.Content, &stringContent); err == nil {
+		return []ResponsesContent{{Type: "input_text", Text: stringContent}}
+	}
+	var contents []ResponsesContent
+	_ = json.Unmarshal(i.Content, &contents)
+	return contents
+}
+
+type ResponsesUsage struct {
+	InputTokens        int `json:"input_tokens"`
+	OutputTokens       int `json:"output_tokens"`
+	TotalTokens        int `json:"total_tokens"`
+	InputTokensDetails *struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"input_tokens_details,omitempty"`
+}
+
+// ToUsage 转换为计费使用的 Usage
+func (u ResponsesUsage) ToUsage() *Usage {
+	usage := &Usage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+	if u.InputTokensDetails != nil {
+		usage.PromptTokensDetails.CachedTokens = u.InputTokensDetails.CachedTokens
+	}
+	return usage
+}
+
+// ResponsesResponse /v1/responses 响应
+type ResponsesResponse struct {
+	Id                 string          `json:"id"`
+	Object             string          `json:"object"`
+	CreatedAt          int64           `json:"created_at"`
+	Status             string          `json:"status"`
+	Model              string          `json:"model"`
+	Output             []ResponsesItem `json:"output"`
+	PreviousResponseId *string         `json:"previous_response_id"`
+	IncompleteDetails  any             `json:"incomplete_details"`
+	Error              any             `json:"error"`
+	Usage              *ResponsesUsage `json:"usage,omitempty"`
+}
+
+// ResponsesStreamEvent /v1/responses 流式事件
+type ResponsesStreamEvent struct {
+	Type           string             `json:"type"`
+	SequenceNumber int                `json:"sequence_number"`
+	Response       *ResponsesResponse `json:"response,omitempty"`
+	OutputIndex    *int               `json:"output_index,omitempty"`
+	ContentIndex   *int               `json:"content_index,omitempty"`
+	ItemId         string             `json:"item_id,omitempty"`
+	Item           *ResponsesItem     `json:"item,omitempty"`
+	Part           *ResponsesContent  `json:"part,omitempty"`
+	Delta          string             `json:"delta,omitempty"`
+	Text           *string            `json:"text,omitempty"`
+	Arguments      *string            `json:"arguments,omitempty"`
+}
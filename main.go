@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"fmt"
 	"log"
@@ -69,6 +70,11 @@ func main() {
 
 	// Initialize constants
 	constant.InitEnv()
+	shutdownTracer, err := common.InitTracer()
+	if err != nil {
+		common.FatalLog("failed to initialize tracer: " + err.Error())
+	}
+	defer shutdownTracer(context.Background())
 	// Initialize options
 	model.InitOptionMap()
 	if common.RedisEnabled {
@@ -88,6 +94,9 @@ func main() {
 		go model.SyncChannelCache(common.SyncFrequency)
 	}
 
+	// 运维事件 webhook 通知
+	service.InitWebhook()
+
 	// 数据看板
 	go model.UpdateQuotaData()
 
@@ -113,6 +122,11 @@ func main() {
 			controller.UpdateTaskBulk()
 		})
 	}
+	if common.IsMasterNode {
+		go model.PurgeAuditLogs(constant.AuditLogRetentionDays)
+		go model.PurgeResponses(constant.ResponsesRetentionDays)
+		go controller.AutomaticallyRecoverChannels()
+	}
 	if os.Getenv("BATCH_UPDATE_ENABLED") == "true" {
 		common.BatchUpdateEnabled = true
 		common.SysLog("batch update enabled with interval " + strconv.Itoa(common.BatchUpdateInterval) + "s")
@@ -143,6 +157,7 @@ func main() {
 	// This will cause SSE not to work!!!
 	//server.Use(gzip.Gzip(gzip.DefaultCompression))
 	server.Use(middleware.RequestId())
+	server.Use(middleware.Tracing())
 	middleware.SetUpLogger(server)
 	// Initialize session store
 	store := cookie.NewStore([]byte(common.SessionSecret))
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"bytes"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// auditLogWriter 在写给客户端的同时保存响应内容，超过 limit 的部分不保存
+type auditLogWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (w *auditLogWriter) capture(data []byte) {
+	remain := w.limit - w.body.Len()
+	if remain < len(data) {
+		w.truncated = true
+		if remain <= 0 {
+			return
+		}
+		data = data[:remain]
+	}
+	w.body.Write(data)
+}
+
+func (w *auditLogWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *auditLogWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func truncateAuditBody(data []byte, limit int) []byte {
+	if len(data) > limit {
+		return data[:limit]
+	}
+	return data
+}
+
+// AuditLog 令牌或分组开启审计时，记录完整的请求体和返回给客户端的响应，需要放在 Distribute 之后
+func AuditLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !common.CryptoSecretConfigured() {
+			// 没有固定的加密密钥时无法保存可解密的审计日志，启动时已输出警告
+			c.Next()
+			return
+		}
+		if !c.GetBool("token_audit_log") && !constant.IsAuditLogGroup(c.GetString("group")) {
+			c.Next()
+			return
+		}
+		limit := constant.AuditLogMaxBytes * 1024
+		writer := &auditLogWriter{ResponseWriter: c.Writer, limit: limit}
+		c.Writer = writer
+		c.Next()
+		if c.Writer == writer {
+			c.Writer = writer.ResponseWriter
+		}
+
+		requestBody, err := common.GetRequestBody(c)
+		if err != nil {
+			common.LogError(c, "failed to read request body for audit log: "+err.Error())
+		}
+		log := &model.AuditLog{
+			RequestId:  c.GetString(common.RequestIdKey),
+			UserId:     c.GetInt("id"),
+			TokenId:    c.GetInt("token_id"),
+			ModelName:  c.GetString("original_model"),
+			Path:       c.Request.URL.Path,
+			StatusCode: writer.Status(),
+		}
+		request := truncateAuditBody(requestBody, limit)
+		response := writer.body.Bytes()
+		if writer.truncated || len(request) < len(requestBody) {
+			common.LogWarn(c, "audit log body exceeds AUDIT_LOG_MAX_BYTES, truncated")
+		}
+		gopool.Go(func() {
+			if err := model.RecordAuditLog(log, request, response); err != nil {
+				common.SysError("failed to record audit log: " + err.Error())
+			}
+		})
+	}
+}
@@ -160,7 +160,7 @@ func WssAuth(c *gin.Context) {
 }
 
 func TokenAuth() func(c *gin.Context) {
-	return func(c *gin.Context) {
+	return withSpan("TokenAuth", func(c *gin.Context) {
 		// 先检测是否为ws
 		if c.Request.Header.Get("Sec-WebSocket-Protocol") != "" {
 			// Sec-WebSocket-Protocol: realtime, openai-insecure-api-key.sk-xxx, openai-beta.realtime-v1
@@ -176,6 +176,24 @@ func TokenAuth() func(c *gin.Context) {
 			}
 			c.Request.Header.Set("Authorization", "Bearer "+key)
 		}
+		// anthropic sdk 使用 x-api-key 传递密钥
+		if c.Request.Header.Get("Authorization") == "" && c.Request.Header.Get("x-api-key") != "" {
+			c.Request.Header.Set("Authorization", "Bearer "+c.Request.Header.Get("x-api-key"))
+		}
+		// google genai sdk 使用 x-goog-api-key 或 ?key= 传递密钥
+		if c.Request.Header.Get("Authorization") == "" && strings.HasPrefix(c.Request.URL.Path, "/v1beta/") {
+			key := c.Request.Header.Get("x-goog-api-key")
+			if key == "" {
+				key = c.Query("key")
+				// 读取后从 URL 中移除，避免密钥出现在后续记录的请求地址中
+				query := c.Request.URL.Query()
+				query.Del("key")
+				c.Request.URL.RawQuery = query.Encode()
+			}
+			if key != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+key)
+			}
+		}
 		key := c.Request.Header.Get("Authorization")
 		parts := make([]string, 0)
 		key = strings.TrimPrefix(key, "Bearer ")
@@ -226,6 +244,10 @@ func TokenAuth() func(c *gin.Context) {
 		}
 		c.Set("allow_ips", token.GetIpLimitsMap())
 		c.Set("token_group", token.Group)
+		c.Set("token_rpm_limit", token.RpmLimit)
+		c.Set("token_tpm_limit", token.TpmLimit)
+		c.Set("token_response_cache", token.ResponseCache)
+		c.Set("token_audit_log", token.AuditLog)
 		if len(parts) > 1 {
 			if model.IsAdmin(token.UserId) {
 				c.Set("specific_channel_id", parts[1])
@@ -234,6 +256,5 @@ func TokenAuth() func(c *gin.Context) {
 				return
 			}
 		}
-		c.Next()
-	}
+	})
 }
@@ -14,6 +14,8 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ModelRequest struct {
@@ -21,7 +23,7 @@ type ModelRequest struct {
 }
 
 func Distribute() func(c *gin.Context) {
-	return func(c *gin.Context) {
+	return withSpan("Distribute", func(c *gin.Context) {
 		allowIpsMap := c.GetStringMap("allow_ips")
 		if len(allowIpsMap) != 0 {
 			clientIp := c.ClientIP()
@@ -124,8 +126,12 @@ func Distribute() func(c *gin.Context) {
 			}
 		}
 		SetupContextForSelectedChannel(c, channel, modelRequest.Model)
-		c.Next()
-	}
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(
+			attribute.String("group", userGroup),
+			attribute.String("model", modelRequest.Model),
+			attribute.Int("channel.id", c.GetInt("channel_id")),
+		)
+	})
 }
 
 func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
@@ -190,6 +196,10 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 			modelRequest.Model = "text-moderation-stable"
 		}
 	}
+	if strings.HasPrefix(c.Request.URL.Path, "/v1beta/models/") {
+		// gemini 的模型在路径中指定: /v1beta/models/gemini-pro:generateContent
+		modelRequest.Model, _ = relayconstant.Path2GeminiModelAction(c.Request.URL.Path)
+	}
 	if strings.HasSuffix(c.Request.URL.Path, "embeddings") {
 		if modelRequest.Model == "" {
 			modelRequest.Model = c.Param("model")
@@ -198,6 +208,11 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/images/generations") {
 		modelRequest.Model = common.GetStringIfEmpty(modelRequest.Model, "dall-e")
 	}
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/images/edits") || strings.HasPrefix(c.Request.URL.Path, "/v1/images/variations") {
+		// multipart 请求，模型在表单中指定
+		modelRequest.Model = common.GetStringIfEmpty(modelRequest.Model, c.PostForm("model"))
+		modelRequest.Model = common.GetStringIfEmpty(modelRequest.Model, "dall-e-2")
+	}
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/audio") {
 		relayMode := relayconstant.RelayModeAudioSpeech
 		if strings.HasPrefix(c.Request.URL.Path, "/v1/audio/speech") {
@@ -230,8 +245,15 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 	c.Set("auto_ban", channel.GetAutoBan())
 	c.Set("model_mapping", channel.GetModelMapping())
 	c.Set("status_code_mapping", channel.GetStatusCodeMapping())
-	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
+	key, keyId, ok := channel.SelectKey()
+	if !ok {
+		// Key 池中的 Key 已全部禁用，渠道即将被禁用，请求会以空 Key 失败而不是使用已知无效的 Key
+		common.SysError(fmt.Sprintf("channel #%d has no enabled keys in its key pool", channel.Id))
+	}
+	c.Set("channel_key_id", keyId)
+	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
 	c.Set("base_url", channel.GetBaseURL())
+	c.Set("transport_setting", channel.GetTransportSetting())
 	// TODO: api_version统一
 	switch channel.Type {
 	case common.ChannelTypeAzure:
@@ -1,16 +1,81 @@
 package middleware
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/url"
 	"one-api/common"
+	"strings"
+	"time"
 )
 
+// accessLog JSON 格式的访问日志
+type accessLog struct {
+	Time      string  `json:"time"`
+	Level     string  `json:"level"`
+	Msg       string  `json:"msg"`
+	RequestId string  `json:"request_id,omitempty"`
+	Status    int     `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	ClientIp  string  `json:"client_ip"`
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	UserId    int     `json:"user_id,omitempty"`
+	ChannelId int     `json:"channel_id,omitempty"`
+	Model     string  `json:"model,omitempty"`
+}
+
+// redactPath 隐藏请求地址中的 ?key= 参数（google genai sdk 用它传递令牌）
+// gin 在请求开始前就记下了原始地址，因此不能依赖鉴权中间件从 URL 中移除
+func redactPath(path string) string {
+	i := strings.IndexByte(path, '?')
+	if i < 0 {
+		return path
+	}
+	query, err := url.ParseQuery(path[i+1:])
+	if err != nil {
+		return path[:i]
+	}
+	if !query.Has("key") {
+		return path
+	}
+	query.Set("key", "REDACTED")
+	return path[:i+1] + query.Encode()
+}
+
 func SetUpLogger(server *gin.Engine) {
 	server.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		if !common.LogLevelEnabled(slog.LevelInfo) {
+			return ""
+		}
 		var requestID string
 		if param.Keys != nil {
-			requestID = param.Keys[common.RequestIdKey].(string)
+			requestID, _ = param.Keys[common.RequestIdKey].(string)
+		}
+		if common.LogJSONEnabled() {
+			entry := accessLog{
+				Time:      param.TimeStamp.Format(time.RFC3339Nano),
+				Level:     "INFO",
+				Msg:       "access",
+				RequestId: requestID,
+				Status:    param.StatusCode,
+				LatencyMs: float64(param.Latency.Microseconds()) / 1000,
+				ClientIp:  param.ClientIP,
+				Method:    param.Method,
+				Path:      redactPath(param.Path),
+			}
+			if param.Keys != nil {
+				entry.UserId, _ = param.Keys["id"].(int)
+				entry.ChannelId, _ = param.Keys["channel_id"].(int)
+				entry.Model, _ = param.Keys["original_model"].(string)
+			}
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return ""
+			}
+			return string(data) + "\n"
 		}
 		return fmt.Sprintf("[GIN] %s | %s | %3d | %13v | %15s | %7s %s\n",
 			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
@@ -19,7 +84,7 @@ func SetUpLogger(server *gin.Engine) {
 			param.Latency,
 			param.ClientIP,
 			param.Method,
-			param.Path,
+			redactPath(param.Path),
 		)
 	}))
 }
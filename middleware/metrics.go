@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsAuth 配置了 METRICS_TOKEN 时校验 Bearer token，否则要求 root 权限
+func MetricsAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if constant.MetricsToken == "" {
+			// 没有配置抓取令牌时不允许匿名访问，需要 root 用户的登录状态或访问令牌
+			authHelper(c, common.RoleRootUser)
+			return
+		}
+		token := strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(constant.MetricsToken)) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type usageRateLimit struct {
+	name string
+	key  string
+	rpm  int
+	tpm  int
+}
+
+func abortWithRateLimit(c *gin.Context, limitType string, message string) {
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error": gin.H{
+			"message": common.MessageWithRequestId(message, c.GetString(common.RequestIdKey)),
+			"type":    limitType,
+			"param":   nil,
+			"code":    "rate_limit_exceeded",
+		},
+	})
+	c.Abort()
+	common.LogWarn(c.Request.Context(), fmt.Sprintf("user %d | %s", c.GetInt("id"), message))
+}
+
+func setRateLimitHeaders(c *gin.Context, suffix string, result *common.UsageRateLimitResult) {
+	if result == nil {
+		return
+	}
+	c.Header("x-ratelimit-limit-"+suffix, strconv.Itoa(result.Limit))
+	c.Header("x-ratelimit-remaining-"+suffix, strconv.Itoa(result.Remaining))
+	c.Header("x-ratelimit-reset-"+suffix, result.Reset.Round(time.Millisecond).String())
+}
+
+// 返回剩余额度更少的结果，用于响应头展示最严格的限制
+func tighterRateLimit(a, b *common.UsageRateLimitResult) *common.UsageRateLimitResult {
+	if a == nil || (b != nil && b.Remaining < a.Remaining) {
+		return b
+	}
+	return a
+}
+
+// TokenRateLimit 按令牌和用户分组限制每分钟请求数（RPM）和 token 数（TPM），需在 TokenAuth 之后使用
+func TokenRateLimit() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userId := c.GetInt("id")
+		group := c.GetString("token_group")
+		if group == "" {
+			group, _ = model.CacheGetUserGroup(userId)
+		}
+		groupLimit := common.GetGroupRateLimit(group)
+		limits := []usageRateLimit{
+			{
+				name: "token",
+				key:  fmt.Sprintf("token:%d", c.GetInt("token_id")),
+				rpm:  c.GetInt("token_rpm_limit"),
+				tpm:  c.GetInt("token_tpm_limit"),
+			},
+			{
+				name: "group " + group,
+				key:  fmt.Sprintf("user:%d", userId),
+				rpm:  groupLimit.RPM,
+				tpm:  groupLimit.TPM,
+			},
+		}
+
+		// 先检查 TPM，避免请求因 TPM 超限被拒绝时仍占用 RPM 额度
+		var requestResult, tokenResult *common.UsageRateLimitResult
+		tokenKeys := make([]string, 0, len(limits))
+		for _, limit := range limits {
+			if limit.tpm <= 0 {
+				continue
+			}
+			result, err := common.RateLimitTokens(limit.key, limit.tpm)
+			if err != nil {
+				common.LogError(c.Request.Context(), "check tpm rate limit failed: "+err.Error())
+				continue
+			}
+			tokenKeys = append(tokenKeys, limit.key)
+			tokenResult = tighterRateLimit(tokenResult, result)
+			if !result.Allowed {
+				setRateLimitHeaders(c, "tokens", result)
+				abortWithRateLimit(c, "tokens", fmt.Sprintf("Rate limit reached for %s on tokens per min (TPM): Limit %d, Remaining %d. Please try again in %s.",
+					limit.name, result.Limit, result.Remaining, result.Reset.Round(time.Millisecond).String()))
+				return
+			}
+		}
+		for _, limit := range limits {
+			if limit.rpm <= 0 {
+				continue
+			}
+			result, err := common.RateLimitRequests(limit.key, limit.rpm)
+			if err != nil {
+				common.LogError(c.Request.Context(), "check rpm rate limit failed: "+err.Error())
+				continue
+			}
+			requestResult = tighterRateLimit(requestResult, result)
+			if !result.Allowed {
+				setRateLimitHeaders(c, "requests", result)
+				abortWithRateLimit(c, "requests", fmt.Sprintf("Rate limit reached for %s on requests per min (RPM): Limit %d, Used %d. Please try again in %s.",
+					limit.name, result.Limit, result.Limit-result.Remaining, result.Reset.Round(time.Millisecond).String()))
+				return
+			}
+		}
+		setRateLimitHeaders(c, "requests", requestResult)
+		setRateLimitHeaders(c, "tokens", tokenResult)
+		if len(tokenKeys) > 0 {
+			c.Set(common.RateLimitTokenKeysKey, tokenKeys)
+		}
+		c.Next()
+	}
+}
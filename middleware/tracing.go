@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"fmt"
+	"one-api/common"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing 为每个请求创建根 span，如果客户端传入了 traceparent 则接入客户端的链路
+func Tracing() gin.HandlerFunc {
+	tracer := otel.Tracer("one-api")
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		ctx, span := tracer.Start(ctx, fmt.Sprintf("%s %s", c.Request.Method, route),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("request_id", c.GetString(common.RequestIdKey)),
+			))
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if userId := c.GetInt("id"); userId != 0 {
+			span.SetAttributes(attribute.Int("user.id", userId))
+		}
+		if status >= 500 {
+			span.SetStatus(codes.Error, fmt.Sprintf("status code %d", status))
+		}
+	}
+}
+
+// withSpan 为中间件自身的处理过程创建 span，被包装的中间件不能调用 c.Next()，
+// 返回后由 gin 继续执行后续的 handler，使 span 不包含后续处理的耗时
+func withSpan(name string, handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, end := common.StartRequestSpan(c, name)
+		handler(c)
+		if c.IsAborted() {
+			span.SetStatus(codes.Error, fmt.Sprintf("aborted with status code %d", c.Writer.Status()))
+		}
+		end()
+	}
+}
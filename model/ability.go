@@ -118,7 +118,7 @@ func GetRandomSatisfiedChannel(group string, model string, limitsMap map[string]
 	}
 	copyAbilities := make([]Ability, 0)
 	for _, ability := range abilities {
-		if limitsMap == nil || limitsMap[ability.Model] {
+		if (limitsMap == nil || limitsMap[ability.Model]) && common.ChannelBreakers.Allow(ability.ChannelId, model) {
 			copyAbilities = append(copyAbilities, ability)
 		}
 	}
@@ -148,6 +148,9 @@ func GetRandomSatisfiedChannel(group string, model string, limitsMap map[string]
 		return nil, errors.New("channel not found")
 	}
 	err = DB.First(&channel, "id = ?", channel.Id).Error
+	if err == nil {
+		common.ChannelBreakers.OnSelected(channel.Id, model)
+	}
 	return &channel, err
 }
 
@@ -0,0 +1,106 @@
+package model
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"one-api/common"
+	"time"
+)
+
+// AuditLog 开启审计的令牌或分组的完整请求和响应，gzip 压缩后用 CryptoSecret 加密保存
+type AuditLog struct {
+	Id         int    `json:"id"`
+	RequestId  string `json:"request_id" gorm:"type:varchar(64);index"`
+	UserId     int    `json:"user_id" gorm:"index"`
+	TokenId    int    `json:"token_id" gorm:"index"`
+	ModelName  string `json:"model_name" gorm:"default:''"`
+	Path       string `json:"path" gorm:"default:''"`
+	StatusCode int    `json:"status_code" gorm:"default:0"`
+	Request    string `json:"-"`
+	Response   string `json:"-"`
+	CreatedAt  int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+func compressAndEncrypt(data []byte) (string, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	return common.EncryptWithSecret(buf.Bytes())
+}
+
+func decryptAndDecompress(encrypted string) ([]byte, error) {
+	if encrypted == "" {
+		return nil, nil
+	}
+	data, err := common.DecryptWithSecret(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// RecordAuditLog 加密并保存请求和响应
+func RecordAuditLog(log *AuditLog, request []byte, response []byte) error {
+	var err error
+	if log.Request, err = compressAndEncrypt(request); err != nil {
+		return err
+	}
+	if log.Response, err = compressAndEncrypt(response); err != nil {
+		return err
+	}
+	if log.CreatedAt == 0 {
+		log.CreatedAt = common.GetTimestamp()
+	}
+	return LOG_DB.Create(log).Error
+}
+
+// GetAuditLogsByRequestId 获取请求的审计日志并解密
+func GetAuditLogsByRequestId(requestId string) ([]*AuditLog, [][]byte, [][]byte, error) {
+	var logs []*AuditLog
+	err := LOG_DB.Where("request_id = ?", requestId).Order("id asc").Find(&logs).Error
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	requests := make([][]byte, len(logs))
+	responses := make([][]byte, len(logs))
+	for i, log := range logs {
+		if requests[i], err = decryptAndDecompress(log.Request); err != nil {
+			return nil, nil, nil, err
+		}
+		if responses[i], err = decryptAndDecompress(log.Response); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return logs, requests, responses, nil
+}
+
+func DeleteAuditLogsBefore(timestamp int64) (int64, error) {
+	result := LOG_DB.Where("created_at < ?", timestamp).Delete(&AuditLog{})
+	return result.RowsAffected, result.Error
+}
+
+// PurgeAuditLogs 定期删除超过保留天数的审计日志
+func PurgeAuditLogs(retentionDays int) {
+	for {
+		before := time.Now().AddDate(0, 0, -retentionDays).Unix()
+		count, err := DeleteAuditLogsBefore(before)
+		if err != nil {
+			common.SysError("failed to purge audit logs: " + err.Error())
+		} else if count > 0 {
+			common.SysLog(fmt.Sprintf("purged %d expired audit logs", count))
+		}
+		time.Sleep(time.Hour)
+	}
+}
@@ -211,6 +211,10 @@ var alias2models map[string][]string
 var channelSyncLock sync.RWMutex
 
 func InitChannelCache() {
+	start := time.Now()
+	defer func() {
+		common.MetricChannelCacheSyncDuration.Observe(time.Since(start).Seconds())
+	}()
 	newChannelId2channel := make(map[int]*Channel)
 	var channels []*Channel
 	DB.Where("status = ?", common.ChannelStatusEnabled).Find(&channels)
@@ -230,6 +234,7 @@ func InitChannelCache() {
 		newGroup2model2channels[group] = make(map[string][]*Channel)
 	}
 	for _, channel := range channels {
+		channel.parseModelRpmLimits()
 		newChannelsIDM[channel.Id] = channel
 		groups := strings.Split(channel.Group, ",")
 		for _, group := range groups {
@@ -257,6 +262,7 @@ func InitChannelCache() {
 	group2model2channels = newGroup2model2channels
 	channelsIDM = newChannelsIDM
 	channelSyncLock.Unlock()
+	initChannelKeyCache()
 	common.SysLog("channels synced from database")
 }
 
@@ -280,9 +286,17 @@ func CacheGetRandomSatisfiedChannel(group string, model string, limitsMap map[st
 	if !common.MemoryCacheEnabled {
 		return GetRandomSatisfiedChannel(group, model, limitsMap, retry)
 	}
+	// 缓存中的渠道列表同步时整体替换，不会原地修改，取出后即可释放锁
 	channelSyncLock.RLock()
-	defer channelSyncLock.RUnlock()
-	channels := group2model2channels[group][model]
+	candidates := group2model2channels[group][model]
+	channelSyncLock.RUnlock()
+	// 跳过已熔断以及 Key 池中的 Key 已全部禁用的渠道，并发和 RPM 在选中后由 AcquireSlot 检查
+	var channels []*Channel
+	for _, channel := range candidates {
+		if common.ChannelBreakers.Allow(channel.Id, model) && channel.HasAvailableKey() {
+			channels = append(channels, channel)
+		}
+	}
 	if len(channels) == 0 {
 		return nil, errors.New("channel not found")
 	}
@@ -309,6 +323,8 @@ func CacheGetRandomSatisfiedChannel(group string, model string, limitsMap map[st
 			targetChannels = append(targetChannels, channel)
 		}
 	}
+	// 按分组配置的选择策略筛选出候选渠道，再在候选中按权重随机
+	targetChannels = filterChannelsByStrategy(common.GetChannelSelectStrategy(group), model, targetChannels)
 
 	// 平滑系数
 	smoothingFactor := 10
@@ -326,6 +342,7 @@ func CacheGetRandomSatisfiedChannel(group string, model string, limitsMap map[st
 		weightOfChannel := channel.GetWeight() + smoothingFactor
 		randomWeight -= weightOfChannel - common.ChannelWeights.GetPenaltyWeight(channel.Id, weightOfChannel-1)
 		if randomWeight < 0 {
+			common.ChannelBreakers.OnSelected(channel.Id, model)
 			return channel, nil
 		}
 	}
@@ -333,6 +350,75 @@ func CacheGetRandomSatisfiedChannel(group string, model string, limitsMap map[st
 	return nil, errors.New("channel not found")
 }
 
+// 延迟策略下，延迟不超过最低延迟该倍数的渠道都作为候选，避免流量全部集中到一个渠道
+const latencyStrategyTolerance = 1.2
+
+func filterChannelsByStrategy(strategy string, model string, channels []*Channel) []*Channel {
+	var score func(channel *Channel) float64
+	tolerance := 1.0
+	switch strategy {
+	case common.ChannelSelectStrategyLatency:
+		score = latencyScorer(model, channels)
+		if score == nil {
+			return channels
+		}
+		tolerance = latencyStrategyTolerance
+	case common.ChannelSelectStrategyLeastInFlight:
+		score = func(channel *Channel) float64 {
+			return float64(common.ChannelStats.GetInFlight(channel.Id))
+		}
+	case common.ChannelSelectStrategyCheapest:
+		score = func(channel *Channel) float64 {
+			return channel.GetCostMultiplier()
+		}
+	default:
+		return channels
+	}
+	if len(channels) <= 1 {
+		return channels
+	}
+	minScore := score(channels[0])
+	for _, channel := range channels[1:] {
+		if s := score(channel); s < minScore {
+			minScore = s
+		}
+	}
+	candidates := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if score(channel) <= minScore*tolerance {
+			candidates = append(candidates, channel)
+		}
+	}
+	return candidates
+}
+
+// latencyScorer 按平均首字延迟打分，没有样本的渠道按同一批候选延迟的中位数计，
+// 既不会因为没有样本而一直被选中，也能分到流量积累样本。所有渠道都没有样本时返回 nil，按权重选择
+func latencyScorer(model string, channels []*Channel) func(channel *Channel) float64 {
+	latencies := make(map[int]float64, len(channels))
+	known := make([]float64, 0, len(channels))
+	for _, channel := range channels {
+		if latency, ok := common.ChannelStats.GetLatency(channel.Id, model); ok {
+			latencies[channel.Id] = latency
+			known = append(known, latency)
+		}
+	}
+	if len(known) == 0 {
+		return nil
+	}
+	sort.Float64s(known)
+	median := known[len(known)/2]
+	if len(known)%2 == 0 {
+		median = (known[len(known)/2-1] + known[len(known)/2]) / 2
+	}
+	return func(channel *Channel) float64 {
+		if latency, ok := latencies[channel.Id]; ok {
+			return latency
+		}
+		return median
+	}
+}
+
 func CacheGetChannel(id int) (*Channel, error) {
 	if !common.MemoryCacheEnabled {
 		return GetChannelById(id, true)
@@ -2,7 +2,9 @@ package model
 
 import (
 	"encoding/json"
+	"fmt"
 	"one-api/common"
+	"one-api/constant"
 	"strings"
 
 	"gorm.io/gorm"
@@ -35,6 +37,18 @@ type Channel struct {
 	OtherInfo          string  `json:"other_info"`
 	Tag                *string `json:"tag" gorm:"index"`
 	OtherSensitiveInfo *string `json:"other_sensitive_info"`
+	// 上游成本倍率，用于 cheapest 选择策略
+	CostMultiplier *float64 `json:"cost_multiplier" gorm:"default:1"`
+	// 最大并发请求数，0 表示不限制
+	MaxConcurrency *int `json:"max_concurrency" gorm:"default:0"`
+	// 各模型每分钟请求数上限，如 {"gpt-4o": 60, "*": 100}，"*" 对未单独配置的模型生效
+	ModelRpmLimits *string `json:"model_rpm_limits" gorm:"type:varchar(1024);default:''"`
+	// Key 池的选择方式，round_robin 或 random，为空时不使用 Key 池；Key 池为空时使用 Key 字段
+	KeySelectMode string `json:"key_select_mode" gorm:"type:varchar(16);default:''"`
+	// 出站连接设置，包括代理、超时、TLS 和空闲连接数，JSON 格式，见 service.ChannelTransportSetting
+	TransportSetting *string `json:"transport_setting" gorm:"type:text"`
+
+	modelRpmLimits map[string]int // 缓存的渠道在同步时解析 ModelRpmLimits，避免选择渠道时重复解析
 }
 
 func (channel *Channel) GetModels() []string {
@@ -64,6 +78,18 @@ func (channel *Channel) SetOtherInfo(otherInfo map[string]interface{}) {
 	channel.OtherInfo = string(otherInfoBytes)
 }
 
+// GetStatusFailedModel 触发自动禁用的模型，未记录时返回空字符串
+func (channel *Channel) GetStatusFailedModel() string {
+	failedModel, _ := channel.GetOtherInfo()["status_failed_model"].(string)
+	return failedModel
+}
+
+// GetStatusTime 渠道状态最后一次变更的时间
+func (channel *Channel) GetStatusTime() int64 {
+	statusTime, _ := channel.GetOtherInfo()["status_time"].(float64)
+	return int64(statusTime)
+}
+
 func (channel *Channel) GetTag() string {
 	if channel.Tag == nil {
 		return ""
@@ -167,6 +193,23 @@ func GetChannelById(id int, selectAll bool) (*Channel, error) {
 	return &channel, err
 }
 
+// GetChannelTypesByIds 获取渠道 ID 到渠道类型的映射，已删除的渠道不在结果中
+func GetChannelTypesByIds(ids []int) (map[int]int, error) {
+	types := make(map[int]int, len(ids))
+	if len(ids) == 0 {
+		return types, nil
+	}
+	var channels []*Channel
+	err := DB.Select("id", "type").Where("id in ?", ids).Find(&channels).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, channel := range channels {
+		types[channel.Id] = channel.Type
+	}
+	return types, nil
+}
+
 func BatchInsertChannels(channels []Channel) error {
 	var err error
 	err = DB.Create(&channels).Error
@@ -197,6 +240,12 @@ func BatchDeleteChannels(ids []int) error {
 		tx.Rollback()
 		return err
 	}
+	err = tx.Where("channel_id in (?)", ids).Delete(&ChannelKey{}).Error
+	if err != nil {
+		// 回滚事务
+		tx.Rollback()
+		return err
+	}
 	// 提交事务
 	tx.Commit()
 	return err
@@ -216,6 +265,83 @@ func (channel *Channel) GetWeight() int {
 	return int(*channel.Weight)
 }
 
+func (channel *Channel) GetCostMultiplier() float64 {
+	if channel.CostMultiplier == nil {
+		return 1
+	}
+	return *channel.CostMultiplier
+}
+
+func (channel *Channel) GetMaxConcurrency() int {
+	if channel.MaxConcurrency == nil {
+		return 0
+	}
+	return *channel.MaxConcurrency
+}
+
+// GetModelRpmLimit 获取模型的每分钟请求数上限，0 表示不限制
+func (channel *Channel) GetModelRpmLimit(modelName string) int {
+	limits := channel.modelRpmLimits
+	if limits == nil {
+		limits = channel.unmarshalModelRpmLimits()
+	}
+	if limit, ok := limits[modelName]; ok {
+		return limit
+	}
+	return limits["*"]
+}
+
+func (channel *Channel) unmarshalModelRpmLimits() map[string]int {
+	limits := make(map[string]int)
+	if channel.ModelRpmLimits == nil || *channel.ModelRpmLimits == "" {
+		return limits
+	}
+	if err := json.Unmarshal([]byte(*channel.ModelRpmLimits), &limits); err != nil {
+		common.SysError(fmt.Sprintf("failed to unmarshal model rpm limits of channel %d: %s", channel.Id, err.Error()))
+		return map[string]int{}
+	}
+	return limits
+}
+
+func (channel *Channel) parseModelRpmLimits() {
+	channel.modelRpmLimits = channel.unmarshalModelRpmLimits()
+}
+
+func (channel *Channel) GetTransportSetting() string {
+	if channel.TransportSetting == nil {
+		return ""
+	}
+	return *channel.TransportSetting
+}
+
+func channelModelRpmKey(channelId int, modelName string) string {
+	return fmt.Sprintf("channel:%d:%s", channelId, modelName)
+}
+
+// AcquireSlot 占用渠道的并发和模型 RPM 额度，成功时返回释放并发名额的函数
+func (channel *Channel) AcquireSlot(modelName string) (func(), bool) {
+	release := func() {}
+	if maxConcurrency := channel.GetMaxConcurrency(); maxConcurrency > 0 {
+		member := common.GetUUID()
+		if !common.AcquireChannelConcurrency(channel.Id, maxConcurrency, member) {
+			return nil, false
+		}
+		release = func() {
+			common.ReleaseChannelConcurrency(channel.Id, member)
+		}
+	}
+	if rpm := channel.GetModelRpmLimit(modelName); rpm > 0 {
+		result, err := common.RateLimitRequests(channelModelRpmKey(channel.Id, modelName), rpm)
+		if err != nil {
+			common.SysError("failed to check channel model rpm: " + err.Error())
+		} else if !result.Allowed {
+			release()
+			return nil, false
+		}
+	}
+	return release, true
+}
+
 func (channel *Channel) GetBaseURL() string {
 	if channel.BaseURL == nil {
 		return ""
@@ -269,12 +395,25 @@ func (channel *Channel) UpdateResponseTime(responseTime int64) {
 }
 
 func (channel *Channel) UpdateBalance(balance float64) {
+	previousBalance := channel.Balance
+	previousUpdatedTime := channel.BalanceUpdatedTime
 	err := DB.Model(channel).Select("balance_updated_time", "balance").Updates(Channel{
 		BalanceUpdatedTime: common.GetTimestamp(),
 		Balance:            balance,
 	}).Error
 	if err != nil {
 		common.SysError("failed to update balance: " + err.Error())
+		return
+	}
+	// 余额首次低于阈值时通知，之后保持在阈值以下不再重复通知
+	threshold := constant.ChannelBalanceLowThreshold
+	if threshold > 0 && balance < threshold && (previousUpdatedTime == 0 || previousBalance >= threshold) {
+		common.PublishEvent(common.EventChannelBalanceLow, 0, map[string]any{
+			"channel_id":   channel.Id,
+			"channel_name": channel.Name,
+			"balance":      balance,
+			"threshold":    threshold,
+		})
 	}
 }
 
@@ -285,10 +424,14 @@ func (channel *Channel) Delete() error {
 		return err
 	}
 	err = channel.DeleteAbilities()
-	return err
+	if err != nil {
+		return err
+	}
+	return deleteChannelKeysByChannelIds([]int{channel.Id})
 }
 
-func UpdateChannelStatusById(id int, status int, reason string) {
+// UpdateChannelStatusById 更新渠道状态，自动禁用时 failedModel 为触发禁用的模型，恢复探测时会用该模型测试
+func UpdateChannelStatusById(id int, status int, reason string, failedModel string) {
 	err := UpdateAbilityStatus(id, status == common.ChannelStatusEnabled)
 	if err != nil {
 		common.SysError("failed to update ability status: " + err.Error())
@@ -305,6 +448,11 @@ func UpdateChannelStatusById(id int, status int, reason string) {
 		info := channel.GetOtherInfo()
 		info["status_reason"] = reason
 		info["status_time"] = common.GetTimestamp()
+		if failedModel != "" {
+			info["status_failed_model"] = failedModel
+		} else {
+			delete(info, "status_failed_model")
+		}
 		channel.SetOtherInfo(info)
 		channel.Status = status
 		err = channel.Save()
@@ -0,0 +1,295 @@
+package model
+
+import (
+	"errors"
+	"math/rand"
+	"one-api/common"
+	"sync"
+	"sync/atomic"
+)
+
+// 多 Key 渠道的选择方式
+const (
+	ChannelKeySelectRoundRobin = "round_robin"
+	ChannelKeySelectRandom     = "random"
+)
+
+// ChannelKey 渠道 Key 池中的一个 Key，设置了 Key 选择方式的渠道按该方式使用池中启用的 Key，
+// Key 池为空时使用渠道自身的 Key。状态沿用渠道的状态值
+type ChannelKey struct {
+	Id           int    `json:"id"`
+	ChannelId    int    `json:"channel_id" gorm:"index"`
+	Key          string `json:"-" gorm:"type:text;not null"`
+	Status       int    `json:"status" gorm:"default:1"`
+	StatusReason string `json:"status_reason" gorm:"type:text"`
+	StatusTime   int64  `json:"status_time" gorm:"bigint"`
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+}
+
+// MaskedKey 只显示 Key 的首尾几位
+func (key *ChannelKey) MaskedKey() string {
+	if len(key.Key) <= 8 {
+		return "***"
+	}
+	return key.Key[:4] + "***" + key.Key[len(key.Key)-4:]
+}
+
+// channelKeyStats 单个 Key 的调用统计，只保存在内存中
+type channelKeyStats struct {
+	successCount        int64
+	failureCount        int64
+	consecutiveFailures int64
+	lastError           atomic.Value
+	lastUsedTime        int64
+}
+
+// ChannelKeyStats Key 的调用统计，用于管理接口展示
+type ChannelKeyStats struct {
+	SuccessCount        int64  `json:"success_count"`
+	FailureCount        int64  `json:"failure_count"`
+	ConsecutiveFailures int64  `json:"consecutive_failures"`
+	LastError           string `json:"last_error"`
+	LastUsedTime        int64  `json:"last_used_time"`
+}
+
+var channelKeyPools map[int][]*ChannelKey // 渠道 ID 到 Key 池中的所有 Key，包括已禁用的
+var channelKeyPoolsLock sync.RWMutex
+var channelKeyCursors sync.Map  // 渠道 ID 到 *uint64，轮询位置
+var channelKeyStatsMap sync.Map // Key ID 到 *channelKeyStats
+
+func initChannelKeyCache() {
+	var keys []*ChannelKey
+	err := DB.Order("id").Find(&keys).Error
+	if err != nil {
+		common.SysError("failed to load channel keys: " + err.Error())
+		return
+	}
+	pools := make(map[int][]*ChannelKey)
+	for _, key := range keys {
+		pools[key.ChannelId] = append(pools[key.ChannelId], key)
+	}
+	channelKeyPoolsLock.Lock()
+	channelKeyPools = pools
+	channelKeyPoolsLock.Unlock()
+}
+
+// 未开启内存缓存时，每个渠道的 Key 池在本节点缓存的秒数
+const channelKeyPoolCacheSeconds = 10
+
+type channelKeyPoolCacheEntry struct {
+	keys     []*ChannelKey
+	expireAt int64
+}
+
+var channelKeyPoolCache sync.Map // 渠道 ID 到 *channelKeyPoolCacheEntry，未开启内存缓存时使用
+
+// getChannelKeyPool 获取渠道 Key 池中的所有 Key，没有设置 Key 选择方式的渠道不使用 Key 池，不查询数据库
+func (channel *Channel) getChannelKeyPool() []*ChannelKey {
+	if channel.KeySelectMode == "" {
+		return nil
+	}
+	if common.MemoryCacheEnabled {
+		channelKeyPoolsLock.RLock()
+		defer channelKeyPoolsLock.RUnlock()
+		if channelKeyPools != nil {
+			return channelKeyPools[channel.Id]
+		}
+	}
+	now := common.GetTimestamp()
+	if entry, ok := channelKeyPoolCache.Load(channel.Id); ok && entry.(*channelKeyPoolCacheEntry).expireAt > now {
+		return entry.(*channelKeyPoolCacheEntry).keys
+	}
+	var keys []*ChannelKey
+	err := DB.Where("channel_id = ?", channel.Id).Order("id").Find(&keys).Error
+	if err != nil {
+		common.SysError("failed to get channel keys: " + err.Error())
+		return nil
+	}
+	channelKeyPoolCache.Store(channel.Id, &channelKeyPoolCacheEntry{keys: keys, expireAt: now + channelKeyPoolCacheSeconds})
+	return keys
+}
+
+func enabledChannelKeys(keys []*ChannelKey) []*ChannelKey {
+	enabled := make([]*ChannelKey, 0, len(keys))
+	for _, key := range keys {
+		if key.Status == common.ChannelStatusEnabled {
+			enabled = append(enabled, key)
+		}
+	}
+	return enabled
+}
+
+// SelectKey 按渠道的选择方式从 Key 池中选择一个 Key，Key 池为空时返回渠道自身的 Key，keyId 为 0；
+// Key 池中的 Key 全部被禁用时 ok 为 false，此时不能回退到渠道自身的 Key，它通常也是池中已被禁用的 Key
+func (channel *Channel) SelectKey() (key string, keyId int, ok bool) {
+	pool := channel.getChannelKeyPool()
+	if len(pool) == 0 {
+		return channel.Key, 0, true
+	}
+	keys := enabledChannelKeys(pool)
+	if len(keys) == 0 {
+		return "", 0, false
+	}
+	var selected *ChannelKey
+	if channel.KeySelectMode == ChannelKeySelectRandom {
+		selected = keys[rand.Intn(len(keys))]
+	} else {
+		cursor, _ := channelKeyCursors.LoadOrStore(channel.Id, new(uint64))
+		next := atomic.AddUint64(cursor.(*uint64), 1)
+		selected = keys[(next-1)%uint64(len(keys))]
+	}
+	return selected.Key, selected.Id, true
+}
+
+// HasAvailableKey 渠道没有使用 Key 池，或 Key 池中还有启用的 Key
+func (channel *Channel) HasAvailableKey() bool {
+	pool := channel.getChannelKeyPool()
+	return len(pool) == 0 || len(enabledChannelKeys(pool)) > 0
+}
+
+// GetAutoDisabledChannelKeys 获取自动禁用的 Key，用于恢复探测
+func GetAutoDisabledChannelKeys() (keys []*ChannelKey, err error) {
+	err = DB.Where("status = ?", common.ChannelStatusAutoDisabled).Find(&keys).Error
+	return keys, err
+}
+
+func getChannelKeyStats(keyId int) *channelKeyStats {
+	stats, _ := channelKeyStatsMap.LoadOrStore(keyId, &channelKeyStats{})
+	return stats.(*channelKeyStats)
+}
+
+// RecordChannelKeyResult 记录 Key 的调用结果，errMessage 为空表示成功
+func RecordChannelKeyResult(keyId int, errMessage string) {
+	if keyId == 0 {
+		return
+	}
+	stats := getChannelKeyStats(keyId)
+	atomic.StoreInt64(&stats.lastUsedTime, common.GetTimestamp())
+	if errMessage == "" {
+		atomic.AddInt64(&stats.successCount, 1)
+		atomic.StoreInt64(&stats.consecutiveFailures, 0)
+		return
+	}
+	atomic.AddInt64(&stats.failureCount, 1)
+	atomic.AddInt64(&stats.consecutiveFailures, 1)
+	stats.lastError.Store(errMessage)
+}
+
+func GetChannelKeyStats(keyId int) ChannelKeyStats {
+	stats := getChannelKeyStats(keyId)
+	lastError, _ := stats.lastError.Load().(string)
+	return ChannelKeyStats{
+		SuccessCount:        atomic.LoadInt64(&stats.successCount),
+		FailureCount:        atomic.LoadInt64(&stats.failureCount),
+		ConsecutiveFailures: atomic.LoadInt64(&stats.consecutiveFailures),
+		LastError:           lastError,
+		LastUsedTime:        atomic.LoadInt64(&stats.lastUsedTime),
+	}
+}
+
+func GetChannelKeys(channelId int) (keys []*ChannelKey, err error) {
+	err = DB.Where("channel_id = ?", channelId).Order("id").Find(&keys).Error
+	return keys, err
+}
+
+func GetChannelKeyById(channelId int, keyId int) (*ChannelKey, error) {
+	key := ChannelKey{}
+	err := DB.Where("id = ? and channel_id = ?", keyId, channelId).First(&key).Error
+	return &key, err
+}
+
+// AddChannelKeys 向渠道的 Key 池中添加 Key，跳过空 Key 和已存在的 Key，返回添加的数量
+func AddChannelKeys(channelId int, keys []string) (int, error) {
+	existing, err := GetChannelKeys(channelId)
+	if err != nil {
+		return 0, err
+	}
+	seen := make(map[string]bool, len(existing))
+	for _, key := range existing {
+		seen[key.Key] = true
+	}
+	now := common.GetTimestamp()
+	var newKeys []*ChannelKey
+	for _, key := range keys {
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		newKeys = append(newKeys, &ChannelKey{
+			ChannelId:   channelId,
+			Key:         key,
+			Status:      common.ChannelStatusEnabled,
+			CreatedTime: now,
+		})
+	}
+	if len(newKeys) == 0 {
+		return 0, nil
+	}
+	err = DB.Create(&newKeys).Error
+	if err != nil {
+		return 0, err
+	}
+	// 只有设置了 Key 选择方式的渠道才使用 Key 池，内存缓存中的渠道在下次同步后生效
+	err = DB.Model(&Channel{}).Where("id = ? and key_select_mode = ?", channelId, "").
+		Update("key_select_mode", ChannelKeySelectRoundRobin).Error
+	if err != nil {
+		return 0, err
+	}
+	reloadChannelKeyCache(channelId)
+	return len(newKeys), nil
+}
+
+func DeleteChannelKey(channelId int, keyId int) error {
+	result := DB.Where("id = ? and channel_id = ?", keyId, channelId).Delete(&ChannelKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("key 不存在")
+	}
+	channelKeyStatsMap.Delete(keyId)
+	reloadChannelKeyCache(channelId)
+	return nil
+}
+
+func deleteChannelKeysByChannelIds(channelIds []int) error {
+	err := DB.Where("channel_id in ?", channelIds).Delete(&ChannelKey{}).Error
+	if err != nil {
+		return err
+	}
+	reloadChannelKeyCache(channelIds...)
+	return nil
+}
+
+// UpdateChannelKeyStatus 更新 Key 的状态，返回渠道剩余的启用 Key 数量
+func UpdateChannelKeyStatus(channelId int, keyId int, status int, reason string) (int64, error) {
+	result := DB.Model(&ChannelKey{}).Where("id = ? and channel_id = ?", keyId, channelId).Updates(map[string]interface{}{
+		"status":        status,
+		"status_reason": reason,
+		"status_time":   common.GetTimestamp(),
+	})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, errors.New("key 不存在")
+	}
+	if status == common.ChannelStatusEnabled {
+		atomic.StoreInt64(&getChannelKeyStats(keyId).consecutiveFailures, 0)
+	}
+	reloadChannelKeyCache(channelId)
+	var enabled int64
+	err := DB.Model(&ChannelKey{}).Where("channel_id = ? and status = ?", channelId, common.ChannelStatusEnabled).Count(&enabled).Error
+	return enabled, err
+}
+
+// reloadChannelKeyCache Key 变更后立即刷新本节点的缓存，其他节点在下次同步渠道缓存或缓存过期时刷新
+func reloadChannelKeyCache(channelIds ...int) {
+	if common.MemoryCacheEnabled {
+		initChannelKeyCache()
+		return
+	}
+	for _, channelId := range channelIds {
+		channelKeyPoolCache.Delete(channelId)
+	}
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"one-api/common"
+)
+
+// 渠道测试的来源
+const (
+	ChannelProbeSourceManual    = "manual"    // 管理员手动测试单个渠道
+	ChannelProbeSourceScheduled = "scheduled" // 测试所有渠道
+	ChannelProbeSourceRecovery  = "recovery"  // 自动禁用渠道的恢复探测
+)
+
+// ChannelProbe 渠道测试记录，包括手动测试、定时测试和自动禁用渠道的恢复探测
+type ChannelProbe struct {
+	Id           int    `json:"id"`
+	ChannelId    int    `json:"channel_id" gorm:"index"`
+	Source       string `json:"source" gorm:"type:varchar(16);default:''"`
+	ModelName    string `json:"model_name" gorm:"default:''"`
+	Endpoint     string `json:"endpoint" gorm:"type:varchar(32);default:''"`
+	Success      bool   `json:"success"`
+	StatusCode   int    `json:"status_code" gorm:"default:0"`
+	ResponseTime int64  `json:"response_time"` // 毫秒
+	Message      string `json:"message" gorm:"type:text"`
+	Attempt      int    `json:"attempt,omitempty"` // 恢复探测时为渠道被禁用以来的第几次探测
+	CreatedAt    int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+// 每个渠道保留的测试记录条数
+var channelProbeHistoryLimit = common.GetEnvOrDefault("CHANNEL_TEST_HISTORY_LIMIT", 100)
+
+func RecordChannelProbe(probe *ChannelProbe) {
+	probe.CreatedAt = common.GetTimestamp()
+	err := DB.Create(probe).Error
+	if err != nil {
+		common.SysError("failed to record channel probe: " + err.Error())
+		return
+	}
+	// 删除超出保留条数的旧记录
+	var ids []int
+	err = DB.Model(&ChannelProbe{}).Where("channel_id = ?", probe.ChannelId).
+		Order("id desc").Offset(channelProbeHistoryLimit).Limit(1000).Pluck("id", &ids).Error
+	if err == nil && len(ids) > 0 {
+		DB.Where("id in ?", ids).Delete(&ChannelProbe{})
+	}
+}
+
+// GetChannelProbes 获取渠道最近的测试记录，按时间倒序
+func GetChannelProbes(channelId int, limit int) (probes []*ChannelProbe, err error) {
+	err = DB.Where("channel_id = ?", channelId).Order("id desc").Limit(limit).Find(&probes).Error
+	return probes, err
+}
+
+func GetAutoDisabledChannels() (channels []*Channel, err error) {
+	err = DB.Where("status = ?", common.ChannelStatusAutoDisabled).Find(&channels).Error
+	return channels, err
+}
@@ -0,0 +1,45 @@
+package model
+
+// File 通过 /v1/files 上传的文件以及批处理生成的结果文件，内容直接保存在数据库中
+type File struct {
+	Id        int    `json:"id"`
+	FileId    string `json:"file_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	Purpose   string `json:"purpose" gorm:"type:varchar(32);index"`
+	Filename  string `json:"filename"`
+	Bytes     int64  `json:"bytes"`
+	Content   string `json:"-"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+}
+
+func (file *File) Insert() error {
+	var err error
+	err = DB.Create(file).Error
+	return err
+}
+
+func GetFileByFileId(userId int, fileId string) (*File, error) {
+	var file *File
+	var err error
+	err = DB.Where("user_id = ? and file_id = ?", userId, fileId).First(&file).Error
+	return file, err
+}
+
+// GetUserFiles 按创建时间倒序获取用户的文件，afterFileId 不为空时只返回在该文件之前创建的文件（分页游标）
+func GetUserFiles(userId int, purpose string, afterFileId string, num int) ([]*File, error) {
+	var files []*File
+	var err error
+	query := DB.Omit("content").Where("user_id = ?", userId)
+	if purpose != "" {
+		query = query.Where("purpose = ?", purpose)
+	}
+	if afterFileId != "" {
+		query = query.Where("id < (?)", DB.Model(&File{}).Select("id").Where("user_id = ? and file_id = ?", userId, afterFileId))
+	}
+	err = query.Order("id desc").Limit(num).Find(&files).Error
+	return files, err
+}
+
+func DeleteFileByFileId(userId int, fileId string) error {
+	return DB.Where("user_id = ? and file_id = ?", userId, fileId).Delete(&File{}).Error
+}
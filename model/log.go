@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 	"gorm.io/gorm"
 )
 
@@ -29,6 +31,10 @@ type Log struct {
 	IsStream             bool   `json:"is_stream" gorm:"default:false"`
 	ChannelId            int    `json:"channel" gorm:"index"`
 	TokenId              int    `json:"token_id" gorm:"default:0;index"`
+	RequestId            string `json:"request_id" gorm:"type:varchar(64);index;default:''"`
+	Ip                   string `json:"ip" gorm:"type:varchar(64);index;default:''"`
+	RetryChannels        string `json:"retry_channels" gorm:"default:''"` // 依次尝试的渠道，如 3->5->7
+	StatusCode           int    `json:"status_code" gorm:"index;default:0"`
 	Other                string `json:"other"`
 }
 
@@ -38,8 +44,41 @@ const (
 	LogTypeConsume
 	LogTypeManage
 	LogTypeSystem
+	LogTypeError
 )
 
+// LogFilter 日志查询中按请求 ID、客户端 IP 和错误状态码过滤的条件，为空时不过滤
+type LogFilter struct {
+	RequestId  string
+	Ip         string
+	StatusCode int
+}
+
+func (filter LogFilter) apply(tx *gorm.DB) *gorm.DB {
+	if filter.RequestId != "" {
+		tx = tx.Where("request_id = ?", filter.RequestId)
+	}
+	if filter.Ip != "" {
+		tx = tx.Where("ip = ?", filter.Ip)
+	}
+	if filter.StatusCode != 0 {
+		tx = tx.Where("status_code = ?", filter.StatusCode)
+	}
+	return tx
+}
+
+// getLogRequestInfo 从请求上下文中取出请求 ID、客户端 IP 和依次尝试的渠道
+func getLogRequestInfo(ctx context.Context) (requestId string, ip string, retryChannels string) {
+	requestId, _ = ctx.Value(common.RequestIdKey).(string)
+	if c, ok := ctx.(*gin.Context); ok {
+		if c.Request != nil {
+			ip = c.ClientIP()
+		}
+		retryChannels = strings.Join(c.GetStringSlice("use_channel"), "->")
+	}
+	return
+}
+
 func GetLogByKey(key string) (logs []*Log, err error) {
 	if os.Getenv("LOG_SQL_DSN") != "" {
 		var tk Token
@@ -73,11 +112,16 @@ func RecordLog(userId int, logType int, content string) {
 
 func RecordConsumeLog(ctx context.Context, userId int, channelId int, promptTokens int, completionTokens int, promptCacheHitTokens int, modelName string, tokenName string, quota int, content string, tokenId int, userQuota int, useTimeSeconds int, isStream bool, other map[string]interface{}) {
 	common.LogInfo(ctx, fmt.Sprintf("record consume log: userId=%d, 用户调用前余额=%d, channelId=%d, promptTokens=%d, completionTokens=%d, promptCacheHitTokens=%d, modelName=%s, tokenName=%s, quota=%d, content=%s", userId, userQuota, channelId, promptTokens, completionTokens, promptCacheHitTokens, modelName, tokenName, quota, content))
+	_, span := common.StartSpan(ctx, "db.record_consume_log", attribute.Int("quota", quota))
+	defer span.End()
+	group, _ := ctx.Value("group").(string)
+	common.RecordUsageMetrics(modelName, channelId, group, promptTokens, completionTokens, quota)
 	if !common.LogConsumeEnabled {
 		return
 	}
 	username, _ := CacheGetUsername(userId)
 	otherStr := common.MapToJsonStr(other)
+	requestId, ip, retryChannels := getLogRequestInfo(ctx)
 	log := &Log{
 		UserId:               userId,
 		Username:             username,
@@ -94,6 +138,9 @@ func RecordConsumeLog(ctx context.Context, userId int, channelId int, promptToke
 		TokenId:              tokenId,
 		UseTime:              useTimeSeconds,
 		IsStream:             isStream,
+		RequestId:            requestId,
+		Ip:                   ip,
+		RetryChannels:        retryChannels,
 		Other:                otherStr,
 	}
 	err := LOG_DB.Create(log).Error
@@ -107,7 +154,51 @@ func RecordConsumeLog(ctx context.Context, userId int, channelId int, promptToke
 	}
 }
 
-func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, startIdx int, num int, channel int) (logs []*Log, total int64, err error) {
+// GetRecentErrorLogs 获取 startTimestamp 之后最近的 limit 条错误日志，startTimestamp 为 0 时不限制时间
+func GetRecentErrorLogs(startTimestamp int64, limit int) (logs []*Log, err error) {
+	tx := LOG_DB.Where("type = ?", LogTypeError)
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	err = tx.Order("id desc").Limit(limit).Find(&logs).Error
+	return logs, err
+}
+
+// RecordErrorLog 记录所有重试都失败的请求，content 为返回给客户端的错误信息
+func RecordErrorLog(ctx context.Context, userId int, channelId int, modelName string, tokenName string, tokenId int, statusCode int, content string, useTimeSeconds int, isStream bool, other map[string]interface{}) {
+	common.LogInfo(ctx, fmt.Sprintf("record error log: userId=%d, channelId=%d, modelName=%s, tokenName=%s, statusCode=%d, content=%s", userId, channelId, modelName, tokenName, statusCode, content))
+	if !common.LogConsumeEnabled {
+		return
+	}
+	_, span := common.StartSpan(ctx, "db.record_error_log")
+	defer span.End()
+	username, _ := CacheGetUsername(userId)
+	requestId, ip, retryChannels := getLogRequestInfo(ctx)
+	log := &Log{
+		UserId:        userId,
+		Username:      username,
+		CreatedAt:     common.GetTimestamp(),
+		Type:          LogTypeError,
+		Content:       content,
+		TokenName:     tokenName,
+		ModelName:     modelName,
+		ChannelId:     channelId,
+		TokenId:       tokenId,
+		UseTime:       useTimeSeconds,
+		IsStream:      isStream,
+		RequestId:     requestId,
+		Ip:            ip,
+		RetryChannels: retryChannels,
+		StatusCode:    statusCode,
+		Other:         common.MapToJsonStr(other),
+	}
+	err := LOG_DB.Create(log).Error
+	if err != nil {
+		common.LogError(ctx, "failed to record log: "+err.Error())
+	}
+}
+
+func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, startIdx int, num int, channel int, filter LogFilter) (logs []*Log, total int64, err error) {
 	var tx *gorm.DB
 	if logType == LogTypeUnknown {
 		tx = LOG_DB
@@ -132,6 +223,7 @@ func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName
 	if channel != 0 {
 		tx = tx.Where("channel_id = ?", channel)
 	}
+	tx = filter.apply(tx)
 	err = tx.Model(&Log{}).Count(&total).Error
 	if err != nil {
 		return nil, 0, err
@@ -143,7 +235,7 @@ func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName
 	return logs, total, err
 }
 
-func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int64, modelName string, tokenName string, startIdx int, num int) (logs []*Log, total int64, err error) {
+func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int64, modelName string, tokenName string, startIdx int, num int, filter LogFilter) (logs []*Log, total int64, err error) {
 	var tx *gorm.DB
 	if logType == LogTypeUnknown {
 		tx = LOG_DB.Where("user_id = ?", userId)
@@ -162,12 +254,15 @@ func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int
 	if endTimestamp != 0 {
 		tx = tx.Where("created_at <= ?", endTimestamp)
 	}
+	tx = filter.apply(tx)
 	err = tx.Model(&Log{}).Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
 	err = tx.Order("id desc").Limit(num).Offset(startIdx).Omit("id").Find(&logs).Error
 	for i := range logs {
+		// 重试的渠道链路仅管理员可见
+		logs[i].RetryChannels = ""
 		var otherMap map[string]interface{}
 		otherMap = common.StrToMap(logs[i].Other)
 		if otherMap != nil {
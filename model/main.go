@@ -184,6 +184,10 @@ func migrateDB() error {
 	if err != nil {
 		return err
 	}
+	err = DB.AutoMigrate(&AuditLog{})
+	if err != nil {
+		return err
+	}
 	err = DB.AutoMigrate(&Midjourney{})
 	if err != nil {
 		return err
@@ -200,6 +204,22 @@ func migrateDB() error {
 	if err != nil {
 		return err
 	}
+	err = DB.AutoMigrate(&Response{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&File{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&ChannelProbe{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&ChannelKey{})
+	if err != nil {
+		return err
+	}
 	common.SysLog("database migrated")
 	err = createRootAccountIfNeed()
 	return err
@@ -210,6 +230,9 @@ func migrateLOGDB() error {
 	if err = LOG_DB.AutoMigrate(&Log{}); err != nil {
 		return err
 	}
+	if err = LOG_DB.AutoMigrate(&AuditLog{}); err != nil {
+		return err
+	}
 
 	// 检查PromptCacheHitTokens字段是否存在
 	var exists bool
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var batchUpdateTypeNames = []string{"user_quota", "token_quota", "used_quota", "channel_used_quota", "request_count"}
+
+// metricsCollector 在抓取时从数据库读取渠道状态、从内存读取批量更新队列长度
+type metricsCollector struct {
+	channelStatus   *prometheus.Desc
+	batchUpdateSize *prometheus.Desc
+}
+
+func newMetricsCollector() *metricsCollector {
+	return &metricsCollector{
+		channelStatus: prometheus.NewDesc("one_api_channel_status",
+			"Channel status: 1 enabled, 2 manually disabled, 3 auto disabled.",
+			[]string{"channel", "name", "type"}, nil),
+		batchUpdateSize: prometheus.NewDesc("one_api_batch_update_queue_size",
+			"Number of pending records in the batch updater by type.",
+			[]string{"type"}, nil),
+	}
+}
+
+func (collector *metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collector.channelStatus
+	ch <- collector.batchUpdateSize
+}
+
+func (collector *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	if DB != nil {
+		var channels []*Channel
+		if err := DB.Select("id", "name", "type", "status").Find(&channels).Error; err == nil {
+			for _, channel := range channels {
+				ch <- prometheus.MustNewConstMetric(collector.channelStatus, prometheus.GaugeValue, float64(channel.Status),
+					strconv.Itoa(channel.Id), channel.Name, strconv.Itoa(channel.Type))
+			}
+		}
+	}
+	for i := 0; i < BatchUpdateTypeCount; i++ {
+		batchUpdateLocks[i].Lock()
+		size := len(batchUpdateStores[i])
+		batchUpdateLocks[i].Unlock()
+		ch <- prometheus.MustNewConstMetric(collector.batchUpdateSize, prometheus.GaugeValue, float64(size), batchUpdateTypeNames[i])
+	}
+}
+
+func init() {
+	prometheus.MustRegister(newMetricsCollector())
+}
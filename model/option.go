@@ -89,6 +89,10 @@ func InitOptionMap() {
 	common.OptionMap["ModelPrice"] = common.ModelPrice2JSONString()
 	common.OptionMap["GroupRatio"] = common.GroupRatio2JSONString()
 	common.OptionMap["UserUsableGroups"] = common.UserUsableGroups2JSONString()
+	common.OptionMap["ChannelSelectStrategy"] = common.ChannelSelectStrategy2JSONString()
+	common.OptionMap["GroupRateLimit"] = common.GroupRateLimit2JSONString()
+	common.OptionMap["GroupHedgeDelay"] = common.GroupHedgeDelay2JSONString()
+	common.OptionMap["ChannelDisableRules"] = common.ChannelDisableRules2JSONString()
 	common.OptionMap["CompletionRatio"] = common.CompletionRatio2JSONString()
 	common.OptionMap["TopUpLink"] = common.TopUpLink
 	common.OptionMap["ChatLink"] = common.ChatLink
@@ -109,6 +113,14 @@ func InitOptionMap() {
 	common.OptionMap["StopOnSensitiveEnabled"] = strconv.FormatBool(constant.StopOnSensitiveEnabled)
 	common.OptionMap["SensitiveWords"] = constant.SensitiveWordsToString()
 	common.OptionMap["StreamCacheQueueLength"] = strconv.Itoa(constant.StreamCacheQueueLength)
+	common.OptionMap["ResponseCacheGroups"] = constant.ResponseCacheGroupsToString()
+	common.OptionMap["AuditLogGroups"] = constant.AuditLogGroupsToString()
+	common.OptionMap["ResponseCacheQuotaRatio"] = strconv.FormatFloat(constant.ResponseCacheQuotaRatio, 'f', -1, 64)
+	common.OptionMap["WebhookEnabled"] = strconv.FormatBool(constant.WebhookEnabled)
+	common.OptionMap["WebhookUrl"] = constant.WebhookUrl
+	common.OptionMap["WebhookSecret"] = constant.WebhookSecret
+	common.OptionMap["WebhookEvents"] = constant.WebhookEventsToString()
+	common.OptionMap["ChannelBalanceLowThreshold"] = strconv.FormatFloat(constant.ChannelBalanceLowThreshold, 'f', -1, 64)
 
 	common.OptionMapRWMutex.Unlock()
 	loadOptionsFromDatabase()
@@ -218,6 +230,8 @@ func updateOptionMap(key string, value string) (err error) {
 			constant.MjForwardUrlEnabled = boolValue
 		case "MjActionCheckSuccessEnabled":
 			constant.MjActionCheckSuccessEnabled = boolValue
+		case "WebhookEnabled":
+			constant.WebhookEnabled = boolValue
 		case "CheckSensitiveEnabled":
 			constant.CheckSensitiveEnabled = boolValue
 		case "CheckSensitiveOnPromptEnabled":
@@ -316,6 +330,14 @@ func updateOptionMap(key string, value string) (err error) {
 		err = common.UpdateGroupRatioByJSONString(value)
 	case "UserUsableGroups":
 		err = common.UpdateUserUsableGroupsByJSONString(value)
+	case "ChannelSelectStrategy":
+		err = common.UpdateChannelSelectStrategyByJSONString(value)
+	case "GroupRateLimit":
+		err = common.UpdateGroupRateLimitByJSONString(value)
+	case "GroupHedgeDelay":
+		err = common.UpdateGroupHedgeDelayByJSONString(value)
+	case "ChannelDisableRules":
+		err = common.UpdateChannelDisableRulesByJSONString(value)
 	case "CompletionRatio":
 		err = common.UpdateCompletionRatioByJSONString(value)
 	case "ModelPrice":
@@ -334,6 +356,20 @@ func updateOptionMap(key string, value string) (err error) {
 		constant.SensitiveWordsFromString(value)
 	case "StreamCacheQueueLength":
 		constant.StreamCacheQueueLength, _ = strconv.Atoi(value)
+	case "ResponseCacheGroups":
+		constant.ResponseCacheGroupsFromString(value)
+	case "AuditLogGroups":
+		constant.AuditLogGroupsFromString(value)
+	case "ResponseCacheQuotaRatio":
+		constant.ResponseCacheQuotaRatio, _ = strconv.ParseFloat(value, 64)
+	case "WebhookUrl":
+		constant.WebhookUrl = value
+	case "WebhookSecret":
+		constant.WebhookSecret = value
+	case "WebhookEvents":
+		constant.WebhookEventsFromString(value)
+	case "ChannelBalanceLowThreshold":
+		constant.ChannelBalanceLowThreshold, _ = strconv.ParseFloat(value, 64)
 	}
 	return err
 }
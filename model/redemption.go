@@ -79,6 +79,11 @@ func Redeem(key string, userId int) (quota int, err error) {
 		return 0, errors.New("兑换失败，" + err.Error())
 	}
 	RecordLog(userId, LogTypeTopup, fmt.Sprintf("通过兑换码充值 %s，兑换码ID %d", common.LogQuota(redemption.Quota), redemption.Id))
+	common.PublishEvent(common.EventTopUpCompleted, userId, map[string]any{
+		"method":        "redemption",
+		"redemption_id": redemption.Id,
+		"quota":         redemption.Quota,
+	})
 	return redemption.Quota, nil
 }
 
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Response 保存 /v1/responses 的对话上下文，用于在本地展开 previous_response_id
+// Items 只保存这一轮新增的 input 和 output item（JSON），续接时沿 PreviousResponseId 向前拼接出完整对话，因此与上游渠道无关
+type Response struct {
+	Id                 int    `json:"id"`
+	ResponseId         string `json:"response_id" gorm:"type:varchar(64);uniqueIndex"`
+	PreviousResponseId string `json:"previous_response_id" gorm:"type:varchar(64);index"`
+	UserId             int    `json:"user_id" gorm:"index"`
+	TokenId            int    `json:"token_id"`
+	ChannelId          int    `json:"channel_id"`
+	Model              string `json:"model"`
+	Items              string `json:"items"`
+	CreatedAt          int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+func (response *Response) Insert() error {
+	var err error
+	err = DB.Create(response).Error
+	return err
+}
+
+func GetResponseByResponseId(userId int, responseId string) (*Response, error) {
+	var response *Response
+	var err error
+	err = DB.Where("user_id = ? and response_id = ?", userId, responseId).First(&response).Error
+	return response, err
+}
+
+// 展开 previous_response_id 时最多向前追溯的轮数，防止异常数据形成环
+const responseChainMaxDepth = 1000
+
+// GetResponseChain 从 responseId 开始沿 PreviousResponseId 向前查找，按时间正序返回对话的各轮记录
+// 起点不存在时返回错误；更早的记录已过期清理时，对话从仍保留的最早一轮开始
+func GetResponseChain(userId int, responseId string) ([]*Response, error) {
+	response, err := GetResponseByResponseId(userId, responseId)
+	if err != nil {
+		return nil, err
+	}
+	chain := []*Response{response}
+	for response.PreviousResponseId != "" && len(chain) < responseChainMaxDepth {
+		response, err = GetResponseByResponseId(userId, response.PreviousResponseId)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, response)
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
+}
+
+func DeleteResponsesBefore(timestamp int64) (int64, error) {
+	result := DB.Where("created_at < ?", timestamp).Delete(&Response{})
+	return result.RowsAffected, result.Error
+}
+
+// PurgeResponses 定期删除超过保留天数的对话上下文
+func PurgeResponses(retentionDays int) {
+	for {
+		before := time.Now().AddDate(0, 0, -retentionDays).Unix()
+		count, err := DeleteResponsesBefore(before)
+		if err != nil {
+			common.SysError("failed to purge responses: " + err.Error())
+		} else if count > 0 {
+			common.SysLog(fmt.Sprintf("purged %d expired responses", count))
+		}
+		time.Sleep(time.Hour)
+	}
+}
@@ -27,6 +27,10 @@ type Token struct {
 	AllowIps           *string        `json:"allow_ips" gorm:"default:''"`
 	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
 	Group              string         `json:"group" gorm:"default:''"`
+	RpmLimit           int            `json:"rpm_limit" gorm:"default:0"` // 0 means unlimited
+	TpmLimit           int            `json:"tpm_limit" gorm:"default:0"` // 0 means unlimited
+	ResponseCache      bool           `json:"response_cache" gorm:"default:false"`
+	AuditLog           bool           `json:"audit_log" gorm:"default:false"` // 记录完整的请求和响应用于审计
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
@@ -156,10 +160,22 @@ func (token *Token) Insert() error {
 func (token *Token) Update() error {
 	var err error
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group").Updates(token).Error
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "rpm_limit", "tpm_limit", "response_cache").Updates(token).Error
 	return err
 }
 
+// UpdateTokenAuditLog 开启或关闭令牌的审计日志，只允许管理员修改
+func UpdateTokenAuditLog(id int, auditLog bool) error {
+	result := DB.Model(&Token{}).Where("id = ?", id).Update("audit_log", auditLog)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("令牌不存在")
+	}
+	return nil
+}
+
 func (token *Token) SelectUpdate() error {
 	// This can update zero values
 	return DB.Model(token).Select("accessed_time", "status").Updates(token).Error
@@ -315,6 +331,14 @@ func PostConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, userQuota int, quot
 			quotaTooLow := userQuota >= common.QuotaRemindThreshold && userQuota-(quota+preConsumedQuota) < common.QuotaRemindThreshold
 			noMoreQuota := userQuota-(quota+preConsumedQuota) <= 0
 			if quotaTooLow || noMoreQuota {
+				eventType := common.EventUserQuotaLow
+				if noMoreQuota {
+					eventType = common.EventUserQuotaExhausted
+				}
+				common.PublishEvent(eventType, relayInfo.UserId, map[string]any{
+					"quota":     userQuota - (quota + preConsumedQuota),
+					"threshold": common.QuotaRemindThreshold,
+				})
 				go func() {
 					email, err := GetUserEmail(relayInfo.UserId)
 					if err != nil {
@@ -335,8 +359,23 @@ func PostConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, userQuota int, quot
 					}
 				}()
 			}
+			if !relayInfo.IsPlayground && !relayInfo.TokenUnlimited && quota+preConsumedQuota > 0 {
+				notifyTokenExhausted(relayInfo, quota+preConsumedQuota)
+			}
 		}
 	}
 
 	return nil
 }
+
+// notifyTokenExhausted 令牌鉴权时的剩余额度扣除本次消费后降到 0 以下时发布 token.exhausted 事件，
+// 不再查询数据库；并发请求基于同一份剩余额度时可能重复通知
+func notifyTokenExhausted(relayInfo *relaycommon.RelayInfo, consumed int) {
+	remainQuota := relayInfo.TokenRemainQuota - consumed
+	if relayInfo.TokenRemainQuota > 0 && remainQuota <= 0 {
+		common.PublishEvent(common.EventTokenExhausted, relayInfo.UserId, map[string]any{
+			"token_id":     relayInfo.TokenId,
+			"remain_quota": remainQuota,
+		})
+	}
+}
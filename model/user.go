@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"one-api/common"
+	"one-api/constant"
 	"strconv"
 	"strings"
 	"time"
@@ -37,6 +38,9 @@ type User struct {
 	InviterId        int            `json:"inviter_id" gorm:"type:int;column:inviter_id;index"`
 	DeletedAt        gorm.DeletedAt `gorm:"index"`
 	LinuxDOId        string         `json:"linux_do_id" gorm:"column:linux_do_id;index"`
+	WebhookUrl       string         `json:"webhook_url" gorm:"type:varchar(512)"`
+	WebhookSecret    string         `json:"-" gorm:"type:text"` // 加密存储，不返回给前端
+	WebhookEvents    string         `json:"webhook_events" gorm:"type:varchar(512)"`
 }
 
 func (user *User) GetAccessToken() string {
@@ -453,6 +457,43 @@ func GetUserEmail(id int) (email string, err error) {
 	return email, err
 }
 
+// GetUserWebhook 获取用户的 webhook 地址、解密后的签名密钥和订阅的事件
+func GetUserWebhook(id int) (url string, secret string, events []string, err error) {
+	user := User{}
+	err = DB.Model(&User{}).Where("id = ?", id).Select("webhook_url", "webhook_secret", "webhook_events").First(&user).Error
+	if err != nil {
+		return "", "", nil, err
+	}
+	if user.WebhookSecret != "" {
+		plain, err := common.DecryptWithSecret(user.WebhookSecret)
+		if err != nil {
+			return "", "", nil, err
+		}
+		secret = string(plain)
+	}
+	return user.WebhookUrl, secret, constant.ParseWebhookEvents(user.WebhookEvents), nil
+}
+
+// UpdateUserWebhook 更新用户的 webhook 配置，secret 为 nil 时保留原密钥
+func UpdateUserWebhook(id int, url string, secret *string, events string) error {
+	updates := map[string]interface{}{
+		"webhook_url":    url,
+		"webhook_events": events,
+	}
+	if secret != nil {
+		encrypted := ""
+		if *secret != "" {
+			var err error
+			encrypted, err = common.EncryptWithSecret([]byte(*secret))
+			if err != nil {
+				return err
+			}
+		}
+		updates["webhook_secret"] = encrypted
+	}
+	return DB.Model(&User{}).Where("id = ?", id).Updates(updates).Error
+}
+
 func GetUserGroup(id int) (group string, err error) {
 	groupCol := "`group`"
 	if common.UsingPostgreSQL {
@@ -23,6 +23,24 @@ type Adaptor interface {
 	GetChannelName() string
 }
 
+// ClaudeAdaptor 上游原生支持 Anthropic Messages 格式的渠道，/v1/messages 请求可直接透传
+type ClaudeAdaptor interface {
+	SupportClaudeMessages(info *relaycommon.RelayInfo) bool
+	ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayInfo, request map[string]any) (any, error)
+}
+
+// GeminiAdaptor 上游原生支持 Gemini generateContent 格式的渠道，/v1beta/models 请求可直接透传
+type GeminiAdaptor interface {
+	SupportGeminiContent(info *relaycommon.RelayInfo) bool
+	ConvertGeminiRequest(c *gin.Context, info *relaycommon.RelayInfo, request map[string]any) (any, error)
+}
+
+// ResponsesAdaptor 上游原生支持 /v1/responses 的渠道，请求可直接透传
+type ResponsesAdaptor interface {
+	SupportResponses(info *relaycommon.RelayInfo) bool
+	ConvertResponsesRequest(c *gin.Context, info *relaycommon.RelayInfo, request map[string]any) (any, error)
+}
+
 type TaskAdaptor interface {
 	Init(info *relaycommon.TaskRelayInfo)
 
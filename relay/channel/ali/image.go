@@ -26,8 +26,34 @@ func oaiImage2Ali(request dto.ImageRequest) *AliImageRequest {
 	return &imageRequest
 }
 
+// oaiImageEdit2Ali 将 OpenAI 图像编辑请求转换为通义万相图像编辑请求，有 mask 时进行局部重绘
+func oaiImageEdit2Ali(c *gin.Context, request dto.ImageRequest) (*AliImageEditRequest, error) {
+	images, err := service.GetFormImagesBase64(c, "image")
+	if err != nil {
+		return nil, err
+	}
+	if len(images) == 0 {
+		return nil, errors.New("image is required")
+	}
+	masks, err := service.GetFormImagesBase64(c, "mask")
+	if err != nil {
+		return nil, err
+	}
+	var imageRequest AliImageEditRequest
+	imageRequest.Model = request.Model
+	imageRequest.Input.Function = "description_edit"
+	imageRequest.Input.Prompt = request.Prompt
+	imageRequest.Input.BaseImageUrl = images[0]
+	if len(masks) > 0 {
+		imageRequest.Input.Function = "description_edit_with_mask"
+		imageRequest.Input.MaskImageUrl = masks[0]
+	}
+	imageRequest.Parameters.N = request.N
+	return &imageRequest, nil
+}
+
 func updateTask(info *relaycommon.RelayInfo, taskID string, key string) (*AliResponse, error, []byte) {
-	url := fmt.Sprintf("/api/v1/tasks/%s", taskID)
+	url := fmt.Sprintf("%s/api/v1/tasks/%s", info.BaseUrl, taskID)
 
 	var aliResponse AliResponse
 
@@ -8,6 +8,7 @@ import (
 	"one-api/dto"
 	"one-api/relay/channel/claude"
 	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
 )
 
 const (
@@ -55,6 +56,23 @@ func (a *Adaptor) ConvertRequest(c *gin.Context, info *relaycommon.RelayInfo, re
 	return claudeReq, err
 }
 
+func (a *Adaptor) SupportClaudeMessages(info *relaycommon.RelayInfo) bool {
+	return true
+}
+
+func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayInfo, request map[string]any) (any, error) {
+	if request == nil {
+		return nil, errors.New("request is nil")
+	}
+	// bedrock 的模型和流式在调用方式中指定
+	delete(request, "model")
+	delete(request, "stream")
+	request["anthropic_version"] = "bedrock-2023-05-31"
+	c.Set("request_model", info.UpstreamModelName)
+	c.Set("converted_request", request)
+	return request, nil
+}
+
 func (a *Adaptor) ConvertRerankRequest(c *gin.Context, relayMode int, request dto.RerankRequest) (any, error) {
 	return nil, nil
 }
@@ -64,6 +82,14 @@ func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, request
 }
 
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *dto.OpenAIErrorWithStatusCode) {
+	if info.RelayMode == relayconstant.RelayModeClaudeMessages {
+		if info.IsStream {
+			err, usage = awsClaudeNativeStreamHandler(c, info)
+		} else {
+			err, usage = awsClaudeNativeHandler(c, info)
+		}
+		return
+	}
 	if info.IsStream {
 		err, usage = awsStreamHandler(c, resp, info, a.RequestMode)
 	} else {
@@ -218,3 +218,125 @@ func awsStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 	}
 	return nil, &usage
 }
+
+func awsClaudeNativeRequestBody(c *gin.Context) ([]byte, error) {
+	request, ok := c.Get("converted_request")
+	if !ok {
+		return nil, errors.New("request not found")
+	}
+	return json.Marshal(request)
+}
+
+// awsClaudeNativeHandler /v1/messages 请求直接调用 bedrock，响应原样返回
+func awsClaudeNativeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*relaymodel.OpenAIErrorWithStatusCode, *relaymodel.Usage) {
+	awsCli, err := newAwsClient(c, info)
+	if err != nil {
+		return wrapErr(errors.Wrap(err, "newAwsClient")), nil
+	}
+
+	awsModelId, err := awsModelID(c.GetString("request_model"))
+	if err != nil {
+		return wrapErr(errors.Wrap(err, "awsModelID")), nil
+	}
+
+	awsReq := &bedrockruntime.InvokeModelInput{
+		ModelId:     aws.String(awsModelId),
+		Accept:      aws.String("application/json"),
+		ContentType: aws.String("application/json"),
+	}
+	awsReq.Body, err = awsClaudeNativeRequestBody(c)
+	if err != nil {
+		return wrapErr(errors.Wrap(err, "marshal request")), nil
+	}
+
+	awsResp, err := awsCli.InvokeModel(c.Request.Context(), awsReq)
+	if err != nil {
+		return wrapErr(errors.Wrap(err, "InvokeModel")), nil
+	}
+	return claude.ClaudeNativeResponse(c, http.StatusOK, awsResp.Body, info)
+}
+
+// awsClaudeNativeStreamHandler /v1/messages 流式请求直接调用 bedrock，将事件转为 SSE 返回
+func awsClaudeNativeStreamHandler(c *gin.Context, info *relaycommon.RelayInfo) (*relaymodel.OpenAIErrorWithStatusCode, *relaymodel.Usage) {
+	awsCli, err := newAwsClient(c, info)
+	if err != nil {
+		return wrapErr(errors.Wrap(err, "newAwsClient")), nil
+	}
+
+	awsModelId, err := awsModelID(c.GetString("request_model"))
+	if err != nil {
+		return wrapErr(errors.Wrap(err, "awsModelID")), nil
+	}
+
+	awsReq := &bedrockruntime.InvokeModelWithResponseStreamInput{
+		ModelId:     aws.String(awsModelId),
+		Accept:      aws.String("application/json"),
+		ContentType: aws.String("application/json"),
+	}
+	awsReq.Body, err = awsClaudeNativeRequestBody(c)
+	if err != nil {
+		return wrapErr(errors.Wrap(err, "marshal request")), nil
+	}
+
+	awsResp, err := awsCli.InvokeModelWithResponseStream(c.Request.Context(), awsReq)
+	if err != nil {
+		return wrapErr(errors.Wrap(err, "InvokeModelWithResponseStream")), nil
+	}
+	stream := awsResp.GetStream()
+	defer stream.Close()
+
+	service.SetEventStreamHeaders(c)
+	usage := &relaymodel.Usage{}
+	responseText := ""
+	c.Stream(func(w io.Writer) bool {
+		event, ok := <-stream.Events()
+		if !ok {
+			return false
+		}
+
+		switch v := event.(type) {
+		case *types.ResponseStreamMemberChunk:
+			info.SetFirstResponseTime()
+			claudeResp := new(claude.ClaudeResponse)
+			err := json.Unmarshal(v.Value.Bytes, claudeResp)
+			if err != nil {
+				common.SysError("error unmarshalling stream response: " + err.Error())
+				return false
+			}
+			switch claudeResp.Type {
+			case "message_start":
+				if claudeResp.Message != nil {
+					usage.PromptTokens = claudeResp.Message.Usage.PromptTokens()
+					usage.PromptCacheHitTokens = claudeResp.Message.Usage.CacheReadInputTokens
+					usage.PromptTokensDetails.CachedTokens = claudeResp.Message.Usage.CacheReadInputTokens
+				}
+			case "content_block_delta":
+				if claudeResp.Delta != nil {
+					responseText += claudeResp.Delta.Text
+				}
+			case "message_delta":
+				usage.CompletionTokens = claudeResp.Usage.OutputTokens
+			}
+			err = claude.RenderClaudeEvent(w, claudeResp.Type, v.Value.Bytes)
+			if err != nil {
+				common.LogError(c, "send_stream_response_failed: "+err.Error())
+				return false
+			}
+			return true
+		case *types.UnknownUnionMember:
+			fmt.Println("unknown tag:", v.Tag)
+			return false
+		default:
+			fmt.Println("union is nil or unknown type")
+			return false
+		}
+	})
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = info.PromptTokens
+	}
+	if usage.CompletionTokens == 0 {
+		usage, _ = service.ResponseText2Usage(responseText, info.UpstreamModelName, usage.PromptTokens)
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return nil, usage
+}
@@ -0,0 +1,490 @@
+package claude
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func stopReasonOpenAI2Claude(reason string) string {
+	switch reason {
+	case "stop":
+		return "end_turn"
+	case "length", "max_tokens":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	default:
+		return "end_turn"
+	}
+}
+
+func parseClaudeMediaMessages(content any) []ClaudeMediaMessage {
+	if content == nil {
+		return nil
+	}
+	if str, ok := content.(string); ok {
+		return []ClaudeMediaMessage{{Type: "text", Text: str}}
+	}
+	var mediaMessages []ClaudeMediaMessage
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil
+	}
+	_ = json.Unmarshal(data, &mediaMessages)
+	return mediaMessages
+}
+
+// claudeContentText 提取 system / tool_result 等字段中的纯文本
+func claudeContentText(content any) string {
+	text := ""
+	for _, mediaMessage := range parseClaudeMediaMessages(content) {
+		if mediaMessage.Type == "text" {
+			text += mediaMessage.Text
+		}
+	}
+	return text
+}
+
+func claudeMessageContent(mediaMessages []ClaudeMediaMessage) json.RawMessage {
+	if len(mediaMessages) == 1 && mediaMessages[0].Type == "text" {
+		content, _ := json.Marshal(mediaMessages[0].Text)
+		return content
+	}
+	contents := make([]dto.MediaMessage, 0, len(mediaMessages))
+	for _, mediaMessage := range mediaMessages {
+		switch mediaMessage.Type {
+		case "text":
+			contents = append(contents, dto.MediaMessage{
+				Type: dto.ContentTypeText,
+				Text: mediaMessage.Text,
+			})
+		case "image":
+			if mediaMessage.Source == nil {
+				continue
+			}
+			url := mediaMessage.Source.Url
+			if mediaMessage.Source.Type == "base64" {
+				url = fmt.Sprintf("data:%s;base64,%s", mediaMessage.Source.MediaType, mediaMessage.Source.Data)
+			}
+			contents = append(contents, dto.MediaMessage{
+				Type: dto.ContentTypeImageURL,
+				ImageUrl: dto.MessageImageUrl{
+					Url:    url,
+					Detail: "high",
+				},
+			})
+		}
+	}
+	content, _ := json.Marshal(contents)
+	return content
+}
+
+// RequestClaude2OpenAI 将 /v1/messages 请求转换为 chat completions 请求
+func RequestClaude2OpenAI(claudeRequest ClaudeRequest) *dto.GeneralOpenAIRequest {
+	textRequest := dto.GeneralOpenAIRequest{
+		Model:       claudeRequest.Model,
+		Stream:      claudeRequest.Stream,
+		MaxTokens:   claudeRequest.MaxTokens,
+		Temperature: claudeRequest.Temperature,
+		TopP:        claudeRequest.TopP,
+		TopK:        claudeRequest.TopK,
+	}
+	if len(claudeRequest.StopSequences) > 0 {
+		textRequest.Stop = claudeRequest.StopSequences
+	}
+	if claudeRequest.Metadata != nil {
+		textRequest.User = claudeRequest.Metadata.UserId
+	}
+	messages := make([]dto.Message, 0, len(claudeRequest.Messages)+1)
+	if system := claudeContentText(claudeRequest.System); system != "" {
+		message := dto.Message{Role: "system"}
+		message.SetStringContent(system)
+		messages = append(messages, message)
+	}
+	for _, claudeMessage := range claudeRequest.Messages {
+		mediaMessages := parseClaudeMediaMessages(claudeMessage.Content)
+		contents := make([]ClaudeMediaMessage, 0, len(mediaMessages))
+		toolCalls := make([]dto.ToolCall, 0)
+		for _, mediaMessage := range mediaMessages {
+			switch mediaMessage.Type {
+			case "tool_use":
+				arguments, _ := json.Marshal(mediaMessage.Input)
+				toolCalls = append(toolCalls, dto.ToolCall{
+					ID:   mediaMessage.Id,
+					Type: "function",
+					Function: dto.FunctionCall{
+						Name:      mediaMessage.Name,
+						Arguments: string(arguments),
+					},
+				})
+			case "tool_result":
+				// tool_result 需要在其余用户内容之前，作为 tool 消息发送
+				message := dto.Message{
+					Role:       "tool",
+					ToolCallId: mediaMessage.ToolUseId,
+				}
+				message.SetStringContent(claudeContentText(mediaMessage.Content))
+				messages = append(messages, message)
+			case "text", "image":
+				contents = append(contents, mediaMessage)
+			}
+		}
+		if len(contents) == 0 && len(toolCalls) == 0 {
+			continue
+		}
+		message := dto.Message{
+			Role: claudeMessage.Role,
+		}
+		if len(contents) > 0 {
+			message.Content = claudeMessageContent(contents)
+		} else {
+			message.SetStringContent("")
+		}
+		if len(toolCalls) > 0 {
+			message.ToolCalls = toolCalls
+		}
+		messages = append(messages, message)
+	}
+	textRequest.Messages = messages
+
+	for _, tool := range claudeRequest.Tools {
+		textRequest.Tools = append(textRequest.Tools, dto.ToolCall{
+			Type: "function",
+			Function: dto.FunctionCall{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.InputSchema,
+			},
+		})
+	}
+	if toolChoice, ok := claudeRequest.ToolChoice.(map[string]any); ok {
+		switch toolChoice["type"] {
+		case "auto":
+			textRequest.ToolChoice = "auto"
+		case "any":
+			textRequest.ToolChoice = "required"
+		case "none":
+			textRequest.ToolChoice = "none"
+		case "tool":
+			textRequest.ToolChoice = map[string]any{
+				"type": "function",
+				"function": map[string]any{
+					"name": toolChoice["name"],
+				},
+			}
+		}
+	}
+	return &textRequest
+}
+
+// ResponseOpenAI2Claude 将 chat completions 非流式响应转换为 /v1/messages 响应
+func ResponseOpenAI2Claude(textResponse *dto.OpenAITextResponse, model string) *ClaudeMessageResponse {
+	claudeResponse := ClaudeMessageResponse{
+		Id:      textResponse.Id,
+		Type:    "message",
+		Role:    "assistant",
+		Content: make([]ClaudeMediaMessage, 0),
+		Model:   model,
+		Usage: ClaudeUsage{
+			InputTokens:  textResponse.Usage.PromptTokens,
+			OutputTokens: textResponse.Usage.CompletionTokens,
+		},
+	}
+	stopReason := "end_turn"
+	if len(textResponse.Choices) > 0 {
+		choice := textResponse.Choices[0]
+		stopReason = stopReasonOpenAI2Claude(choice.FinishReason)
+		if text := choice.Message.StringContent(); text != "" {
+			claudeResponse.Content = append(claudeResponse.Content, ClaudeMediaMessage{
+				Type: "text",
+				Text: text,
+			})
+		}
+		if choice.Message.ToolCalls != nil {
+			var toolCalls []dto.ToolCall
+			data, _ := json.Marshal(choice.Message.ToolCalls)
+			_ = json.Unmarshal(data, &toolCalls)
+			for _, toolCall := range toolCalls {
+				input := make(map[string]any)
+				_ = json.Unmarshal([]byte(toolCall.Function.Arguments), &input)
+				claudeResponse.Content = append(claudeResponse.Content, ClaudeMediaMessage{
+					Type:  "tool_use",
+					Id:    toolCall.ID,
+					Name:  toolCall.Function.Name,
+					Input: input,
+				})
+			}
+			if len(toolCalls) > 0 {
+				stopReason = "tool_use"
+			}
+		}
+	}
+	claudeResponse.StopReason = &stopReason
+	return &claudeResponse
+}
+
+// OpenAI2ClaudeStreamConverter 将 chat completions 流式响应转换为 /v1/messages 事件
+type OpenAI2ClaudeStreamConverter struct {
+	Id           string
+	Model        string
+	PromptTokens int
+
+	started    bool
+	finished   bool
+	blockIndex int
+	blockOpen  bool
+	blockType  string
+	toolId     string
+	stopReason string
+	usage      *dto.Usage
+}
+
+func (s *OpenAI2ClaudeStreamConverter) start() []ClaudeStreamEvent {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	return []ClaudeStreamEvent{{
+		Type: "message_start",
+		Message: &ClaudeMessageResponse{
+			Id:      s.Id,
+			Type:    "message",
+			Role:    "assistant",
+			Content: make([]ClaudeMediaMessage, 0),
+			Model:   s.Model,
+			Usage: ClaudeUsage{
+				InputTokens: s.PromptTokens,
+			},
+		},
+	}}
+}
+
+func (s *OpenAI2ClaudeStreamConverter) closeBlock() []ClaudeStreamEvent {
+	if !s.blockOpen {
+		return nil
+	}
+	index := s.blockIndex
+	s.blockOpen = false
+	s.blockIndex++
+	return []ClaudeStreamEvent{{Type: "content_block_stop", Index: &index}}
+}
+
+func (s *OpenAI2ClaudeStreamConverter) openBlock(contentBlock map[string]any) []ClaudeStreamEvent {
+	events := s.closeBlock()
+	index := s.blockIndex
+	s.blockOpen = true
+	s.blockType = contentBlock["type"].(string)
+	return append(events, ClaudeStreamEvent{Type: "content_block_start", Index: &index, ContentBlock: contentBlock})
+}
+
+// Convert 处理一个 chat completions chunk，返回需要下发的事件
+func (s *OpenAI2ClaudeStreamConverter) Convert(chunk *dto.ChatCompletionsStreamResponse) []ClaudeStreamEvent {
+	if s.Id == "" {
+		s.Id = chunk.Id
+	}
+	events := s.start()
+	if chunk.Usage != nil && chunk.Usage.TotalTokens != 0 {
+		s.usage = chunk.Usage
+	}
+	for _, choice := range chunk.Choices {
+		if text := choice.Delta.GetContentString(); text != "" {
+			if !s.blockOpen || s.blockType != "text" {
+				events = append(events, s.openBlock(map[string]any{"type": "text", "text": ""})...)
+			}
+			index := s.blockIndex
+			events = append(events, ClaudeStreamEvent{
+				Type:  "content_block_delta",
+				Index: &index,
+				Delta: map[string]any{"type": "text_delta", "text": text},
+			})
+		}
+		for _, toolCall := range choice.Delta.ToolCalls {
+			if !s.blockOpen || s.blockType != "tool_use" || (toolCall.ID != "" && toolCall.ID != s.toolId) {
+				s.toolId = toolCall.ID
+				events = append(events, s.openBlock(map[string]any{
+					"type":  "tool_use",
+					"id":    toolCall.ID,
+					"name":  toolCall.Function.Name,
+					"input": map[string]any{},
+				})...)
+			}
+			if toolCall.Function.Arguments != "" {
+				index := s.blockIndex
+				events = append(events, ClaudeStreamEvent{
+					Type:  "content_block_delta",
+					Index: &index,
+					Delta: map[string]any{"type": "input_json_delta", "partial_json": toolCall.Function.Arguments},
+				})
+			}
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			s.stopReason = stopReasonOpenAI2Claude(*choice.FinishReason)
+		}
+	}
+	return events
+}
+
+// Finish 结束流，outputTokens 为 0 时使用上游下发的 usage
+func (s *OpenAI2ClaudeStreamConverter) Finish(outputTokens int) []ClaudeStreamEvent {
+	if s.finished {
+		return nil
+	}
+	s.finished = true
+	events := s.start()
+	events = append(events, s.closeBlock()...)
+	if s.stopReason == "" {
+		s.stopReason = "end_turn"
+	}
+	if outputTokens == 0 && s.usage != nil {
+		outputTokens = s.usage.CompletionTokens
+	}
+	events = append(events, ClaudeStreamEvent{
+		Type:  "message_delta",
+		Delta: map[string]any{"stop_reason": s.stopReason, "stop_sequence": nil},
+		Usage: &ClaudeUsage{OutputTokens: outputTokens},
+	})
+	return append(events, ClaudeStreamEvent{Type: "message_stop"})
+}
+
+// RenderClaudeEvent 以 event/data 格式写出一个 /v1/messages 流式事件
+func RenderClaudeEvent(w io.Writer, eventType string, data []byte) error {
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, data)
+	return err
+}
+
+// ClaudeNativeStreamHandler 原样转发 Anthropic 格式的流式响应，并从事件中统计 usage
+func ClaudeNativeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	usage := &dto.Usage{}
+	responseText := ""
+	var streamError *ClaudeError
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	scanner.Split(bufio.ScanLines)
+	service.SetEventStreamHeaders(c)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		info.SetFirstResponseTime()
+		_, err := c.Writer.Write([]byte(line + "\n"))
+		if err != nil {
+			common.LogError(c, "send_stream_response_failed: "+err.Error())
+			break
+		}
+		if line == "" {
+			c.Writer.Flush()
+			continue
+		}
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		var claudeResponse ClaudeResponse
+		if err := json.Unmarshal([]byte(data), &claudeResponse); err != nil {
+			continue
+		}
+		switch claudeResponse.Type {
+		case "message_start":
+			if claudeResponse.Message != nil {
+				usage.PromptTokens = claudeResponse.Message.Usage.PromptTokens()
+				usage.PromptCacheHitTokens = claudeResponse.Message.Usage.CacheReadInputTokens
+				usage.PromptTokensDetails.CachedTokens = claudeResponse.Message.Usage.CacheReadInputTokens
+			}
+		case "content_block_delta":
+			if claudeResponse.Delta != nil {
+				responseText += claudeResponse.Delta.Text
+			}
+		case "message_delta":
+			usage.CompletionTokens = claudeResponse.Usage.OutputTokens
+		case "error":
+			streamError = &claudeResponse.Error
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		common.LogError(c, "read_stream_response_failed: "+err.Error())
+	}
+	c.Writer.Flush()
+	resp.Body.Close()
+
+	if streamError != nil {
+		// 流中途出错（如 overloaded_error），错误事件已经转发给客户端；
+		// 按渠道失败处理，不再按已输出的文本估算计费
+		return &dto.OpenAIErrorWithStatusCode{
+			Error: dto.OpenAIError{
+				Message: streamError.Message,
+				Type:    streamError.Type,
+				Code:    streamError.Type,
+			},
+			StatusCode: http.StatusBadGateway,
+		}, nil
+	}
+
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = info.PromptTokens
+	}
+	if usage.CompletionTokens == 0 {
+		usage, _ = service.ResponseText2Usage(responseText, info.UpstreamModelName, usage.PromptTokens)
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return nil, usage
+}
+
+// ClaudeNativeHandler 原样转发 Anthropic 格式的非流式响应
+func ClaudeNativeHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil
+	}
+	return ClaudeNativeResponse(c, resp.StatusCode, responseBody, info)
+}
+
+// ClaudeNativeResponse 校验并写出 Anthropic 格式的响应体，返回其中的 usage
+func ClaudeNativeResponse(c *gin.Context, statusCode int, responseBody []byte, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	var claudeResponse ClaudeResponse
+	err := json.Unmarshal(responseBody, &claudeResponse)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	if claudeResponse.Error.Type != "" {
+		return &dto.OpenAIErrorWithStatusCode{
+			Error: dto.OpenAIError{
+				Message: claudeResponse.Error.Message,
+				Type:    claudeResponse.Error.Type,
+				Param:   "",
+				Code:    claudeResponse.Error.Type,
+			},
+			StatusCode: statusCode,
+		}, nil
+	}
+	usage := dto.Usage{
+		PromptTokens:         claudeResponse.Usage.PromptTokens(),
+		CompletionTokens:     claudeResponse.Usage.OutputTokens,
+		TotalTokens:          claudeResponse.Usage.PromptTokens() + claudeResponse.Usage.OutputTokens,
+		PromptCacheHitTokens: claudeResponse.Usage.CacheReadInputTokens,
+	}
+	usage.PromptTokensDetails.CachedTokens = claudeResponse.Usage.CacheReadInputTokens
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = info.PromptTokens
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(statusCode)
+	_, err = c.Writer.Write(responseBody)
+	if err != nil {
+		common.LogError(c, "write_response_body_failed: "+err.Error())
+	}
+	return nil, &usage
+}
@@ -410,6 +410,9 @@ func ClaudeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 	scanner := bufio.NewScanner(resp.Body)
 	scanner.Split(bufio.ScanLines)
 	service.SetEventStreamHeaders(c)
+	// 收到第一个有效内容前不向客户端写出任何数据，上游在此之前出错时由上层换渠道重试
+	service.BufferStreamUntilContent(c)
+	var streamError ClaudeError
 
 	for scanner.Scan() {
 		data := scanner.Text()
@@ -426,10 +429,19 @@ func ClaudeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 			continue
 		}
 
+		if claudeResponse.Type == "error" {
+			streamError = claudeResponse.Error
+		}
+
 		response, claudeUsage := StreamResponseClaude2OpenAI(requestMode, &claudeResponse)
 		if response == nil {
 			continue
 		}
+		if !service.StreamCommitted(c) && (requestMode == RequestModeCompletion || claudeResponse.Type != "message_start") {
+			if err := service.CommitStream(c); err != nil {
+				common.LogError(c, "send_stream_response_failed: "+err.Error())
+			}
+		}
 		if requestMode == RequestModeCompletion {
 			responseText += claudeResponse.Completion
 			responseId = response.Id
@@ -461,6 +473,24 @@ func ClaudeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 		}
 	}
 
+	if !service.StreamCommitted(c) && !service.IsClientCancelled(c) {
+		// 上游还没有返回任何内容，客户端也没有收到任何数据，返回错误以便重试其他渠道
+		service.DiscardStream(c)
+		resp.Body.Close()
+		if streamError.Type != "" {
+			return &dto.OpenAIErrorWithStatusCode{
+				Error: dto.OpenAIError{
+					Message: streamError.Message,
+					Type:    streamError.Type,
+					Param:   "",
+					Code:    streamError.Type,
+				},
+				StatusCode: http.StatusBadGateway,
+			}, nil
+		}
+		return service.OpenAIErrorWrapper(fmt.Errorf("upstream stream ended before sending any content"), "empty_stream_response", http.StatusBadGateway), nil
+	}
+
 	if requestMode == RequestModeCompletion {
 		usage, _ = service.ResponseText2Usage(responseText, info.UpstreamModelName, info.PromptTokens)
 	} else {
@@ -1,5 +1,7 @@
 package gemini
 
+import "encoding/json"
+
 type GeminiChatRequest struct {
 	Contents           []GeminiChatContent        `json:"contents"`
 	SafetySettings     []GeminiChatSafetySettings `json:"safety_settings,omitempty"`
@@ -8,6 +10,31 @@ type GeminiChatRequest struct {
 	SystemInstructions *GeminiChatContent         `json:"system_instruction,omitempty"`
 }
 
+// UnmarshalJSON 兼容 Google GenAI SDK 使用的驼峰字段名
+func (r *GeminiChatRequest) UnmarshalJSON(data []byte) error {
+	type geminiChatRequest GeminiChatRequest
+	var request struct {
+		geminiChatRequest
+		SafetySettingsCamel    []GeminiChatSafetySettings  `json:"safetySettings,omitempty"`
+		GenerationConfigCamel  *GeminiChatGenerationConfig `json:"generationConfig,omitempty"`
+		SystemInstructionCamel *GeminiChatContent          `json:"systemInstruction,omitempty"`
+	}
+	if err := json.Unmarshal(data, &request); err != nil {
+		return err
+	}
+	*r = GeminiChatRequest(request.geminiChatRequest)
+	if request.SafetySettingsCamel != nil {
+		r.SafetySettings = request.SafetySettingsCamel
+	}
+	if request.GenerationConfigCamel != nil {
+		r.GenerationConfig = *request.GenerationConfigCamel
+	}
+	if request.SystemInstructionCamel != nil {
+		r.SystemInstructions = request.SystemInstructionCamel
+	}
+	return nil
+}
+
 type GeminiInlineData struct {
 	MimeType string `json:"mimeType"`
 	Data     string `json:"data"`
@@ -18,10 +45,22 @@ type FunctionCall struct {
 	Arguments    any    `json:"args"`
 }
 
+type FunctionResponse struct {
+	Name     string `json:"name"`
+	Response any    `json:"response"`
+}
+
+type GeminiFileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileUri  string `json:"fileUri"`
+}
+
 type GeminiPart struct {
-	Text         string            `json:"text,omitempty"`
-	InlineData   *GeminiInlineData `json:"inlineData,omitempty"`
-	FunctionCall *FunctionCall     `json:"functionCall,omitempty"`
+	Text             string            `json:"text,omitempty"`
+	InlineData       *GeminiInlineData `json:"inlineData,omitempty"`
+	FileData         *GeminiFileData   `json:"fileData,omitempty"`
+	FunctionCall     *FunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *FunctionResponse `json:"functionResponse,omitempty"`
 }
 
 type GeminiChatContent struct {
@@ -52,9 +91,9 @@ type GeminiChatGenerationConfig struct {
 
 type GeminiChatCandidate struct {
 	Content       GeminiChatContent        `json:"content"`
-	FinishReason  string                   `json:"finishReason"`
+	FinishReason  string                   `json:"finishReason,omitempty"`
 	Index         int64                    `json:"index"`
-	SafetyRatings []GeminiChatSafetyRating `json:"safetyRatings"`
+	SafetyRatings []GeminiChatSafetyRating `json:"safetyRatings,omitempty"`
 }
 
 type GeminiChatSafetyRating struct {
@@ -67,9 +106,9 @@ type GeminiChatPromptFeedback struct {
 }
 
 type GeminiChatResponse struct {
-	Candidates     []GeminiChatCandidate    `json:"candidates"`
-	PromptFeedback GeminiChatPromptFeedback `json:"promptFeedback"`
-	UsageMetadata  GeminiUsageMetadata      `json:"usageMetadata"`
+	Candidates     []GeminiChatCandidate     `json:"candidates"`
+	PromptFeedback *GeminiChatPromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  GeminiUsageMetadata       `json:"usageMetadata"`
 }
 
 type GeminiUsageMetadata struct {
@@ -0,0 +1,417 @@
+package gemini
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func finishReasonOpenAI2Gemini(reason string) string {
+	switch reason {
+	case "length":
+		return "MAX_TOKENS"
+	case "content_filter":
+		return "SAFETY"
+	default:
+		return "STOP"
+	}
+}
+
+func geminiToolCallId(name string) string {
+	return fmt.Sprintf("call_%s", name)
+}
+
+// RequestGemini2OpenAI 将 generateContent 请求转换为 chat completions 请求
+func RequestGemini2OpenAI(geminiRequest GeminiChatRequest, model string, stream bool) *dto.GeneralOpenAIRequest {
+	config := geminiRequest.GenerationConfig
+	textRequest := dto.GeneralOpenAIRequest{
+		Model:       model,
+		Stream:      stream,
+		MaxTokens:   config.MaxOutputTokens,
+		Temperature: config.Temperature,
+		TopP:        config.TopP,
+		TopK:        int(config.TopK),
+		N:           config.CandidateCount,
+	}
+	if len(config.StopSequences) > 0 {
+		textRequest.Stop = config.StopSequences
+	}
+	if config.ResponseMimeType == "application/json" {
+		if config.ResponseSchema != nil {
+			textRequest.ResponseFormat = &dto.ResponseFormat{
+				Type: "json_schema",
+				JsonSchema: &dto.FormatJsonSchema{
+					Name:   "response",
+					Schema: config.ResponseSchema,
+				},
+			}
+		} else {
+			textRequest.ResponseFormat = &dto.ResponseFormat{Type: "json_object"}
+		}
+	}
+
+	messages := make([]dto.Message, 0, len(geminiRequest.Contents)+1)
+	if geminiRequest.SystemInstructions != nil {
+		var texts []string
+		for _, part := range geminiRequest.SystemInstructions.Parts {
+			if part.Text != "" {
+				texts = append(texts, part.Text)
+			}
+		}
+		if len(texts) > 0 {
+			message := dto.Message{Role: "system"}
+			message.SetStringContent(strings.Join(texts, "\n"))
+			messages = append(messages, message)
+		}
+	}
+	for _, content := range geminiRequest.Contents {
+		role := "user"
+		if content.Role == "model" {
+			role = "assistant"
+		}
+		mediaMessages := make([]dto.MediaMessage, 0, len(content.Parts))
+		toolCalls := make([]dto.ToolCall, 0)
+		for _, part := range content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				arguments, _ := json.Marshal(part.FunctionCall.Arguments)
+				toolCalls = append(toolCalls, dto.ToolCall{
+					ID:   geminiToolCallId(part.FunctionCall.FunctionName),
+					Type: "function",
+					Function: dto.FunctionCall{
+						Name:      part.FunctionCall.FunctionName,
+						Arguments: string(arguments),
+					},
+				})
+			case part.FunctionResponse != nil:
+				response, _ := json.Marshal(part.FunctionResponse.Response)
+				message := dto.Message{
+					Role:       "tool",
+					ToolCallId: geminiToolCallId(part.FunctionResponse.Name),
+				}
+				message.SetStringContent(string(response))
+				messages = append(messages, message)
+			case part.InlineData != nil:
+				mediaMessages = append(mediaMessages, dto.MediaMessage{
+					Type: dto.ContentTypeImageURL,
+					ImageUrl: dto.MessageImageUrl{
+						Url:    fmt.Sprintf("data:%s;base64,%s", part.InlineData.MimeType, part.InlineData.Data),
+						Detail: "high",
+					},
+				})
+			case part.FileData != nil:
+				mediaMessages = append(mediaMessages, dto.MediaMessage{
+					Type: dto.ContentTypeImageURL,
+					ImageUrl: dto.MessageImageUrl{
+						Url:    part.FileData.FileUri,
+						Detail: "high",
+					},
+				})
+			case part.Text != "":
+				mediaMessages = append(mediaMessages, dto.MediaMessage{
+					Type: dto.ContentTypeText,
+					Text: part.Text,
+				})
+			}
+		}
+		if len(mediaMessages) == 0 && len(toolCalls) == 0 {
+			continue
+		}
+		message := dto.Message{Role: role}
+		if len(mediaMessages) == 1 && mediaMessages[0].Type == dto.ContentTypeText {
+			message.SetStringContent(mediaMessages[0].Text)
+		} else if len(mediaMessages) > 0 {
+			message.Content, _ = json.Marshal(mediaMessages)
+		} else {
+			message.SetStringContent("")
+		}
+		if len(toolCalls) > 0 {
+			message.ToolCalls = toolCalls
+		}
+		messages = append(messages, message)
+	}
+	textRequest.Messages = messages
+
+	for _, tool := range geminiRequest.Tools {
+		if tool.FunctionDeclarations == nil {
+			continue
+		}
+		var functions []dto.FunctionCall
+		data, _ := json.Marshal(tool.FunctionDeclarations)
+		_ = json.Unmarshal(data, &functions)
+		for _, function := range functions {
+			textRequest.Tools = append(textRequest.Tools, dto.ToolCall{
+				Type:     "function",
+				Function: function,
+			})
+		}
+	}
+	return &textRequest
+}
+
+func geminiFunctionCallPart(toolCall dto.ToolCall) GeminiPart {
+	var args any
+	_ = json.Unmarshal([]byte(toolCall.Function.Arguments), &args)
+	return GeminiPart{
+		FunctionCall: &FunctionCall{
+			FunctionName: toolCall.Function.Name,
+			Arguments:    args,
+		},
+	}
+}
+
+func geminiUsageMetadata(usage *dto.Usage) GeminiUsageMetadata {
+	return GeminiUsageMetadata{
+		PromptTokenCount:     usage.PromptTokens,
+		CandidatesTokenCount: usage.CompletionTokens,
+		TotalTokenCount:      usage.PromptTokens + usage.CompletionTokens,
+	}
+}
+
+// ResponseOpenAI2Gemini 将 chat completions 非流式响应转换为 generateContent 响应
+func ResponseOpenAI2Gemini(textResponse *dto.OpenAITextResponse) *GeminiChatResponse {
+	geminiResponse := GeminiChatResponse{
+		Candidates:    make([]GeminiChatCandidate, 0, len(textResponse.Choices)),
+		UsageMetadata: geminiUsageMetadata(&textResponse.Usage),
+	}
+	for _, choice := range textResponse.Choices {
+		candidate := GeminiChatCandidate{
+			Content: GeminiChatContent{
+				Role:  "model",
+				Parts: make([]GeminiPart, 0),
+			},
+			FinishReason: finishReasonOpenAI2Gemini(choice.FinishReason),
+			Index:        int64(choice.Index),
+		}
+		if text := choice.Message.StringContent(); text != "" {
+			candidate.Content.Parts = append(candidate.Content.Parts, GeminiPart{Text: text})
+		}
+		if choice.Message.ToolCalls != nil {
+			var toolCalls []dto.ToolCall
+			data, _ := json.Marshal(choice.Message.ToolCalls)
+			_ = json.Unmarshal(data, &toolCalls)
+			for _, toolCall := range toolCalls {
+				candidate.Content.Parts = append(candidate.Content.Parts, geminiFunctionCallPart(toolCall))
+			}
+		}
+		geminiResponse.Candidates = append(geminiResponse.Candidates, candidate)
+	}
+	return &geminiResponse
+}
+
+// OpenAI2GeminiStreamConverter 将 chat completions 流式响应转换为 streamGenerateContent 响应
+// 文本增量直接下发，工具调用的参数需要拼接完整后在最后一个 chunk 中下发
+type OpenAI2GeminiStreamConverter struct {
+	finishReason string
+	toolCalls    map[int]*dto.ToolCall
+}
+
+// Convert 处理一个 chat completions chunk，没有需要下发的内容时返回 nil
+func (s *OpenAI2GeminiStreamConverter) Convert(chunk *dto.ChatCompletionsStreamResponse) *GeminiChatResponse {
+	var parts []GeminiPart
+	for _, choice := range chunk.Choices {
+		if text := choice.Delta.GetContentString(); text != "" {
+			parts = append(parts, GeminiPart{Text: text})
+		}
+		for i, toolCall := range choice.Delta.ToolCalls {
+			index := i
+			if toolCall.Index != nil {
+				index = *toolCall.Index
+			}
+			if s.toolCalls == nil {
+				s.toolCalls = make(map[int]*dto.ToolCall)
+			}
+			if existing, ok := s.toolCalls[index]; ok {
+				existing.Function.Arguments += toolCall.Function.Arguments
+			} else {
+				toolCall := toolCall
+				s.toolCalls[index] = &toolCall
+			}
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			s.finishReason = *choice.FinishReason
+		}
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	return &GeminiChatResponse{
+		Candidates: []GeminiChatCandidate{{
+			Content: GeminiChatContent{
+				Role:  "model",
+				Parts: parts,
+			},
+		}},
+	}
+}
+
+// Finish 生成最后一个 chunk，包含工具调用、结束原因和 usage
+func (s *OpenAI2GeminiStreamConverter) Finish(usage *dto.Usage) *GeminiChatResponse {
+	parts := make([]GeminiPart, 0, len(s.toolCalls))
+	indexes := make([]int, 0, len(s.toolCalls))
+	for index := range s.toolCalls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		parts = append(parts, geminiFunctionCallPart(*s.toolCalls[index]))
+	}
+	response := &GeminiChatResponse{
+		Candidates: []GeminiChatCandidate{{
+			Content: GeminiChatContent{
+				Role:  "model",
+				Parts: parts,
+			},
+			FinishReason: finishReasonOpenAI2Gemini(s.finishReason),
+		}},
+	}
+	if usage != nil {
+		response.UsageMetadata = geminiUsageMetadata(usage)
+	}
+	return response
+}
+
+// GeminiStreamWriter 按客户端请求的格式写出流式响应：alt=sse 时为 SSE，否则为 JSON 数组
+type GeminiStreamWriter struct {
+	writer io.Writer
+	sse    bool
+	count  int
+}
+
+func NewGeminiStreamWriter(c *gin.Context, writer io.Writer) *GeminiStreamWriter {
+	return &GeminiStreamWriter{
+		writer: writer,
+		sse:    c.Query("alt") == "sse",
+	}
+}
+
+// SetHeaders 设置与输出格式对应的响应头，需要在第一次写出之前调用
+func (s *GeminiStreamWriter) SetHeaders(c *gin.Context) {
+	service.SetEventStreamHeaders(c)
+	if !s.sse {
+		c.Writer.Header().Set("Content-Type", "application/json")
+	}
+}
+
+func (s *GeminiStreamWriter) Write(data []byte) error {
+	var err error
+	if s.sse {
+		_, err = fmt.Fprintf(s.writer, "data: %s\r\n\r\n", data)
+	} else if s.count == 0 {
+		_, err = fmt.Fprintf(s.writer, "[%s", data)
+	} else {
+		_, err = fmt.Fprintf(s.writer, ",\r\n%s", data)
+	}
+	s.count++
+	return err
+}
+
+func (s *GeminiStreamWriter) Close() error {
+	if s.sse {
+		return nil
+	}
+	if s.count == 0 {
+		_, err := io.WriteString(s.writer, "[]")
+		return err
+	}
+	_, err := io.WriteString(s.writer, "]")
+	return err
+}
+
+// GeminiNativeStreamHandler 原样转发 Gemini 格式的流式响应，并从 usageMetadata 中统计 usage
+func GeminiNativeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	responseText := ""
+	usage := &dto.Usage{}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	scanner.Split(bufio.ScanLines)
+
+	streamWriter := NewGeminiStreamWriter(c, c.Writer)
+	streamWriter.SetHeaders(c)
+	for scanner.Scan() {
+		data := strings.TrimSpace(scanner.Text())
+		info.SetFirstResponseTime()
+		if !strings.HasPrefix(data, "data: ") {
+			continue
+		}
+		data = strings.TrimPrefix(data, "data: ")
+		var geminiResponse GeminiChatResponse
+		err := json.Unmarshal([]byte(data), &geminiResponse)
+		if err != nil {
+			common.LogError(c, "error unmarshalling stream response: "+err.Error())
+			continue
+		}
+		for _, candidate := range geminiResponse.Candidates {
+			for _, part := range candidate.Content.Parts {
+				responseText += part.Text
+			}
+		}
+		if geminiResponse.UsageMetadata.TotalTokenCount != 0 {
+			usage.PromptTokens = geminiResponse.UsageMetadata.PromptTokenCount
+			usage.CompletionTokens = geminiResponse.UsageMetadata.CandidatesTokenCount
+		}
+		err = streamWriter.Write([]byte(data))
+		if err != nil {
+			common.LogError(c, "send_stream_response_failed: "+err.Error())
+			break
+		}
+		c.Writer.Flush()
+	}
+	if err := streamWriter.Close(); err != nil {
+		common.LogError(c, "send_stream_response_failed: "+err.Error())
+	}
+	c.Writer.Flush()
+	resp.Body.Close()
+
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = info.PromptTokens
+	}
+	if usage.CompletionTokens == 0 {
+		usage, _ = service.ResponseText2Usage(responseText, info.UpstreamModelName, usage.PromptTokens)
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return nil, usage
+}
+
+// GeminiNativeHandler 原样转发 Gemini 格式的非流式响应
+func GeminiNativeHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil
+	}
+	var geminiResponse GeminiChatResponse
+	err = json.Unmarshal(responseBody, &geminiResponse)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	usage := dto.Usage{
+		PromptTokens:     geminiResponse.UsageMetadata.PromptTokenCount,
+		CompletionTokens: geminiResponse.UsageMetadata.CandidatesTokenCount,
+		TotalTokens:      geminiResponse.UsageMetadata.TotalTokenCount,
+	}
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = info.PromptTokens
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(resp.StatusCode)
+	_, err = c.Writer.Write(responseBody)
+	if err != nil {
+		common.LogError(c, "write_response_body_failed: "+err.Error())
+	}
+	return nil, &usage
+}
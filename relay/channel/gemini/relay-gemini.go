@@ -325,6 +325,8 @@ func GeminiChatStreamHandler(c *gin.Context, resp *http.Response, info *relaycom
 	scanner.Split(bufio.ScanLines)
 
 	service.SetEventStreamHeaders(c)
+	// 收到第一个有效内容前不向客户端写出任何数据，上游在此之前出错时由上层换渠道重试
+	service.BufferStreamUntilContent(c)
 	for scanner.Scan() {
 		data := scanner.Text()
 		info.SetFirstResponseTime()
@@ -348,6 +350,11 @@ func GeminiChatStreamHandler(c *gin.Context, resp *http.Response, info *relaycom
 		response.Id = id
 		response.Created = createAt
 		responseText += response.Choices[0].Delta.GetContentString()
+		if !service.StreamCommitted(c) {
+			if err := service.CommitStream(c); err != nil {
+				common.LogError(c, err.Error())
+			}
+		}
 		if geminiResponse.UsageMetadata.TotalTokenCount != 0 {
 			usage.PromptTokens = geminiResponse.UsageMetadata.PromptTokenCount
 			usage.CompletionTokens = geminiResponse.UsageMetadata.CandidatesTokenCount
@@ -358,9 +365,20 @@ func GeminiChatStreamHandler(c *gin.Context, resp *http.Response, info *relaycom
 		}
 	}
 
+	if !service.StreamCommitted(c) && !service.IsClientCancelled(c) {
+		// 上游还没有返回任何内容，客户端也没有收到任何数据，返回错误以便重试其他渠道
+		service.DiscardStream(c)
+		resp.Body.Close()
+		return service.OpenAIErrorWrapper(fmt.Errorf("upstream stream ended before sending any content"), "empty_stream_response", http.StatusBadGateway), nil
+	}
+
 	response := service.GenerateStopResponse(id, createAt, info.UpstreamModelName, constant.FinishReasonStop)
 	service.ObjectData(c, response)
 
+	if usage.CompletionTokens == 0 && responseText != "" {
+		// 客户端中途断开时上游可能还未返回用量，按已生成的内容计算
+		usage, _ = service.ResponseText2Usage(responseText, info.UpstreamModelName, info.PromptTokens)
+	}
 	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
 
 	if info.ShouldIncludeUsage {
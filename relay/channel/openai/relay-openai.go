@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -37,6 +38,67 @@ func sendStreamData(c *gin.Context, data string, forceFormat bool) error {
 	return service.StringData(c, data)
 }
 
+// isStreamContentData 流式数据块是否包含需要发给客户端的内容，只有角色信息的首个数据块和上游的错误信息不算
+func isStreamContentData(data string, relayMode int) bool {
+	if relayMode == relayconstant.RelayModeCompletions {
+		var streamResponse dto.CompletionsStreamResponse
+		if err := json.Unmarshal(common.StringToByteSlice(data), &streamResponse); err != nil {
+			return true
+		}
+		for _, choice := range streamResponse.Choices {
+			if choice.Text != "" || choice.FinishReason != "" {
+				return true
+			}
+		}
+		return false
+	}
+	// delta 中除 role 外的任意字段（content、reasoning_content、tool_calls 等）都算作内容
+	var streamResponse struct {
+		Choices []struct {
+			Delta        map[string]any `json:"delta"`
+			FinishReason *string        `json:"finish_reason"`
+		} `json:"choices"`
+		Usage *dto.Usage `json:"usage"`
+	}
+	if err := json.Unmarshal(common.StringToByteSlice(data), &streamResponse); err != nil {
+		return true
+	}
+	if service.ValidUsage(streamResponse.Usage) {
+		return true
+	}
+	for _, choice := range streamResponse.Choices {
+		if choice.FinishReason != nil {
+			return true
+		}
+		for key, value := range choice.Delta {
+			if key != "role" && value != nil && value != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// streamNoContentError 上游在返回任何内容前结束或超时，优先使用上游返回的错误信息
+func streamNoContentError(lastStreamData string, streamTimeout bool) *dto.OpenAIErrorWithStatusCode {
+	if streamTimeout {
+		return service.OpenAIErrorWrapper(fmt.Errorf("upstream stream timed out before sending any content"), "stream_timeout", http.StatusBadGateway)
+	}
+	var errResponse dto.GeneralErrorResponse
+	if err := json.Unmarshal(common.StringToByteSlice(lastStreamData), &errResponse); err == nil {
+		if errResponse.Error.Message != "" {
+			return &dto.OpenAIErrorWithStatusCode{
+				Error:      errResponse.Error,
+				StatusCode: http.StatusBadGateway,
+			}
+		}
+		if message := errResponse.ToMessage(); message != "" {
+			return service.OpenAIErrorWrapper(errors.New(message), "empty_stream_response", http.StatusBadGateway)
+		}
+	}
+	return service.OpenAIErrorWrapper(fmt.Errorf("upstream stream ended before sending any content"), "empty_stream_response", http.StatusBadGateway)
+}
+
 func OaiStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
 	if resp == nil || resp.Body == nil {
 		common.LogError(c, "invalid response or response body")
@@ -65,6 +127,8 @@ func OaiStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 	scanner.Split(bufio.ScanLines)
 
 	service.SetEventStreamHeaders(c)
+	// 收到第一个有效内容前不向客户端写出任何数据，上游在此之前出错或超时时由上层换渠道重试
+	service.BufferStreamUntilContent(c)
 
 	ticker := time.NewTicker(time.Duration(constant.StreamingTimeout) * time.Second)
 	defer ticker.Stop()
@@ -89,6 +153,11 @@ func OaiStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 			mu.Lock()
 			data = data[6:]
 			if !strings.HasPrefix(data, "[DONE]") {
+				if !service.StreamCommitted(c) && isStreamContentData(data, info.RelayMode) {
+					if err := service.CommitStream(c); err != nil {
+						common.LogError(c, "streaming error: "+err.Error())
+					}
+				}
 				if lastStreamData != "" {
 					err := sendStreamData(c, lastStreamData, forceFormat)
 					if err != nil {
@@ -103,12 +172,33 @@ func OaiStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 		common.SafeSendBool(stopChan, true)
 	})
 
+	streamTimeout := false
 	select {
 	case <-ticker.C:
 		// 超时处理逻辑
 		common.LogError(c, "streaming timeout")
+		streamTimeout = true
 	case <-stopChan:
 		// 正常结束
+	case <-c.Request.Context().Done():
+		// 客户端断开连接，停止读取上游，按已生成的内容计费
+		common.LogInfo(c, "client disconnected, stop reading upstream stream")
+		resp.Body.Close()
+		<-stopChan
+	}
+
+	mu.Lock()
+	committed := service.StreamCommitted(c)
+	mu.Unlock()
+	if !committed && !service.IsClientCancelled(c) {
+		// 上游还没有返回任何内容，客户端也没有收到任何数据，返回错误以便重试其他渠道
+		resp.Body.Close()
+		if streamTimeout {
+			// 等待读取协程退出后再恢复 Writer，避免超时后到达的数据直接写给客户端
+			<-stopChan
+		}
+		service.DiscardStream(c)
+		return streamNoContentError(lastStreamData, streamTimeout), nil
 	}
 
 	shouldSendLastResp := true
@@ -0,0 +1,521 @@
+package openai
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResponsesResponseKey 透传 /v1/responses 时在 context 中保存最终响应对象的 key
+const ResponsesResponseKey = "responses_response"
+
+func responsesMessageContent(item dto.ResponsesItem) json.RawMessage {
+	contents := item.ParseContent()
+	onlyText := true
+	for _, content := range contents {
+		if content.Type != "input_text" && content.Type != "output_text" && content.Type != "text" {
+			onlyText = false
+			break
+		}
+	}
+	if onlyText || item.Role == "assistant" {
+		var text strings.Builder
+		for _, content := range contents {
+			text.WriteString(content.Text)
+		}
+		data, _ := json.Marshal(text.String())
+		return data
+	}
+	mediaMessages := make([]dto.MediaMessage, 0, len(contents))
+	for _, content := range contents {
+		switch content.Type {
+		case "input_text", "output_text", "text":
+			mediaMessages = append(mediaMessages, dto.MediaMessage{
+				Type: dto.ContentTypeText,
+				Text: content.Text,
+			})
+		case "input_image":
+			mediaMessages = append(mediaMessages, dto.MediaMessage{
+				Type: dto.ContentTypeImageURL,
+				ImageUrl: dto.MessageImageUrl{
+					Url:    content.ImageUrl,
+					Detail: "auto",
+				},
+			})
+		}
+	}
+	data, _ := json.Marshal(mediaMessages)
+	return data
+}
+
+// RequestResponses2OpenAI 将 /v1/responses 请求转换为 chat completions 请求，items 为已展开上下文后的完整输入
+func RequestResponses2OpenAI(request dto.ResponsesRequest, items []dto.ResponsesItem, model string) *dto.GeneralOpenAIRequest {
+	textRequest := &dto.GeneralOpenAIRequest{
+		Model:       model,
+		Stream:      request.Stream,
+		MaxTokens:   request.MaxOutputTokens,
+		Temperature: request.Temperature,
+		TopP:        request.TopP,
+		User:        request.User,
+		ToolChoice:  request.ToolChoice,
+	}
+	if request.Reasoning != nil {
+		textRequest.ReasoningEffort = request.Reasoning.Effort
+	}
+	if request.Instructions != "" {
+		message := dto.Message{Role: "system"}
+		message.SetStringContent(request.Instructions)
+		textRequest.Messages = append(textRequest.Messages, message)
+	}
+
+	var toolCalls []dto.ToolCall
+	for _, item := range items {
+		switch item.Type {
+		case "", "message":
+			role := item.Role
+			if role == "developer" {
+				role = "system"
+			}
+			textRequest.Messages = append(textRequest.Messages, dto.Message{
+				Role:    role,
+				Content: responsesMessageContent(item),
+			})
+			toolCalls = nil
+		case "function_call":
+			// 连续的 function_call 合并到同一条 assistant 消息中
+			last := len(textRequest.Messages) - 1
+			if toolCalls == nil && (last < 0 || textRequest.Messages[last].Role != "assistant") {
+				textRequest.Messages = append(textRequest.Messages, dto.Message{Role: "assistant"})
+				last++
+			}
+			toolCalls = append(toolCalls, dto.ToolCall{
+				ID:   item.CallId,
+				Type: "function",
+				Function: dto.FunctionCall{
+					Name:      item.Name,
+					Arguments: item.Arguments,
+				},
+			})
+			textRequest.Messages[last].ToolCalls = toolCalls
+		case "function_call_output":
+			message := dto.Message{
+				Role:       "tool",
+				ToolCallId: item.CallId,
+			}
+			message.SetStringContent(item.Output)
+			textRequest.Messages = append(textRequest.Messages, message)
+			toolCalls = nil
+		}
+	}
+
+	for _, tool := range request.Tools {
+		if tool.Type != "function" {
+			continue
+		}
+		textRequest.Tools = append(textRequest.Tools, dto.ToolCall{
+			Type: "function",
+			Function: dto.FunctionCall{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	if toolChoice, ok := request.ToolChoice.(map[string]any); ok && toolChoice["type"] == "function" {
+		textRequest.ToolChoice = map[string]any{
+			"type": "function",
+			"function": map[string]any{
+				"name": toolChoice["name"],
+			},
+		}
+	}
+	if request.Text != nil && request.Text.Format != nil {
+		switch request.Text.Format.Type {
+		case "json_schema":
+			textRequest.ResponseFormat = &dto.ResponseFormat{
+				Type: "json_schema",
+				JsonSchema: &dto.FormatJsonSchema{
+					Name:   request.Text.Format.Name,
+					Schema: request.Text.Format.Schema,
+					Strict: request.Text.Format.Strict,
+				},
+			}
+		case "json_object":
+			textRequest.ResponseFormat = &dto.ResponseFormat{Type: "json_object"}
+		}
+	}
+	return textRequest
+}
+
+func responsesUsage(usage dto.Usage) *dto.ResponsesUsage {
+	responsesUsage := &dto.ResponsesUsage{
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+		TotalTokens:  usage.PromptTokens + usage.CompletionTokens,
+	}
+	if usage.PromptTokensDetails.CachedTokens > 0 {
+		responsesUsage.InputTokensDetails = &struct {
+			CachedTokens int `json:"cached_tokens"`
+		}{CachedTokens: usage.PromptTokensDetails.CachedTokens}
+	}
+	return responsesUsage
+}
+
+func responsesStatus(response *dto.ResponsesResponse, finishReason string) {
+	response.Status = "completed"
+	if finishReason == "length" {
+		response.Status = "incomplete"
+		response.IncompleteDetails = map[string]string{"reason": "max_output_tokens"}
+	}
+}
+
+func responsesTextItem(text string, status string) dto.ResponsesItem {
+	content, _ := json.Marshal([]dto.ResponsesContent{{Type: "output_text", Text: text, Annotations: []any{}}})
+	return dto.ResponsesItem{
+		Type:    "message",
+		Id:      "msg_" + common.GetUUID(),
+		Status:  status,
+		Role:    "assistant",
+		Content: content,
+	}
+}
+
+// ResponseOpenAI2Responses 将 chat completions 非流式响应转换为 /v1/responses 响应
+func ResponseOpenAI2Responses(textResponse *dto.OpenAITextResponse, model string) *dto.ResponsesResponse {
+	response := &dto.ResponsesResponse{
+		Id:        "resp_" + common.GetUUID(),
+		Object:    "response",
+		CreatedAt: textResponse.Created,
+		Model:     model,
+		Output:    make([]dto.ResponsesItem, 0),
+		Usage:     responsesUsage(textResponse.Usage),
+	}
+	if response.CreatedAt == 0 {
+		response.CreatedAt = common.GetTimestamp()
+	}
+	finishReason := ""
+	if len(textResponse.Choices) > 0 {
+		choice := textResponse.Choices[0]
+		finishReason = choice.FinishReason
+		if text := choice.Message.StringContent(); len(choice.Message.Content) > 0 && text != "" && text != "null" {
+			response.Output = append(response.Output, responsesTextItem(text, "completed"))
+		}
+		if choice.Message.ToolCalls != nil {
+			var toolCalls []dto.ToolCall
+			data, _ := json.Marshal(choice.Message.ToolCalls)
+			_ = json.Unmarshal(data, &toolCalls)
+			for _, toolCall := range toolCalls {
+				response.Output = append(response.Output, dto.ResponsesItem{
+					Type:      "function_call",
+					Id:        "fc_" + common.GetUUID(),
+					Status:    "completed",
+					CallId:    toolCall.ID,
+					Name:      toolCall.Function.Name,
+					Arguments: toolCall.Function.Arguments,
+				})
+			}
+		}
+	}
+	responsesStatus(response, finishReason)
+	return response
+}
+
+// OpenAI2ResponsesStreamConverter 将 chat completions 流式响应转换为 /v1/responses 流式事件
+type OpenAI2ResponsesStreamConverter struct {
+	Id        string
+	Model     string
+	CreatedAt int64
+
+	sequence     int
+	started      bool
+	output       []dto.ResponsesItem
+	textIndex    int
+	text         strings.Builder
+	toolIndexes  map[int]int
+	finishReason string
+}
+
+func NewOpenAI2ResponsesStreamConverter(model string) *OpenAI2ResponsesStreamConverter {
+	return &OpenAI2ResponsesStreamConverter{
+		Id:          "resp_" + common.GetUUID(),
+		Model:       model,
+		CreatedAt:   common.GetTimestamp(),
+		textIndex:   -1,
+		toolIndexes: make(map[int]int),
+	}
+}
+
+func (s *OpenAI2ResponsesStreamConverter) event(event dto.ResponsesStreamEvent) dto.ResponsesStreamEvent {
+	event.SequenceNumber = s.sequence
+	s.sequence++
+	return event
+}
+
+func (s *OpenAI2ResponsesStreamConverter) response(status string) *dto.ResponsesResponse {
+	output := make([]dto.ResponsesItem, len(s.output))
+	copy(output, s.output)
+	return &dto.ResponsesResponse{
+		Id:        s.Id,
+		Object:    "response",
+		CreatedAt: s.CreatedAt,
+		Status:    status,
+		Model:     s.Model,
+		Output:    output,
+	}
+}
+
+func (s *OpenAI2ResponsesStreamConverter) start() []dto.ResponsesStreamEvent {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	return []dto.ResponsesStreamEvent{
+		s.event(dto.ResponsesStreamEvent{Type: "response.created", Response: s.response("in_progress")}),
+		s.event(dto.ResponsesStreamEvent{Type: "response.in_progress", Response: s.response("in_progress")}),
+	}
+}
+
+func (s *OpenAI2ResponsesStreamConverter) addItem(item dto.ResponsesItem) (int, dto.ResponsesStreamEvent) {
+	index := len(s.output)
+	s.output = append(s.output, item)
+	return index, s.event(dto.ResponsesStreamEvent{Type: "response.output_item.added", OutputIndex: &index, Item: &item})
+}
+
+func (s *OpenAI2ResponsesStreamConverter) closeText() []dto.ResponsesStreamEvent {
+	if s.textIndex < 0 {
+		return nil
+	}
+	index := s.textIndex
+	contentIndex := 0
+	text := s.text.String()
+	item := responsesTextItem(text, "completed")
+	item.Id = s.output[index].Id
+	s.output[index] = item
+	s.textIndex = -1
+	s.text.Reset()
+	return []dto.ResponsesStreamEvent{
+		s.event(dto.ResponsesStreamEvent{Type: "response.output_text.done", ItemId: item.Id, OutputIndex: &index, ContentIndex: &contentIndex, Text: &text}),
+		s.event(dto.ResponsesStreamEvent{Type: "response.content_part.done", ItemId: item.Id, OutputIndex: &index, ContentIndex: &contentIndex, Part: &dto.ResponsesContent{Type: "output_text", Text: text, Annotations: []any{}}}),
+		s.event(dto.ResponsesStreamEvent{Type: "response.output_item.done", OutputIndex: &index, Item: &item}),
+	}
+}
+
+func (s *OpenAI2ResponsesStreamConverter) closeToolCalls() []dto.ResponsesStreamEvent {
+	var events []dto.ResponsesStreamEvent
+	for i := range s.output {
+		if s.output[i].Type != "function_call" || s.output[i].Status == "completed" {
+			continue
+		}
+		index := i
+		s.output[i].Status = "completed"
+		item := s.output[i]
+		events = append(events,
+			s.event(dto.ResponsesStreamEvent{Type: "response.function_call_arguments.done", ItemId: item.Id, OutputIndex: &index, Arguments: &item.Arguments}),
+			s.event(dto.ResponsesStreamEvent{Type: "response.output_item.done", OutputIndex: &index, Item: &item}),
+		)
+	}
+	return events
+}
+
+func (s *OpenAI2ResponsesStreamConverter) Convert(chunk *dto.ChatCompletionsStreamResponse) []dto.ResponsesStreamEvent {
+	events := s.start()
+	for _, choice := range chunk.Choices {
+		if choice.Delta.GetContentString() != "" {
+			if s.textIndex < 0 {
+				var event dto.ResponsesStreamEvent
+				item := dto.ResponsesItem{
+					Type:    "message",
+					Id:      "msg_" + common.GetUUID(),
+					Status:  "in_progress",
+					Role:    "assistant",
+					Content: json.RawMessage("[]"),
+				}
+				s.textIndex, event = s.addItem(item)
+				index := s.textIndex
+				contentIndex := 0
+				events = append(events, event, s.event(dto.ResponsesStreamEvent{
+					Type:         "response.content_part.added",
+					ItemId:       item.Id,
+					OutputIndex:  &index,
+					ContentIndex: &contentIndex,
+					Part:         &dto.ResponsesContent{Type: "output_text", Text: "", Annotations: []any{}},
+				}))
+			}
+			index := s.textIndex
+			contentIndex := 0
+			s.text.WriteString(choice.Delta.GetContentString())
+			events = append(events, s.event(dto.ResponsesStreamEvent{
+				Type:         "response.output_text.delta",
+				ItemId:       s.output[index].Id,
+				OutputIndex:  &index,
+				ContentIndex: &contentIndex,
+				Delta:        choice.Delta.GetContentString(),
+			}))
+		}
+		for _, toolCall := range choice.Delta.ToolCalls {
+			toolIndex := 0
+			if toolCall.Index != nil {
+				toolIndex = *toolCall.Index
+			}
+			index, ok := s.toolIndexes[toolIndex]
+			if !ok {
+				events = append(events, s.closeText()...)
+				var event dto.ResponsesStreamEvent
+				index, event = s.addItem(dto.ResponsesItem{
+					Type:   "function_call",
+					Id:     "fc_" + common.GetUUID(),
+					Status: "in_progress",
+					CallId: toolCall.ID,
+					Name:   toolCall.Function.Name,
+				})
+				s.toolIndexes[toolIndex] = index
+				events = append(events, event)
+			}
+			if toolCall.Function.Arguments != "" {
+				s.output[index].Arguments += toolCall.Function.Arguments
+				events = append(events, s.event(dto.ResponsesStreamEvent{
+					Type:        "response.function_call_arguments.delta",
+					ItemId:      s.output[index].Id,
+					OutputIndex: &index,
+					Delta:       toolCall.Function.Arguments,
+				}))
+			}
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			s.finishReason = *choice.FinishReason
+		}
+	}
+	return events
+}
+
+// Finish 结束所有输出项并生成 response.completed 事件
+func (s *OpenAI2ResponsesStreamConverter) Finish(usage *dto.Usage) []dto.ResponsesStreamEvent {
+	events := s.start()
+	events = append(events, s.closeText()...)
+	events = append(events, s.closeToolCalls()...)
+	response := s.Response()
+	if usage != nil {
+		response.Usage = responsesUsage(*usage)
+	}
+	eventType := "response.completed"
+	if response.Status == "incomplete" {
+		eventType = "response.incomplete"
+	}
+	return append(events, s.event(dto.ResponsesStreamEvent{Type: eventType, Response: response}))
+}
+
+// Response 当前已生成的完整响应
+func (s *OpenAI2ResponsesStreamConverter) Response() *dto.ResponsesResponse {
+	response := s.response("")
+	responsesStatus(response, s.finishReason)
+	return response
+}
+
+func RenderResponsesEvent(w io.Writer, event dto.ResponsesStreamEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
+
+// OaiResponsesStreamHandler 原样转发 /v1/responses 流式响应，从 response.completed 事件中获取最终响应和 usage
+func OaiResponsesStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	usage := &dto.Usage{}
+	responseText := ""
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	scanner.Split(bufio.ScanLines)
+	service.SetEventStreamHeaders(c)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		info.SetFirstResponseTime()
+		_, err := c.Writer.Write([]byte(line + "\n"))
+		if err != nil {
+			common.LogError(c, "send_stream_response_failed: "+err.Error())
+			break
+		}
+		if line == "" {
+			c.Writer.Flush()
+			continue
+		}
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		var event dto.ResponsesStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			continue
+		}
+		switch event.Type {
+		case "response.output_text.delta":
+			responseText += event.Delta
+		case "response.completed", "response.incomplete", "response.failed":
+			if event.Response != nil {
+				c.Set(ResponsesResponseKey, event.Response)
+				if event.Response.Usage != nil {
+					usage = event.Response.Usage.ToUsage()
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		common.LogError(c, "read_stream_response_failed: "+err.Error())
+	}
+	c.Writer.Flush()
+	resp.Body.Close()
+
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = info.PromptTokens
+	}
+	if usage.CompletionTokens == 0 {
+		usage, _ = service.ResponseText2Usage(responseText, info.UpstreamModelName, usage.PromptTokens)
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return nil, usage
+}
+
+// OaiResponsesHandler 原样转发 /v1/responses 非流式响应
+func OaiResponsesHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil
+	}
+	var response dto.ResponsesResponse
+	err = json.Unmarshal(responseBody, &response)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	c.Set(ResponsesResponseKey, &response)
+
+	for k, v := range resp.Header {
+		c.Writer.Header().Set(k, v[0])
+	}
+	c.Writer.WriteHeader(resp.StatusCode)
+	_, err = c.Writer.Write(responseBody)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "write_response_body_failed", http.StatusInternalServerError), nil
+	}
+
+	usage := &dto.Usage{PromptTokens: info.PromptTokens}
+	if response.Usage != nil {
+		usage = response.Usage.ToUsage()
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return nil, usage
+}
@@ -11,6 +11,7 @@ import (
 	"one-api/relay/channel/openai"
 	relaycommon "one-api/relay/common"
 	"one-api/relay/constant"
+	"one-api/service"
 )
 
 type Adaptor struct {
@@ -22,8 +23,25 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 }
 
 func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
-	//TODO implement me
-	return nil, errors.New("not implemented")
+	c.Set("response_format", request.ResponseFormat)
+	sfRequest := &SFImageRequest{
+		Model:     request.Model,
+		Prompt:    request.Prompt,
+		ImageSize: request.Size,
+		BatchSize: request.N,
+	}
+	if info.RelayMode == constant.RelayModeImagesEdits || info.RelayMode == constant.RelayModeImagesVariations {
+		// 编辑和变体均按图生图处理
+		images, err := service.GetFormImagesBase64(c, "image")
+		if err != nil {
+			return nil, err
+		}
+		if len(images) == 0 {
+			return nil, errors.New("image is required")
+		}
+		sfRequest.Image = images[0]
+	}
+	return sfRequest, nil
 }
 
 func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
@@ -36,6 +54,8 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 		return fmt.Sprintf("%s/v1/embeddings", info.BaseUrl), nil
 	} else if info.RelayMode == constant.RelayModeChatCompletions {
 		return fmt.Sprintf("%s/v1/chat/completions", info.BaseUrl), nil
+	} else if info.RelayMode == constant.RelayModeImagesGenerations || info.RelayMode == constant.RelayModeImagesEdits || info.RelayMode == constant.RelayModeImagesVariations {
+		return fmt.Sprintf("%s/v1/images/generations", info.BaseUrl), nil
 	}
 	return "", errors.New("invalid relay mode")
 }
@@ -70,6 +90,8 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 		}
 	case constant.RelayModeEmbeddings:
 		err, usage = openai.OpenaiHandler(c, resp, info.PromptTokens, info.UpstreamModelName)
+	case constant.RelayModeImagesGenerations, constant.RelayModeImagesEdits, constant.RelayModeImagesVariations:
+		err, usage = siliconflowImageHandler(c, resp, info)
 	}
 	return
 }
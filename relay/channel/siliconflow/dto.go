@@ -15,3 +15,18 @@ type SFRerankResponse struct {
 	Results []dto.RerankResponseDocument `json:"results"`
 	Meta    SFMeta                       `json:"meta"`
 }
+
+type SFImageRequest struct {
+	Model     string `json:"model"`
+	Prompt    string `json:"prompt"`
+	ImageSize string `json:"image_size,omitempty"`
+	BatchSize int    `json:"batch_size,omitempty"`
+	Image     string `json:"image,omitempty"`
+}
+
+type SFImageResponse struct {
+	Images []struct {
+		Url string `json:"url"`
+	} `json:"images"`
+	Seed int64 `json:"seed"`
+}
@@ -14,6 +14,7 @@ import (
 	"one-api/relay/channel/gemini"
 	"one-api/relay/channel/openai"
 	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
 	"strings"
 )
 
@@ -147,6 +148,33 @@ func (a *Adaptor) ConvertRequest(c *gin.Context, info *relaycommon.RelayInfo, re
 	return nil, errors.New("unsupported request mode")
 }
 
+func (a *Adaptor) SupportClaudeMessages(info *relaycommon.RelayInfo) bool {
+	return strings.HasPrefix(info.UpstreamModelName, "claude")
+}
+
+func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayInfo, request map[string]any) (any, error) {
+	if request == nil {
+		return nil, errors.New("request is nil")
+	}
+	// vertex 的模型在 url 中指定
+	delete(request, "model")
+	request["anthropic_version"] = anthropicVersion
+	c.Set("request_model", info.UpstreamModelName)
+	return request, nil
+}
+
+func (a *Adaptor) SupportGeminiContent(info *relaycommon.RelayInfo) bool {
+	return strings.HasPrefix(info.UpstreamModelName, "gemini")
+}
+
+func (a *Adaptor) ConvertGeminiRequest(c *gin.Context, info *relaycommon.RelayInfo, request map[string]any) (any, error) {
+	if request == nil {
+		return nil, errors.New("request is nil")
+	}
+	c.Set("request_model", info.UpstreamModelName)
+	return request, nil
+}
+
 func (a *Adaptor) ConvertRerankRequest(c *gin.Context, relayMode int, request dto.RerankRequest) (any, error) {
 	return nil, nil
 }
@@ -156,6 +184,22 @@ func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, request
 }
 
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *dto.OpenAIErrorWithStatusCode) {
+	if info.RelayMode == relayconstant.RelayModeClaudeMessages {
+		if info.IsStream {
+			err, usage = claude.ClaudeNativeStreamHandler(c, resp, info)
+		} else {
+			err, usage = claude.ClaudeNativeHandler(c, resp, info)
+		}
+		return
+	}
+	if info.RelayMode == relayconstant.RelayModeGemini {
+		if info.IsStream {
+			err, usage = gemini.GeminiNativeStreamHandler(c, resp, info)
+		} else {
+			err, usage = gemini.GeminiNativeHandler(c, resp, info)
+		}
+		return
+	}
 	if info.IsStream {
 		switch a.RequestMode {
 		case RequestModeClaude:
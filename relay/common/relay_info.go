@@ -11,6 +11,9 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// RelayInfoKey 当前请求的 RelayInfo 在 gin.Context 中的 key
+const RelayInfoKey = "relay_info"
+
 type RelayInfo struct {
 	ChannelType          int
 	ChannelId            int
@@ -19,6 +22,7 @@ type RelayInfo struct {
 	UserId               int
 	Group                string
 	TokenUnlimited       bool
+	TokenRemainQuota     int // 令牌鉴权时的剩余额度，用于判断令牌额度是否在本次请求中用尽
 	StartTime            time.Time
 	FirstResponseTime    time.Time
 	setFirstResponse     bool
@@ -82,6 +86,7 @@ func GenRelayInfo(c *gin.Context) *RelayInfo {
 		UserId:            userId,
 		Group:             group,
 		TokenUnlimited:    tokenUnlimited,
+		TokenRemainQuota:  c.GetInt("token_quota"),
 		StartTime:         startTime,
 		FirstResponseTime: startTime.Add(-time.Second),
 		OriginModelName:   c.GetString("original_model"),
@@ -106,6 +111,7 @@ func GenRelayInfo(c *gin.Context) *RelayInfo {
 	if info.ChannelType == common.ChannelTypeVertexAi {
 		info.ApiVersion = c.GetString("region")
 	}
+	c.Set(RelayInfoKey, info)
 	if info.ChannelType == common.ChannelTypeOpenAI || info.ChannelType == common.ChannelTypeAnthropic ||
 		info.ChannelType == common.ChannelTypeAws || info.ChannelType == common.ChannelTypeGemini ||
 		info.ChannelType == common.ChannelCloudflare {
@@ -122,6 +128,14 @@ func (info *RelayInfo) SetIsStream(isStream bool) {
 	info.IsStream = isStream
 }
 
+// GetFirstResponseLatency 获取首字延迟，未收到流式响应时返回到目前为止的总耗时
+func (info *RelayInfo) GetFirstResponseLatency() time.Duration {
+	if info.FirstResponseTime.After(info.StartTime) {
+		return info.FirstResponseTime.Sub(info.StartTime)
+	}
+	return time.Since(info.StartTime)
+}
+
 func (info *RelayInfo) SetFirstResponseTime() {
 	if !info.setFirstResponse {
 		info.FirstResponseTime = time.Now()
@@ -133,6 +147,8 @@ type TaskRelayInfo struct {
 	ChannelType       int
 	ChannelId         int
 	TokenId           int
+	TokenUnlimited    bool
+	TokenRemainQuota  int
 	UserId            int
 	Group             string
 	StartTime         time.Time
@@ -161,17 +177,19 @@ func GenTaskRelayInfo(c *gin.Context) *TaskRelayInfo {
 	apiType, _ := constant.ChannelType2APIType(channelType)
 
 	info := &TaskRelayInfo{
-		RelayMode:      constant.Path2RelayMode(c.Request.URL.Path),
-		BaseUrl:        c.GetString("base_url"),
-		RequestURLPath: c.Request.URL.String(),
-		ChannelType:    channelType,
-		ChannelId:      channelId,
-		TokenId:        tokenId,
-		UserId:         userId,
-		Group:          group,
-		StartTime:      startTime,
-		ApiType:        apiType,
-		ApiKey:         strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer "),
+		RelayMode:        constant.Path2RelayMode(c.Request.URL.Path),
+		BaseUrl:          c.GetString("base_url"),
+		RequestURLPath:   c.Request.URL.String(),
+		ChannelType:      channelType,
+		ChannelId:        channelId,
+		TokenId:          tokenId,
+		TokenUnlimited:   c.GetBool("token_unlimited_quota"),
+		TokenRemainQuota: c.GetInt("token_quota"),
+		UserId:           userId,
+		Group:            group,
+		StartTime:        startTime,
+		ApiType:          apiType,
+		ApiKey:           strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer "),
 	}
 	if info.BaseUrl == "" {
 		info.BaseUrl = common.ChannelBaseURLs[channelType]
@@ -184,6 +202,8 @@ func (info *TaskRelayInfo) ToRelayInfo() *RelayInfo {
 		ChannelType:       info.ChannelType,
 		ChannelId:         info.ChannelId,
 		TokenId:           info.TokenId,
+		TokenUnlimited:    info.TokenUnlimited,
+		TokenRemainQuota:  info.TokenRemainQuota,
 		UserId:            info.UserId,
 		Group:             info.Group,
 		StartTime:         info.StartTime,
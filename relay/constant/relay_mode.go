@@ -40,6 +40,15 @@ const (
 	RelayModeRerank
 
 	RelayModeRealtime
+
+	RelayModeClaudeMessages
+
+	RelayModeGemini
+
+	RelayModeResponses
+
+	RelayModeImagesEdits
+	RelayModeImagesVariations
 )
 
 func Path2RelayMode(path string) int {
@@ -56,6 +65,10 @@ func Path2RelayMode(path string) int {
 		relayMode = RelayModeModerations
 	} else if strings.HasPrefix(path, "/v1/images/generations") {
 		relayMode = RelayModeImagesGenerations
+	} else if strings.HasPrefix(path, "/v1/images/edits") {
+		relayMode = RelayModeImagesEdits
+	} else if strings.HasPrefix(path, "/v1/images/variations") {
+		relayMode = RelayModeImagesVariations
 	} else if strings.HasPrefix(path, "/v1/edits") {
 		relayMode = RelayModeEdits
 	} else if strings.HasPrefix(path, "/v1/audio/speech") {
@@ -68,10 +81,23 @@ func Path2RelayMode(path string) int {
 		relayMode = RelayModeRerank
 	} else if strings.HasPrefix(path, "/v1/realtime") {
 		relayMode = RelayModeRealtime
+	} else if strings.HasPrefix(path, "/v1/messages") {
+		relayMode = RelayModeClaudeMessages
+	} else if strings.HasPrefix(path, "/v1beta/models/") {
+		relayMode = RelayModeGemini
+	} else if strings.HasPrefix(path, "/v1/responses") {
+		relayMode = RelayModeResponses
 	}
 	return relayMode
 }
 
+// Path2GeminiModelAction 解析 /v1beta/models/{model}:{action} 中的模型名称和动作
+func Path2GeminiModelAction(path string) (string, string) {
+	path = strings.TrimPrefix(path, "/v1beta/models/")
+	modelName, action, _ := strings.Cut(path, ":")
+	return modelName, action
+}
+
 func Path2RelayModeMidjourney(path string) int {
 	relayMode := RelayModeUnknown
 	if strings.HasSuffix(path, "/mj/submit/action") {
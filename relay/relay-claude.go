@@ -0,0 +1,124 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/relay/channel"
+	"one-api/relay/channel/claude"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func getAndValidateClaudeRequest(c *gin.Context, relayInfo *relaycommon.RelayInfo) (*claude.ClaudeRequest, error) {
+	claudeRequest := &claude.ClaudeRequest{}
+	err := common.UnmarshalBodyReusable(c, claudeRequest)
+	if err != nil {
+		return nil, err
+	}
+	if claudeRequest.Model == "" {
+		return nil, errors.New("model is required")
+	}
+	if len(claudeRequest.Messages) == 0 {
+		return nil, errors.New("field messages is required")
+	}
+	if claudeRequest.MaxTokens > math.MaxInt32/2 {
+		return nil, errors.New("max_tokens is invalid")
+	}
+	relayInfo.IsStream = claudeRequest.Stream
+	return claudeRequest, nil
+}
+
+// ClaudeHelper 处理 Anthropic /v1/messages 请求
+// 原生支持的渠道（Anthropic、AWS、Vertex Claude）直接透传，其余渠道转换为 chat completions 后再将响应转换回来
+func ClaudeHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
+	relayInfo := relaycommon.GenRelayInfo(c)
+
+	claudeRequest, err := getAndValidateClaudeRequest(c, relayInfo)
+	if err != nil {
+		common.LogError(c, fmt.Sprintf("getAndValidateClaudeRequest failed: %s", err.Error()))
+		return service.OpenAIErrorWrapperLocal(err, "invalid_claude_request", http.StatusBadRequest)
+	}
+
+	// map model name
+	modelMapping := c.GetString("model_mapping")
+	if modelMapping != "" && modelMapping != "{}" {
+		modelMap := make(map[string]string)
+		err := json.Unmarshal([]byte(modelMapping), &modelMap)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "unmarshal_model_mapping_failed", http.StatusInternalServerError)
+		}
+		if modelMap[claudeRequest.Model] != "" {
+			claudeRequest.Model = modelMap[claudeRequest.Model]
+		}
+	}
+	relayInfo.UpstreamModelName = claudeRequest.Model
+	return relayNativeRequest(c, relayInfo, &nativeRelayRequest{
+		modelName:   claudeRequest.Model,
+		maxTokens:   int(claudeRequest.MaxTokens),
+		textRequest: claude.RequestClaude2OpenAI(*claudeRequest),
+		nativeConverter: func(adaptor channel.Adaptor, info *relaycommon.RelayInfo) nativeRequestConverter {
+			claudeAdaptor, ok := adaptor.(channel.ClaudeAdaptor)
+			if !ok || !claudeAdaptor.SupportClaudeMessages(info) {
+				return nil
+			}
+			return claudeAdaptor.ConvertClaudeRequest
+		},
+		newResponseConverter: func(info *relaycommon.RelayInfo) responseConverter {
+			return newClaudeResponseConverter(info)
+		},
+	})
+}
+
+// claudeResponseConverter 将 chat completions 响应转换为 Anthropic 格式
+type claudeResponseConverter struct {
+	model  string
+	stream *claude.OpenAI2ClaudeStreamConverter
+}
+
+func newClaudeResponseConverter(info *relaycommon.RelayInfo) *claudeResponseConverter {
+	return &claudeResponseConverter{
+		model: info.OriginModelName,
+		stream: &claude.OpenAI2ClaudeStreamConverter{
+			Model:        info.OriginModelName,
+			PromptTokens: info.PromptTokens,
+		},
+	}
+}
+
+func (r *claudeResponseConverter) writeEvents(w io.Writer, events []claude.ClaudeStreamEvent) error {
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		err = claude.RenderClaudeEvent(w, event.Type, data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *claudeResponseConverter) ConvertStreamChunk(w io.Writer, chunk *dto.ChatCompletionsStreamResponse) error {
+	return r.writeEvents(w, r.stream.Convert(chunk))
+}
+
+func (r *claudeResponseConverter) FinishStream(w io.Writer, usage *dto.Usage) error {
+	outputTokens := 0
+	if usage != nil {
+		outputTokens = usage.CompletionTokens
+	}
+	return r.writeEvents(w, r.stream.Finish(outputTokens))
+}
+
+func (r *claudeResponseConverter) ConvertResponse(textResponse *dto.OpenAITextResponse) ([]byte, error) {
+	return json.Marshal(claude.ResponseOpenAI2Claude(textResponse, r.model))
+}
@@ -0,0 +1,131 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/relay/channel"
+	"one-api/relay/channel/gemini"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func getAndValidateGeminiRequest(c *gin.Context, relayInfo *relaycommon.RelayInfo) (*gemini.GeminiChatRequest, error) {
+	geminiRequest := &gemini.GeminiChatRequest{}
+	err := common.UnmarshalBodyReusable(c, geminiRequest)
+	if err != nil {
+		return nil, err
+	}
+	if len(geminiRequest.Contents) == 0 {
+		return nil, errors.New("field contents is required")
+	}
+	if geminiRequest.GenerationConfig.MaxOutputTokens > math.MaxInt32/2 {
+		return nil, errors.New("maxOutputTokens is invalid")
+	}
+	_, action := relayconstant.Path2GeminiModelAction(c.Request.URL.Path)
+	switch action {
+	case "generateContent":
+		relayInfo.IsStream = false
+	case "streamGenerateContent":
+		relayInfo.IsStream = true
+	default:
+		return nil, fmt.Errorf("unsupported action: %s", action)
+	}
+	return geminiRequest, nil
+}
+
+// GeminiHelper 处理 Gemini /v1beta/models/{model}:generateContent 请求
+// 原生支持的渠道（Gemini、Vertex Gemini）直接透传，其余渠道转换为 chat completions 后再将响应转换回来
+func GeminiHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
+	relayInfo := relaycommon.GenRelayInfo(c)
+
+	geminiRequest, err := getAndValidateGeminiRequest(c, relayInfo)
+	if err != nil {
+		common.LogError(c, fmt.Sprintf("getAndValidateGeminiRequest failed: %s", err.Error()))
+		return service.OpenAIErrorWrapperLocal(err, "invalid_gemini_request", http.StatusBadRequest)
+	}
+
+	// map model name
+	modelName := relayInfo.OriginModelName
+	modelMapping := c.GetString("model_mapping")
+	if modelMapping != "" && modelMapping != "{}" {
+		modelMap := make(map[string]string)
+		err := json.Unmarshal([]byte(modelMapping), &modelMap)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "unmarshal_model_mapping_failed", http.StatusInternalServerError)
+		}
+		if modelMap[modelName] != "" {
+			modelName = modelMap[modelName]
+		}
+	}
+	relayInfo.UpstreamModelName = modelName
+	textRequest := gemini.RequestGemini2OpenAI(*geminiRequest, modelName, relayInfo.IsStream)
+	return relayNativeRequest(c, relayInfo, &nativeRelayRequest{
+		modelName:   modelName,
+		maxTokens:   int(textRequest.MaxTokens),
+		textRequest: textRequest,
+		nativeConverter: func(adaptor channel.Adaptor, info *relaycommon.RelayInfo) nativeRequestConverter {
+			geminiAdaptor, ok := adaptor.(channel.GeminiAdaptor)
+			if !ok || !geminiAdaptor.SupportGeminiContent(info) {
+				return nil
+			}
+			return geminiAdaptor.ConvertGeminiRequest
+		},
+		newResponseConverter: func(info *relaycommon.RelayInfo) responseConverter {
+			return newGeminiResponseConverter(c)
+		},
+	})
+}
+
+// geminiResponseConverter 将 chat completions 响应转换为 Gemini 格式
+type geminiResponseConverter struct {
+	c      *gin.Context
+	stream *gemini.OpenAI2GeminiStreamConverter
+	writer *gemini.GeminiStreamWriter
+}
+
+func newGeminiResponseConverter(c *gin.Context) *geminiResponseConverter {
+	return &geminiResponseConverter{
+		c:      c,
+		stream: &gemini.OpenAI2GeminiStreamConverter{},
+	}
+}
+
+func (r *geminiResponseConverter) write(w io.Writer, response *gemini.GeminiChatResponse) error {
+	if r.writer == nil {
+		r.writer = gemini.NewGeminiStreamWriter(r.c, w)
+		r.writer.SetHeaders(r.c)
+	}
+	if response == nil {
+		return nil
+	}
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return r.writer.Write(data)
+}
+
+func (r *geminiResponseConverter) ConvertStreamChunk(w io.Writer, chunk *dto.ChatCompletionsStreamResponse) error {
+	return r.write(w, r.stream.Convert(chunk))
+}
+
+func (r *geminiResponseConverter) FinishStream(w io.Writer, usage *dto.Usage) error {
+	err := r.write(w, r.stream.Finish(usage))
+	if err != nil {
+		return err
+	}
+	return r.writer.Close()
+}
+
+func (r *geminiResponseConverter) ConvertResponse(textResponse *dto.OpenAITextResponse) ([]byte, error) {
+	return json.Marshal(gemini.ResponseOpenAI2Gemini(textResponse))
+}
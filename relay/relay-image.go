@@ -13,18 +13,51 @@ import (
 	"one-api/dto"
 	"one-api/model"
 	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
 	"one-api/service"
+	"strconv"
 	"strings"
 )
 
 func getAndValidImageRequest(c *gin.Context, info *relaycommon.RelayInfo) (*dto.ImageRequest, error) {
 	imageRequest := &dto.ImageRequest{}
-	err := common.UnmarshalBodyReusable(c, imageRequest)
-	if err != nil {
-		return nil, err
-	}
-	if imageRequest.Prompt == "" {
-		return nil, errors.New("prompt is required")
+	if info.RelayMode == relayconstant.RelayModeImagesEdits || info.RelayMode == relayconstant.RelayModeImagesVariations {
+		// edits、variations 为 multipart 请求
+		err := c.Request.ParseMultipartForm(32 << 20)
+		if err != nil {
+			return nil, err
+		}
+		formData := c.Request.MultipartForm
+		if len(formData.File["image"]) == 0 && len(formData.File["image[]"]) == 0 {
+			return nil, errors.New("image is required")
+		}
+		imageRequest.Model = c.Request.FormValue("model")
+		imageRequest.Prompt = c.Request.FormValue("prompt")
+		imageRequest.Size = c.Request.FormValue("size")
+		imageRequest.Quality = c.Request.FormValue("quality")
+		imageRequest.ResponseFormat = c.Request.FormValue("response_format")
+		imageRequest.User = c.Request.FormValue("user")
+		if n := c.Request.FormValue("n"); n != "" {
+			imageRequest.N, err = strconv.Atoi(n)
+			if err != nil {
+				return nil, errors.New("n must be an integer")
+			}
+			// edits、variations 最多生成 10 张
+			if imageRequest.N < 1 || imageRequest.N > 10 {
+				return nil, errors.New("n must be between 1 and 10")
+			}
+		}
+		if info.RelayMode == relayconstant.RelayModeImagesEdits && imageRequest.Prompt == "" {
+			return nil, errors.New("prompt is required")
+		}
+	} else {
+		err := common.UnmarshalBodyReusable(c, imageRequest)
+		if err != nil {
+			return nil, err
+		}
+		if imageRequest.Prompt == "" {
+			return nil, errors.New("prompt is required")
+		}
 	}
 	if strings.Contains(imageRequest.Size, "×") {
 		return nil, errors.New("size an unexpected error occurred in the parameter, please use 'x' instead of the multiplication sign '×'")
@@ -32,6 +65,10 @@ func getAndValidImageRequest(c *gin.Context, info *relaycommon.RelayInfo) (*dto.
 	if imageRequest.N == 0 {
 		imageRequest.N = 1
 	}
+	// 按 n 计费，负数会产生负的额度
+	if imageRequest.N < 0 {
+		return nil, errors.New("n must be a positive integer")
+	}
 	if imageRequest.Size == "" {
 		imageRequest.Size = "1024x1024"
 	}
@@ -59,7 +96,7 @@ func getAndValidImageRequest(c *gin.Context, info *relaycommon.RelayInfo) (*dto.
 	//if imageRequest.N != 0 && (imageRequest.N < 1 || imageRequest.N > 10) {
 	//	return service.OpenAIErrorWrapper(errors.New("n must be between 1 and 10"), "invalid_field_value", http.StatusBadRequest)
 	//}
-	if constant.ShouldCheckPromptSensitive() {
+	if constant.ShouldCheckPromptSensitive() && imageRequest.Prompt != "" {
 		err := service.CheckSensitiveInput(imageRequest.Prompt)
 		if err != nil {
 			return nil, err
@@ -142,15 +179,20 @@ func ImageHelper(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode {
 		return service.OpenAIErrorWrapperLocal(err, "convert_request_failed", http.StatusInternalServerError)
 	}
 
-	jsonData, err := json.Marshal(convertedRequest)
-	if err != nil {
-		return service.OpenAIErrorWrapperLocal(err, "json_marshal_failed", http.StatusInternalServerError)
+	if reader, ok := convertedRequest.(io.Reader); ok {
+		// multipart 请求体
+		requestBody = reader
+	} else {
+		jsonData, err := json.Marshal(convertedRequest)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "json_marshal_failed", http.StatusInternalServerError)
+		}
+		requestBody = bytes.NewBuffer(jsonData)
 	}
-	requestBody = bytes.NewBuffer(jsonData)
 
 	statusCodeMappingStr := c.GetString("status_code_mapping")
 
-	resp, err := adaptor.DoRequest(c, relayInfo, requestBody)
+	resp, err := doRequest(c, adaptor, relayInfo, requestBody)
 	if err != nil {
 		return service.OpenAIErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
 	}
@@ -166,7 +208,7 @@ func ImageHelper(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode {
 		}
 	}
 
-	_, openaiErr := adaptor.DoResponse(c, httpResp, relayInfo)
+	_, openaiErr := doResponse(c, adaptor, httpResp, relayInfo)
 	if openaiErr != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(openaiErr, statusCodeMappingStr)
@@ -0,0 +1,208 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/relay/channel"
+	"one-api/relay/channel/openai"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func getAndValidateResponsesRequest(c *gin.Context, relayInfo *relaycommon.RelayInfo) (*dto.ResponsesRequest, error) {
+	responsesRequest := &dto.ResponsesRequest{}
+	err := common.UnmarshalBodyReusable(c, responsesRequest)
+	if err != nil {
+		return nil, err
+	}
+	if responsesRequest.Model == "" {
+		return nil, errors.New("model is required")
+	}
+	if len(responsesRequest.Input) == 0 {
+		return nil, errors.New("field input is required")
+	}
+	if responsesRequest.MaxOutputTokens > math.MaxInt32/2 {
+		return nil, errors.New("max_output_tokens is invalid")
+	}
+	relayInfo.IsStream = responsesRequest.Stream
+	return responsesRequest, nil
+}
+
+// getResponsesHistory 沿 previous_response_id 向前拼接出本地保存的对话上下文
+func getResponsesHistory(c *gin.Context, previousResponseId string) ([]dto.ResponsesItem, error) {
+	if previousResponseId == "" {
+		return nil, nil
+	}
+	chain, err := model.GetResponseChain(c.GetInt("id"), previousResponseId)
+	if err != nil {
+		return nil, fmt.Errorf("previous response with id '%s' not found", previousResponseId)
+	}
+	var items []dto.ResponsesItem
+	for _, response := range chain {
+		var turnItems []dto.ResponsesItem
+		if err := json.Unmarshal([]byte(response.Items), &turnItems); err != nil {
+			return nil, err
+		}
+		items = append(items, turnItems...)
+	}
+	return items, nil
+}
+
+// saveResponsesHistory 保存本轮的 input 和 output，后续请求可通过 previous_response_id 续接
+// reasoning 等上游私有的 item 不保存，item id 也会被清除，保证任意渠道都能接受
+func saveResponsesHistory(c *gin.Context, relayInfo *relaycommon.RelayInfo, request *dto.ResponsesRequest, inputItems []dto.ResponsesItem, response *dto.ResponsesResponse) {
+	if response == nil || response.Id == "" {
+		return
+	}
+	history := make([]dto.ResponsesItem, 0, len(inputItems)+len(response.Output))
+	for _, item := range append(inputItems, response.Output...) {
+		if item.Type != "" && item.Type != "message" && item.Type != "function_call" && item.Type != "function_call_output" {
+			continue
+		}
+		item.Id = ""
+		item.Status = ""
+		history = append(history, item)
+	}
+	data, err := json.Marshal(history)
+	if err != nil {
+		common.LogError(c, "marshal responses history failed: "+err.Error())
+		return
+	}
+	record := &model.Response{
+		ResponseId:         response.Id,
+		PreviousResponseId: request.PreviousResponseId,
+		UserId:             relayInfo.UserId,
+		TokenId:            relayInfo.TokenId,
+		ChannelId:          relayInfo.ChannelId,
+		Model:              relayInfo.OriginModelName,
+		Items:              string(data),
+		CreatedAt:          common.GetTimestamp(),
+	}
+	if err := record.Insert(); err != nil {
+		common.LogError(c, "save responses history failed: "+err.Error())
+	}
+}
+
+// ResponsesHelper 处理 OpenAI /v1/responses 请求
+// OpenAI 渠道直接透传，其余渠道转换为 chat completions 后再将响应转换回来
+// previous_response_id 统一在本地展开，因此重试切换渠道后仍能续接上下文
+func ResponsesHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
+	relayInfo := relaycommon.GenRelayInfo(c)
+
+	responsesRequest, err := getAndValidateResponsesRequest(c, relayInfo)
+	if err != nil {
+		common.LogError(c, fmt.Sprintf("getAndValidateResponsesRequest failed: %s", err.Error()))
+		return service.OpenAIErrorWrapperLocal(err, "invalid_responses_request", http.StatusBadRequest)
+	}
+	inputItems, err := responsesRequest.ParseInput()
+	if err != nil {
+		return service.OpenAIErrorWrapperLocal(err, "invalid_responses_request", http.StatusBadRequest)
+	}
+	history, err := getResponsesHistory(c, responsesRequest.PreviousResponseId)
+	if err != nil {
+		return service.OpenAIErrorWrapperLocal(err, "previous_response_not_found", http.StatusBadRequest)
+	}
+	items := append(history, inputItems...)
+
+	// map model name
+	modelMapping := c.GetString("model_mapping")
+	if modelMapping != "" && modelMapping != "{}" {
+		modelMap := make(map[string]string)
+		err := json.Unmarshal([]byte(modelMapping), &modelMap)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "unmarshal_model_mapping_failed", http.StatusInternalServerError)
+		}
+		if modelMap[responsesRequest.Model] != "" {
+			responsesRequest.Model = modelMap[responsesRequest.Model]
+		}
+	}
+	relayInfo.UpstreamModelName = responsesRequest.Model
+	return relayNativeRequest(c, relayInfo, &nativeRelayRequest{
+		modelName:   responsesRequest.Model,
+		maxTokens:   int(responsesRequest.MaxOutputTokens),
+		textRequest: openai.RequestResponses2OpenAI(*responsesRequest, items, responsesRequest.Model),
+		nativeConverter: func(adaptor channel.Adaptor, info *relaycommon.RelayInfo) nativeRequestConverter {
+			responsesAdaptor, ok := adaptor.(channel.ResponsesAdaptor)
+			if !ok || !responsesAdaptor.SupportResponses(info) {
+				return nil
+			}
+			return func(c *gin.Context, info *relaycommon.RelayInfo, request map[string]any) (any, error) {
+				if responsesRequest.PreviousResponseId != "" {
+					delete(request, "previous_response_id")
+					request["input"] = items
+				}
+				return responsesAdaptor.ConvertResponsesRequest(c, info, request)
+			}
+		},
+		newResponseConverter: func(info *relaycommon.RelayInfo) responseConverter {
+			return newResponsesResponseConverter(info, responsesRequest.PreviousResponseId)
+		},
+		afterResponse: func(converter responseConverter) {
+			var response *dto.ResponsesResponse
+			if converter != nil {
+				response = converter.(*responsesResponseConverter).response
+			} else if value, exists := c.Get(openai.ResponsesResponseKey); exists {
+				response, _ = value.(*dto.ResponsesResponse)
+			}
+			if responsesRequest.ShouldStore() {
+				saveResponsesHistory(c, relayInfo, responsesRequest, inputItems, response)
+			}
+		},
+	})
+}
+
+// responsesResponseConverter 将 chat completions 响应转换为 /v1/responses 格式，并记录最终响应用于保存上下文
+type responsesResponseConverter struct {
+	model              string
+	previousResponseId string
+	stream             *openai.OpenAI2ResponsesStreamConverter
+	response           *dto.ResponsesResponse
+}
+
+func newResponsesResponseConverter(info *relaycommon.RelayInfo, previousResponseId string) *responsesResponseConverter {
+	return &responsesResponseConverter{
+		model:              info.OriginModelName,
+		previousResponseId: previousResponseId,
+		stream:             openai.NewOpenAI2ResponsesStreamConverter(info.OriginModelName),
+	}
+}
+
+func (r *responsesResponseConverter) writeEvents(w io.Writer, events []dto.ResponsesStreamEvent) error {
+	for _, event := range events {
+		if event.Response != nil && r.previousResponseId != "" {
+			event.Response.PreviousResponseId = &r.previousResponseId
+		}
+		err := openai.RenderResponsesEvent(w, event)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *responsesResponseConverter) ConvertStreamChunk(w io.Writer, chunk *dto.ChatCompletionsStreamResponse) error {
+	return r.writeEvents(w, r.stream.Convert(chunk))
+}
+
+func (r *responsesResponseConverter) FinishStream(w io.Writer, usage *dto.Usage) error {
+	events := r.stream.Finish(usage)
+	r.response = events[len(events)-1].Response
+	return r.writeEvents(w, events)
+}
+
+func (r *responsesResponseConverter) ConvertResponse(textResponse *dto.OpenAITextResponse) ([]byte, error) {
+	r.response = openai.ResponseOpenAI2Responses(textResponse, r.model)
+	if r.previousResponseId != "" {
+		r.response.PreviousResponseId = &r.previousResponseId
+	}
+	return json.Marshal(r.response)
+}
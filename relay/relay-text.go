@@ -21,6 +21,7 @@ import (
 	"github.com/bytedance/sonic"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func getAndValidateTextRequest(c *gin.Context, relayInfo *relaycommon.RelayInfo) (*dto.GeneralOpenAIRequest, error) {
@@ -75,6 +76,8 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 		common.LogError(c, fmt.Sprintf("getAndValidateTextRequest failed: %s", err.Error()))
 		return service.OpenAIErrorWrapperLocal(err, "invalid_text_request", http.StatusBadRequest)
 	}
+	// 在模型映射之前计算缓存 key，使缓存与所选渠道无关
+	cacheKey := getResponseCacheKey(c, relayInfo, textRequest)
 
 	// map model name
 	//isModelMapped := false
@@ -137,6 +140,20 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 			returnPreConsumedQuota(c, relayInfo, userQuota, preConsumedQuota)
 		}
 	}()
+	// 命中缓存时同样需要先通过额度检查，预扣的额度在结算时按缓存计费多退少补
+	var cacheWriter *responseCacheWriter
+	if cacheKey != "" {
+		if entry, ok := service.GetResponseCache(cacheKey); ok {
+			replayResponseCache(c, relayInfo, textRequest.Model, entry, preConsumedQuota, userQuota, ratio, modelRatio, groupRatio, modelPrice, getModelPriceSuccess)
+			return nil
+		}
+		c.Header("X-Cache", "MISS")
+		cacheWriter = newResponseCacheWriter(c.Writer)
+		c.Writer = cacheWriter
+		defer func() {
+			c.Writer = cacheWriter.ResponseWriter
+		}()
+	}
 	includeUsage := false
 	// 判断用户是否需要返回使用情况
 	if textRequest.StreamOptions != nil && textRequest.StreamOptions.IncludeUsage {
@@ -188,7 +205,7 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 
 	statusCodeMappingStr := c.GetString("status_code_mapping")
 	var httpResp *http.Response
-	resp, err := adaptor.DoRequest(c, relayInfo, requestBody)
+	resp, err := doRequest(c, adaptor, relayInfo, requestBody)
 	if err != nil {
 		return service.OpenAIErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
 	}
@@ -204,7 +221,7 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 		}
 	}
 
-	usage, openaiErr := adaptor.DoResponse(c, httpResp, relayInfo)
+	usage, openaiErr := doResponse(c, adaptor, httpResp, relayInfo)
 	if openaiErr != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(openaiErr, statusCodeMappingStr)
@@ -216,6 +233,13 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 	} else {
 		postConsumeQuota(c, relayInfo, textRequest.Model, usage.(*dto.Usage), ratio, preConsumedQuota, userQuota, modelRatio, groupRatio, modelPrice, getModelPriceSuccess, "")
 	}
+	if cacheWriter != nil && cacheWriter.cacheable() && usage.(*dto.Usage) != nil && !service.IsClientCancelled(c) {
+		service.SetResponseCache(cacheKey, &service.ResponseCacheEntry{
+			ContentType: cacheWriter.Header().Get("Content-Type"),
+			Body:        cacheWriter.buffer.Bytes(),
+			Usage:       *usage.(*dto.Usage),
+		})
+	}
 	return nil
 }
 
@@ -256,6 +280,8 @@ func checkRequestSensitive(textRequest *dto.GeneralOpenAIRequest, info *relaycom
 
 // 预扣费并返回用户剩余配额
 func preConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommon.RelayInfo) (int, int, *dto.OpenAIErrorWithStatusCode) {
+	_, span := common.StartSpan(c, "quota.pre_consume", attribute.Int("quota.pre_consumed", preConsumedQuota))
+	defer span.End()
 	userQuota, err := model.CacheGetUserQuota(relayInfo.UserId)
 	if err != nil {
 		return 0, 0, service.OpenAIErrorWrapperLocal(err, "get_user_quota_failed", http.StatusInternalServerError)
@@ -312,6 +338,13 @@ func returnPreConsumedQuota(c *gin.Context, relayInfo *relaycommon.RelayInfo, us
 func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelName string,
 	usage *dto.Usage, ratio float64, preConsumedQuota int, userQuota int, modelRatio float64, groupRatio float64,
 	modelPrice float64, usePrice bool, extraContent string) {
+	_, endSpan := common.StartRequestSpan(ctx, "quota.post_consume")
+	defer endSpan()
+	if ctx.GetBool("hedge_cancelled") {
+		// 对冲请求中落败的一方，已被取消，不计费
+		returnPreConsumedQuota(ctx, relayInfo, userQuota, preConsumedQuota)
+		return
+	}
 	if usage == nil {
 		usage = &dto.Usage{
 			PromptTokens:     relayInfo.PromptTokens,
@@ -370,7 +403,12 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelN
 			common.LogError(ctx, "error update user quota cache: "+err.Error())
 		}
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
-		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		if !ctx.GetBool("response_cache_hit") {
+			// 命中响应缓存时渠道没有处理请求，不计入渠道用量
+			model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+			// 命中响应缓存时没有 token 发往上游，不计入 TPM
+			common.RecordRateLimitTokens(ctx, totalTokens)
+		}
 	}
 
 	logModel := modelName
@@ -385,6 +423,9 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelN
 	if extraContent != "" {
 		logContent += ", " + extraContent
 	}
+	if service.IsClientCancelled(ctx) {
+		logContent += "，客户端已取消"
+	}
 	other := service.GenerateTextOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio, modelPrice)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, relayInfo.ChannelId, promptTokens, completionTokens, promptCacheHitTokens, logModel,
 		tokenName, quota, logContent, relayInfo.TokenId, userQuota, int(useTimeSeconds), relayInfo.IsStream, other)
@@ -0,0 +1,129 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseConverter 将 chat completions 格式的响应转换为入站请求对应的格式
+type responseConverter interface {
+	ConvertStreamChunk(w io.Writer, chunk *dto.ChatCompletionsStreamResponse) error
+	FinishStream(w io.Writer, usage *dto.Usage) error
+	ConvertResponse(textResponse *dto.OpenAITextResponse) ([]byte, error)
+}
+
+// convertResponseWriter 拦截渠道写出的 chat completions 响应，经 responseConverter 转换后再写给客户端
+// 流式响应按行转换；非流式响应先缓存，在 finish 时整体转换
+type convertResponseWriter struct {
+	gin.ResponseWriter
+	info      *relaycommon.RelayInfo
+	status    int
+	buffer    bytes.Buffer
+	converter responseConverter
+}
+
+func newConvertResponseWriter(writer gin.ResponseWriter, info *relaycommon.RelayInfo, converter responseConverter) *convertResponseWriter {
+	return &convertResponseWriter{
+		ResponseWriter: writer,
+		info:           info,
+		status:         http.StatusOK,
+		converter:      converter,
+	}
+}
+
+func (w *convertResponseWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *convertResponseWriter) WriteHeaderNow() {
+}
+
+func (w *convertResponseWriter) Status() int {
+	return w.status
+}
+
+func (w *convertResponseWriter) Write(data []byte) (int, error) {
+	w.buffer.Write(data)
+	if !w.info.IsStream {
+		return len(data), nil
+	}
+	for {
+		line, err := w.buffer.ReadBytes('\n')
+		if err != nil {
+			// 不完整的行留到下次处理
+			w.buffer.Write(line)
+			break
+		}
+		if err := w.writeStreamLine(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *convertResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *convertResponseWriter) writeStreamLine(line []byte) error {
+	data := strings.TrimSpace(string(line))
+	if !strings.HasPrefix(data, "data:") {
+		return nil
+	}
+	data = strings.TrimSpace(strings.TrimPrefix(data, "data:"))
+	if data == "" || data == "[DONE]" {
+		return nil
+	}
+	var streamResponse dto.ChatCompletionsStreamResponse
+	if err := json.Unmarshal([]byte(data), &streamResponse); err != nil {
+		return nil
+	}
+	if err := w.converter.ConvertStreamChunk(w.ResponseWriter, &streamResponse); err != nil {
+		return err
+	}
+	w.ResponseWriter.Flush()
+	return nil
+}
+
+// finish 写出剩余内容：流式请求补齐结束部分，非流式请求转换完整响应，usage 为最终计费的用量
+func (w *convertResponseWriter) finish(usage *dto.Usage) {
+	if w.info.IsStream {
+		if w.buffer.Len() > 0 {
+			_ = w.writeStreamLine(w.buffer.Bytes())
+			w.buffer.Reset()
+		}
+		if err := w.converter.FinishStream(w.ResponseWriter, usage); err != nil {
+			common.SysError("send converted stream response failed: " + err.Error())
+		}
+		w.ResponseWriter.Flush()
+		return
+	}
+
+	body := w.buffer.Bytes()
+	var textResponse dto.OpenAITextResponse
+	if err := json.Unmarshal(body, &textResponse); err == nil && len(textResponse.Choices) > 0 {
+		if usage != nil {
+			textResponse.Usage = *usage
+		}
+		if convertedBody, err := w.converter.ConvertResponse(&textResponse); err == nil {
+			body = convertedBody
+		}
+	}
+	w.ResponseWriter.Header().Del("Content-Length")
+	w.ResponseWriter.Header().Set("Content-Type", "application/json")
+	w.ResponseWriter.WriteHeader(w.status)
+	_, err := w.ResponseWriter.Write(body)
+	if err != nil {
+		common.SysError("write converted response failed: " + err.Error())
+	}
+}
@@ -0,0 +1,177 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+)
+
+// nativeRequestConverter 将客户端传入的原生请求体转换为渠道的请求
+type nativeRequestConverter func(c *gin.Context, info *relaycommon.RelayInfo, request map[string]any) (any, error)
+
+// nativeRelayRequest 描述一个非 chat completions 格式的入站请求（Claude、Gemini、Responses）
+type nativeRelayRequest struct {
+	modelName   string                    // 映射后的模型名
+	maxTokens   int                       // 请求的最大输出 token 数，为 0 时按默认值预扣费
+	textRequest *dto.GeneralOpenAIRequest // 转换后的 chat completions 请求，用于计费以及不支持原生格式的渠道
+	// nativeConverter 渠道原生支持该格式时返回请求转换函数，否则返回 nil
+	nativeConverter func(adaptor channel.Adaptor, info *relaycommon.RelayInfo) nativeRequestConverter
+	// newResponseConverter 创建将 chat completions 响应转换回入站格式的 responseConverter
+	newResponseConverter func(info *relaycommon.RelayInfo) responseConverter
+	// afterResponse 响应成功写出后、结算配额前调用，converter 为 nil 表示请求为原生透传
+	afterResponse func(converter responseConverter)
+}
+
+// relayNativeRequest 处理原生格式请求的公共流程：预扣费、请求转换、发送请求、响应转换和结算配额
+// 渠道原生支持时直接透传，其余渠道转换为 chat completions 后再将响应转换回来
+func relayNativeRequest(c *gin.Context, relayInfo *relaycommon.RelayInfo, request *nativeRelayRequest) (openaiErr *dto.OpenAIErrorWithStatusCode) {
+	textRequest := request.textRequest
+	modelPrice, getModelPriceSuccess := common.GetModelPrice(request.modelName, false)
+	groupRatio := common.GetGroupRatio(relayInfo.Group)
+
+	var preConsumedQuota int
+	var ratio float64
+	var modelRatio float64
+
+	if constant.ShouldCheckPromptSensitive() {
+		err := service.CheckSensitiveMessages(textRequest.Messages)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "sensitive_words_detected", http.StatusBadRequest)
+		}
+	}
+
+	promptTokens, err := service.CountTokenChatRequest(*textRequest, request.modelName)
+	// count messages token error 计算promptTokens错误
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "count_token_messages_failed", http.StatusInternalServerError)
+	}
+	relayInfo.PromptTokens = promptTokens
+
+	if !getModelPriceSuccess {
+		preConsumedTokens := common.PreConsumedQuota
+		if request.maxTokens != 0 {
+			preConsumedTokens = promptTokens + request.maxTokens
+		}
+		modelRatio = common.GetModelRatio(request.modelName)
+		ratio = modelRatio * groupRatio
+		preConsumedQuota = int(float64(preConsumedTokens) * ratio)
+	} else {
+		preConsumedQuota = int(modelPrice * common.QuotaPerUnit * groupRatio)
+	}
+
+	// pre-consume quota 预消耗配额
+	preConsumedQuota, userQuota, openaiErr := preConsumeQuota(c, preConsumedQuota, relayInfo)
+	if openaiErr != nil {
+		return openaiErr
+	}
+	defer func() {
+		if openaiErr != nil {
+			returnPreConsumedQuota(c, relayInfo, userQuota, preConsumedQuota)
+		}
+	}()
+
+	adaptor := GetAdaptor(relayInfo.ApiType)
+	if adaptor == nil {
+		return service.OpenAIErrorWrapperLocal(fmt.Errorf("invalid api type: %d", relayInfo.ApiType), "invalid_api_type", http.StatusBadRequest)
+	}
+
+	var requestBody io.Reader
+	var responseWriter *convertResponseWriter
+	var converter responseConverter
+	if convertNative := request.nativeConverter(adaptor, relayInfo); convertNative != nil {
+		// 原生透传，保留客户端传入的全部字段
+		adaptor.Init(relayInfo)
+		body, err := common.GetRequestBody(c)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "get_request_body_failed", http.StatusInternalServerError)
+		}
+		nativeRequest := make(map[string]any)
+		err = json.Unmarshal(body, &nativeRequest)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "unmarshal_request_failed", http.StatusBadRequest)
+		}
+		convertedRequest, err := convertNative(c, relayInfo, nativeRequest)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "convert_request_failed", http.StatusInternalServerError)
+		}
+		jsonData, err := sonic.Marshal(convertedRequest)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "json_marshal_failed", http.StatusInternalServerError)
+		}
+		requestBody = bytes.NewBuffer(jsonData)
+	} else {
+		// 其余渠道按 chat completions 处理
+		relayInfo.RelayMode = relayconstant.RelayModeChatCompletions
+		relayInfo.RequestURLPath = "/v1/chat/completions"
+		relayInfo.ShouldIncludeUsage = true
+		if relayInfo.SupportStreamOptions && textRequest.Stream {
+			textRequest.StreamOptions = &dto.StreamOptions{
+				IncludeUsage: true,
+			}
+		}
+		adaptor.Init(relayInfo)
+		convertedRequest, err := adaptor.ConvertRequest(c, relayInfo, textRequest)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "convert_request_failed", http.StatusInternalServerError)
+		}
+		jsonData, err := sonic.Marshal(convertedRequest)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "json_marshal_failed", http.StatusInternalServerError)
+		}
+		requestBody = bytes.NewBuffer(jsonData)
+
+		converter = request.newResponseConverter(relayInfo)
+		responseWriter = newConvertResponseWriter(c.Writer, relayInfo, converter)
+		c.Writer = responseWriter
+		defer func() {
+			c.Writer = responseWriter.ResponseWriter
+		}()
+	}
+
+	statusCodeMappingStr := c.GetString("status_code_mapping")
+	var httpResp *http.Response
+	resp, err := doRequest(c, adaptor, relayInfo, requestBody)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+
+	if resp != nil {
+		httpResp = resp.(*http.Response)
+		relayInfo.IsStream = relayInfo.IsStream || strings.HasPrefix(httpResp.Header.Get("Content-Type"), "text/event-stream")
+		if httpResp.StatusCode != http.StatusOK {
+			openaiErr = service.RelayErrorHandler(httpResp)
+			// reset status code 重置状态码
+			service.ResetStatusCode(openaiErr, statusCodeMappingStr)
+			return openaiErr
+		}
+	}
+
+	usage, openaiErr := doResponse(c, adaptor, httpResp, relayInfo)
+	if openaiErr != nil {
+		// reset status code 重置状态码
+		service.ResetStatusCode(openaiErr, statusCodeMappingStr)
+		return openaiErr
+	}
+	if responseWriter != nil {
+		responseWriter.finish(usage.(*dto.Usage))
+	}
+	if request.afterResponse != nil {
+		request.afterResponse(converter)
+	}
+
+	postConsumeQuota(c, relayInfo, request.modelName, usage.(*dto.Usage), ratio, preConsumedQuota, userQuota, modelRatio, groupRatio, modelPrice, getModelPriceSuccess, "")
+	return nil
+}
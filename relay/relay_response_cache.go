@@ -0,0 +1,100 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseCacheWriter 在写给客户端的同时保留一份响应内容，用于写入响应缓存；
+// 响应超过 ResponseCacheMaxBytes 后停止保留并标记为不可缓存
+type responseCacheWriter struct {
+	gin.ResponseWriter
+	buffer    bytes.Buffer
+	limit     int
+	oversized bool
+}
+
+func newResponseCacheWriter(writer gin.ResponseWriter) *responseCacheWriter {
+	return &responseCacheWriter{ResponseWriter: writer, limit: constant.ResponseCacheMaxBytes * 1024}
+}
+
+func (w *responseCacheWriter) capture(data []byte) {
+	if w.oversized {
+		return
+	}
+	if w.buffer.Len()+len(data) > w.limit {
+		w.oversized = true
+		w.buffer = bytes.Buffer{}
+		return
+	}
+	w.buffer.Write(data)
+}
+
+func (w *responseCacheWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseCacheWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// cacheable 响应是否完整保留，可以写入缓存
+func (w *responseCacheWriter) cacheable() bool {
+	return !w.oversized && w.Status() == http.StatusOK
+}
+
+// getResponseCacheKey 令牌或分组开启了响应缓存且请求结果确定时返回缓存 key，否则返回空字符串
+// embeddings 总是可缓存；chat/completions 仅在显式指定 temperature 为 0 且只生成一个结果时缓存
+func getResponseCacheKey(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) string {
+	if !c.GetBool("token_response_cache") && !constant.IsResponseCacheGroup(info.Group) {
+		return ""
+	}
+	switch info.RelayMode {
+	case relayconstant.RelayModeEmbeddings:
+	case relayconstant.RelayModeChatCompletions, relayconstant.RelayModeCompletions:
+		if request.N > 1 {
+			return ""
+		}
+		requestBody, err := common.GetRequestBody(c)
+		if err != nil {
+			return ""
+		}
+		var sampling struct {
+			Temperature *float64 `json:"temperature"`
+		}
+		if err := json.Unmarshal(requestBody, &sampling); err != nil || sampling.Temperature == nil || *sampling.Temperature != 0 {
+			return ""
+		}
+	default:
+		return ""
+	}
+	key, err := service.ResponseCacheKey(info.UserId, info.RelayMode, request)
+	if err != nil {
+		common.LogError(c, "failed to build response cache key: "+err.Error())
+		return ""
+	}
+	return key
+}
+
+// replayResponseCache 回放缓存的响应，并按 ResponseCacheQuotaRatio 折扣计费
+func replayResponseCache(c *gin.Context, info *relaycommon.RelayInfo, modelName string, entry *service.ResponseCacheEntry,
+	preConsumedQuota int, userQuota int, ratio float64, modelRatio float64, groupRatio float64, modelPrice float64, usePrice bool) {
+	c.Set("response_cache_hit", true)
+	c.Header("X-Cache", "HIT")
+	c.Data(http.StatusOK, entry.ContentType, entry.Body)
+
+	cacheRatio := constant.ResponseCacheQuotaRatio
+	usage := entry.Usage
+	postConsumeQuota(c, info, modelName, &usage, ratio*cacheRatio, preConsumedQuota, userQuota, modelRatio, groupRatio*cacheRatio, modelPrice, usePrice, "响应缓存命中")
+}
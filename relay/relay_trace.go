@@ -0,0 +1,48 @@
+package relay
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// doRequest 在 span 中调用适配器的 DoRequest，上游请求会携带该 span 的 trace context
+func doRequest(c *gin.Context, adaptor channel.Adaptor, info *relaycommon.RelayInfo, requestBody io.Reader) (any, error) {
+	span, end := common.StartRequestSpan(c, "adaptor.DoRequest",
+		attribute.String("adaptor", adaptor.GetChannelName()),
+		attribute.String("upstream_model", info.UpstreamModelName),
+		attribute.Bool("stream", info.IsStream),
+	)
+	defer end()
+	resp, err := adaptor.DoRequest(c, info, requestBody)
+	common.SetSpanError(span, err)
+	if httpResp, ok := resp.(*http.Response); ok && httpResp != nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", httpResp.StatusCode))
+	}
+	return resp, err
+}
+
+// doResponse 在 span 中调用适配器的 DoResponse，流式请求时包含读取整个流的耗时
+func doResponse(c *gin.Context, adaptor channel.Adaptor, resp *http.Response, info *relaycommon.RelayInfo) (any, *dto.OpenAIErrorWithStatusCode) {
+	span, end := common.StartRequestSpan(c, "adaptor.DoResponse", attribute.String("adaptor", adaptor.GetChannelName()))
+	defer end()
+	usage, openaiErr := adaptor.DoResponse(c, resp, info)
+	if openaiErr != nil {
+		span.SetAttributes(attribute.Int("error.status_code", openaiErr.StatusCode))
+		common.SetSpanError(span, errors.New(openaiErr.Error.Message))
+	}
+	if u, ok := usage.(*dto.Usage); ok && u != nil {
+		span.SetAttributes(
+			attribute.Int("usage.prompt_tokens", u.PromptTokens),
+			attribute.Int("usage.completion_tokens", u.CompletionTokens),
+		)
+	}
+	return usage, openaiErr
+}
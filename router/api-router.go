@@ -49,6 +49,8 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.GET("/self", controller.GetSelf)
 				selfRoute.GET("/models", controller.GetUserModels)
 				selfRoute.PUT("/self", controller.UpdateSelf)
+				selfRoute.GET("/self/webhook", controller.GetSelfWebhook)
+				selfRoute.PUT("/self/webhook", controller.UpdateSelfWebhook)
 				selfRoute.DELETE("/self", controller.DeleteSelf)
 				selfRoute.GET("/token", controller.GenerateAccessToken)
 				selfRoute.GET("/aff", controller.GetAffCode)
@@ -83,9 +85,16 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.GET("/", controller.GetAllChannels)
 			channelRoute.GET("/search", controller.SearchChannels)
 			channelRoute.GET("/models", controller.ChannelListModels)
+			channelRoute.GET("/weights", controller.GetChannelWeights)
+			channelRoute.DELETE("/weights/:id", controller.ResetChannelWeight)
+			channelRoute.GET("/circuit_breakers", controller.GetChannelCircuitBreakers)
+			channelRoute.GET("/disable_rules", controller.GetChannelDisableRules)
+			channelRoute.POST("/disable_rules/dry_run", controller.DryRunChannelDisableRules)
+			channelRoute.DELETE("/circuit_breakers/:id", controller.ResetChannelCircuitBreakers)
 			channelRoute.GET("/:id", controller.GetChannel)
 			channelRoute.GET("/test", controller.TestAllChannels)
 			channelRoute.GET("/test/:id", controller.TestChannel)
+			channelRoute.GET("/test_history/:id", controller.GetChannelTestHistory)
 			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
 			channelRoute.GET("/update_balance/:id", controller.UpdateChannelBalance)
 			channelRoute.POST("/", controller.AddChannel)
@@ -98,6 +107,10 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.POST("/batch", controller.DeleteChannelBatch)
 			channelRoute.POST("/fix", controller.FixChannelsAbilities)
 			channelRoute.GET("/fetch_models/:id", controller.FetchUpstreamModels)
+			channelRoute.GET("/:id/keys", controller.GetChannelKeys)
+			channelRoute.POST("/:id/keys", controller.AddChannelKeys)
+			channelRoute.DELETE("/:id/keys/:key_id", controller.DeleteChannelKey)
+			channelRoute.PUT("/:id/keys/:key_id/status", controller.UpdateChannelKeyStatus)
 
 		}
 		tokenRoute := apiRouter.Group("/token")
@@ -109,6 +122,7 @@ func SetApiRouter(router *gin.Engine) {
 			tokenRoute.POST("/", controller.AddToken)
 			tokenRoute.PUT("/", controller.UpdateToken)
 			tokenRoute.DELETE("/:id", controller.DeleteToken)
+			tokenRoute.PUT("/audit_log", middleware.AdminAuth(), controller.UpdateTokenAuditLog)
 		}
 		redemptionRoute := apiRouter.Group("/redemption")
 		redemptionRoute.Use(middleware.AdminAuth())
@@ -129,6 +143,7 @@ func SetApiRouter(router *gin.Engine) {
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), controller.SearchUserLogs)
 		logRoute.GET("/token/cache/hit", middleware.UserAuth(), controller.GetTokenCacheHitStats)
+		logRoute.GET("/audit/:request_id", middleware.RootAuth(), controller.GetAuditLog)
 
 		dataRoute := apiRouter.Group("/data")
 		dataRoute.GET("/", middleware.AdminAuth(), controller.GetAllQuotaDates)
@@ -2,87 +2,66 @@ package service
 
 import (
 	"fmt"
-	"net/http"
 	"one-api/common"
 	relaymodel "one-api/dto"
 	"one-api/model"
-	"strings"
 )
 
-// disable & notify
-func DisableChannel(channelId int, channelName string, reason string) {
-	model.UpdateChannelStatusById(channelId, common.ChannelStatusAutoDisabled, reason)
+// disable & notify, modelName 为触发禁用的模型，未知时为空
+func DisableChannel(channelId int, channelName string, reason string, modelName string) {
+	model.UpdateChannelStatusById(channelId, common.ChannelStatusAutoDisabled, reason, modelName)
 	subject := fmt.Sprintf("通道「%s」（#%d）已被禁用", channelName, channelId)
 	content := fmt.Sprintf("通道「%s」（#%d）已被禁用，原因：%s", channelName, channelId, reason)
 	notifyRootUser(subject, content)
+	common.PublishEvent(common.EventChannelDisabled, 0, map[string]any{
+		"channel_id":   channelId,
+		"channel_name": channelName,
+		"reason":       reason,
+		"model":        modelName,
+	})
 }
 
 func EnableChannel(channelId int, channelName string) {
-	model.UpdateChannelStatusById(channelId, common.ChannelStatusEnabled, "")
+	model.UpdateChannelStatusById(channelId, common.ChannelStatusEnabled, "", "")
 	subject := fmt.Sprintf("通道「%s」（#%d）已被启用", channelName, channelId)
 	content := fmt.Sprintf("通道「%s」（#%d）已被启用", channelName, channelId)
 	notifyRootUser(subject, content)
+	common.PublishEvent(common.EventChannelEnabled, 0, map[string]any{
+		"channel_id":   channelId,
+		"channel_name": channelName,
+	})
 }
 
-func ShouldDisableChannel(channelType int, err *relaymodel.OpenAIErrorWithStatusCode) bool {
-	if !common.AutomaticDisableChannelEnabled {
-		return false
+// NewChannelErrorInfo 提取用于匹配渠道错误规则的信息
+func NewChannelErrorInfo(channelType int, err *relaymodel.OpenAIErrorWithStatusCode) *common.ChannelErrorInfo {
+	info := &common.ChannelErrorInfo{
+		ChannelType: channelType,
+		StatusCode:  err.StatusCode,
+		ErrorType:   err.Error.Type,
+		Message:     err.Error.Message,
 	}
+	if err.Error.Code != nil {
+		info.ErrorCode = fmt.Sprintf("%v", err.Error.Code)
+	}
+	return info
+}
+
+// ChannelErrorAction 按管理员配置的规则判断上游错误的处理动作，本地错误不匹配规则，只降低权重
+func ChannelErrorAction(channelType int, err *relaymodel.OpenAIErrorWithStatusCode) string {
 	if err == nil {
-		return false
+		return common.ChannelRuleActionIgnore
 	}
 	if err.LocalError {
-		return false
-	}
-	if err.StatusCode == http.StatusUnauthorized {
-		return true
-	}
-	if err.StatusCode == http.StatusForbidden {
-		switch channelType {
-		case common.ChannelTypeGemini:
-			return true
-		}
-	}
-	switch err.Error.Code {
-	case "invalid_api_key":
-		return true
-	case "account_deactivated":
-		return true
-	case "billing_not_active":
-		return true
-	}
-	switch err.Error.Type {
-	case "insufficient_quota":
-		return true
-	case "insufficient_user_quota":
-		return true
-	// https://docs.anthropic.com/claude/reference/errors
-	case "authentication_error":
-		return true
-	case "permission_error":
-		return true
-	case "forbidden":
-		return true
-	}
-	if strings.HasPrefix(err.Error.Message, "Your credit balance is too low") { // anthropic
-		return true
-	} else if strings.HasPrefix(err.Error.Message, "This organization has been disabled.") {
-		return true
-	} else if strings.HasPrefix(err.Error.Message, "You exceeded your current quota") {
-		return true
-	} else if strings.HasPrefix(err.Error.Message, "Permission denied") {
-		return true
+		return common.ChannelRuleActionPenalize
 	}
+	return common.ChannelErrorAction(common.GetChannelDisableRules(), NewChannelErrorInfo(channelType, err))
+}
 
-	if strings.Contains(err.Error.Message, "The security token included in the request is invalid") { // anthropic
-		return true
-	} else if strings.Contains(err.Error.Message, "Operation not allowed") {
-		return true
-	} else if strings.Contains(err.Error.Message, "Your account is not authorized") {
-		return true
+func ShouldDisableChannel(channelType int, err *relaymodel.OpenAIErrorWithStatusCode) bool {
+	if !common.AutomaticDisableChannelEnabled {
+		return false
 	}
-
-	return false
+	return ChannelErrorAction(channelType, err) == common.ChannelRuleActionDisable
 }
 
 func ShouldEnableChannel(err error, openaiWithStatusErr *relaymodel.OpenAIErrorWithStatusCode, status int) bool {
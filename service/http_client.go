@@ -1,9 +1,19 @@
 package service
 
 import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"one-api/common"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 var httpClient *http.Client
@@ -30,3 +40,181 @@ func GetHttpClient() *http.Client {
 func GetImpatientHttpClient() *http.Client {
 	return impatientHTTPClient
 }
+
+// ChannelTransportSetting 渠道的出站连接设置，超时单位为秒，0 表示使用默认值
+type ChannelTransportSetting struct {
+	Proxy                 string `json:"proxy,omitempty"`                   // 代理地址，支持 http://、https://、socks5://、socks5h://，可带用户名密码
+	Timeout               int    `json:"timeout,omitempty"`                 // 整个请求（包括读取流式响应）的超时，默认为 RELAY_TIMEOUT
+	ConnectTimeout        int    `json:"connect_timeout,omitempty"`         // 建立连接的超时，同时用作 WebSocket 握手超时
+	ResponseHeaderTimeout int    `json:"response_header_timeout,omitempty"` // 发送请求后等待响应头的超时
+	IdleConnTimeout       int    `json:"idle_conn_timeout,omitempty"`       // 空闲连接保留时间
+	MaxIdleConns          int    `json:"max_idle_conns,omitempty"`          // 最大空闲连接数，同时作为每个 host 的上限
+	TLSInsecureSkipVerify bool   `json:"tls_insecure_skip_verify,omitempty"`
+	TLSServerName         string `json:"tls_server_name,omitempty"`
+	TLSMinVersion         string `json:"tls_min_version,omitempty"` // 1.0、1.1、1.2 或 1.3
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseChannelTransportSetting 解析并校验渠道的出站连接设置，空字符串返回 nil
+func ParseChannelTransportSetting(jsonStr string) (*ChannelTransportSetting, error) {
+	if strings.TrimSpace(jsonStr) == "" {
+		return nil, nil
+	}
+	setting := &ChannelTransportSetting{}
+	if err := json.Unmarshal([]byte(jsonStr), setting); err != nil {
+		return nil, fmt.Errorf("invalid transport setting: %s", err.Error())
+	}
+	if setting.Proxy != "" {
+		proxyUrl, err := url.Parse(setting.Proxy)
+		if err != nil || proxyUrl.Host == "" {
+			return nil, errors.New("invalid proxy url")
+		}
+		switch proxyUrl.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q", proxyUrl.Scheme)
+		}
+	}
+	if setting.Timeout < 0 || setting.ConnectTimeout < 0 || setting.ResponseHeaderTimeout < 0 ||
+		setting.IdleConnTimeout < 0 || setting.MaxIdleConns < 0 {
+		return nil, errors.New("timeouts and max idle conns must not be negative")
+	}
+	if _, ok := tlsVersions[setting.TLSMinVersion]; setting.TLSMinVersion != "" && !ok {
+		return nil, fmt.Errorf("invalid tls min version %q", setting.TLSMinVersion)
+	}
+	return setting, nil
+}
+
+// channelTransport 按渠道缓存的 http.Client 和 WebSocket Dialer，设置变更后重建
+// 设置无效时也会缓存（client 为 nil），避免每次请求都重新解析并打印日志
+type channelTransport struct {
+	setting   string
+	transport *http.Transport
+	client    *http.Client
+	dialer    *websocket.Dialer
+}
+
+var channelTransports = make(map[int]*channelTransport)
+var channelTransportsLock sync.Mutex
+
+func newChannelTransport(jsonStr string, setting *ChannelTransportSetting) *channelTransport {
+	netDialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if setting.ConnectTimeout > 0 {
+		netDialer.Timeout = time.Duration(setting.ConnectTimeout) * time.Second
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = netDialer.DialContext
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		NetDialContext:   netDialer.DialContext,
+		HandshakeTimeout: 45 * time.Second,
+	}
+	if setting.Proxy != "" {
+		proxyUrl, _ := url.Parse(setting.Proxy)
+		transport.Proxy = http.ProxyURL(proxyUrl)
+		// WebSocket 的 socks5 代理同样由代理服务器解析域名，只识别 socks5
+		wsProxyUrl := *proxyUrl
+		switch wsProxyUrl.Scheme {
+		case "socks5h":
+			wsProxyUrl.Scheme = "socks5"
+		case "https":
+			// WebSocket Dialer 不支持 https 代理，改为先和代理建立 TLS 连接，再按 http 代理发送 CONNECT
+			if wsProxyUrl.Port() == "" {
+				wsProxyUrl.Host = net.JoinHostPort(wsProxyUrl.Hostname(), "443")
+			}
+			wsProxyUrl.Scheme = "http"
+			proxyDialer := &tls.Dialer{
+				NetDialer: netDialer,
+				Config:    &tls.Config{ServerName: proxyUrl.Hostname()},
+			}
+			dialer.NetDialContext = proxyDialer.DialContext
+		}
+		dialer.Proxy = http.ProxyURL(&wsProxyUrl)
+	}
+	if setting.ConnectTimeout > 0 {
+		transport.TLSHandshakeTimeout = netDialer.Timeout
+		dialer.HandshakeTimeout = netDialer.Timeout
+	}
+	if setting.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = time.Duration(setting.ResponseHeaderTimeout) * time.Second
+	}
+	if setting.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = time.Duration(setting.IdleConnTimeout) * time.Second
+	}
+	if setting.MaxIdleConns > 0 {
+		transport.MaxIdleConns = setting.MaxIdleConns
+		transport.MaxIdleConnsPerHost = setting.MaxIdleConns
+	}
+	if setting.TLSInsecureSkipVerify || setting.TLSServerName != "" || setting.TLSMinVersion != "" {
+		tlsConfig := &tls.Config{
+			InsecureSkipVerify: setting.TLSInsecureSkipVerify,
+			ServerName:         setting.TLSServerName,
+			MinVersion:         tlsVersions[setting.TLSMinVersion],
+		}
+		transport.TLSClientConfig = tlsConfig
+		dialer.TLSClientConfig = tlsConfig.Clone()
+	}
+	client := &http.Client{Transport: transport}
+	if setting.Timeout > 0 {
+		client.Timeout = time.Duration(setting.Timeout) * time.Second
+	} else if common.RelayTimeout != 0 {
+		client.Timeout = time.Duration(common.RelayTimeout) * time.Second
+	}
+	return &channelTransport{setting: jsonStr, transport: transport, client: client, dialer: dialer}
+}
+
+// getChannelTransport 获取渠道缓存的连接，渠道没有设置或设置无效时返回 nil
+func getChannelTransport(channelId int, jsonStr string) *channelTransport {
+	if channelId == 0 || strings.TrimSpace(jsonStr) == "" {
+		return nil
+	}
+	channelTransportsLock.Lock()
+	defer channelTransportsLock.Unlock()
+	cached, ok := channelTransports[channelId]
+	if ok && cached.setting == jsonStr {
+		if cached.client == nil {
+			return nil
+		}
+		return cached
+	}
+	if ok {
+		if cached.transport != nil {
+			cached.transport.CloseIdleConnections()
+		}
+		delete(channelTransports, channelId)
+	}
+	setting, err := ParseChannelTransportSetting(jsonStr)
+	if err != nil {
+		common.SysError(fmt.Sprintf("channel #%d: %s, using default http client", channelId, err.Error()))
+		channelTransports[channelId] = &channelTransport{setting: jsonStr}
+		return nil
+	}
+	cached = newChannelTransport(jsonStr, setting)
+	channelTransports[channelId] = cached
+	return cached
+}
+
+// GetChannelHttpClient 获取渠道的 http.Client，渠道没有出站连接设置时返回全局的 client
+func GetChannelHttpClient(channelId int, transportSetting string) *http.Client {
+	if cached := getChannelTransport(channelId, transportSetting); cached != nil {
+		return cached.client
+	}
+	return httpClient
+}
+
+// GetChannelWssDialer 获取渠道的 WebSocket Dialer，渠道没有出站连接设置时返回 websocket.DefaultDialer
+func GetChannelWssDialer(channelId int, transportSetting string) *websocket.Dialer {
+	if cached := getChannelTransport(channelId, transportSetting); cached != nil {
+		return cached.dialer
+	}
+	return websocket.DefaultDialer
+}
@@ -5,9 +5,11 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"github.com/gin-gonic/gin"
 	"golang.org/x/image/webp"
 	"image"
 	"io"
+	"net/http"
 	"one-api/common"
 	"strings"
 )
@@ -105,3 +107,30 @@ func getImageConfig(reader io.Reader) (image.Config, string, error) {
 	}
 	return config, format, nil
 }
+
+// GetFormImagesBase64 读取 multipart 表单中的图片文件（兼容 field 与 field[] 两种写法），返回 data url 格式的 base64 数据
+func GetFormImagesBase64(c *gin.Context, field string) ([]string, error) {
+	if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
+		return nil, err
+	}
+	var images []string
+	for _, key := range []string{field, field + "[]"} {
+		for _, fileHeader := range c.Request.MultipartForm.File[key] {
+			file, err := fileHeader.Open()
+			if err != nil {
+				return nil, err
+			}
+			data, err := io.ReadAll(file)
+			file.Close()
+			if err != nil {
+				return nil, err
+			}
+			mimeType := fileHeader.Header.Get("Content-Type")
+			if !strings.HasPrefix(mimeType, "image/") {
+				mimeType = http.DetectContentType(data)
+			}
+			images = append(images, fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data)))
+		}
+	}
+	return images, nil
+}
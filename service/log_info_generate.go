@@ -2,6 +2,7 @@ package service
 
 import (
 	"github.com/gin-gonic/gin"
+	"one-api/common"
 	"one-api/dto"
 	relaycommon "one-api/relay/common"
 )
@@ -14,6 +15,15 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 	other["model_price"] = modelPrice
 	other["group"] = relayInfo.Group
 	other["frt"] = float64(relayInfo.FirstResponseTime.UnixMilli() - relayInfo.StartTime.UnixMilli())
+	if ctx.GetBool("response_cache_hit") {
+		other["response_cache_hit"] = true
+	}
+	if IsClientCancelled(ctx) {
+		other["client_cancelled"] = true
+	}
+	if _, ok := ctx.Get("specific_channel_id"); !ok {
+		other["channel_strategy"] = common.GetChannelSelectStrategy(relayInfo.Group)
+	}
 	adminInfo := make(map[string]interface{})
 	adminInfo["use_channel"] = ctx.GetStringSlice("use_channel")
 	other["admin_info"] = adminInfo
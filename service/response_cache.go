@@ -0,0 +1,124 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ResponseCacheEntry 缓存的完整响应，流式请求保存原始的 SSE 数据，命中时原样回放
+type ResponseCacheEntry struct {
+	ContentType string    `json:"content_type"`
+	Body        []byte    `json:"body"`
+	Usage       dto.Usage `json:"usage"`
+	ExpiresAt   int64     `json:"expires_at"`
+}
+
+const responseCacheKeyPrefix = "response_cache:"
+
+// ResponseCacheKey 以用户、请求类型和规范化后的请求体计算缓存 key，不同用户之间不共享缓存
+func ResponseCacheKey(userId int, relayMode int, request *dto.GeneralOpenAIRequest) (string, error) {
+	normalized, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%d:%d:%s", userId, relayMode, normalized)))
+	return responseCacheKeyPrefix + hex.EncodeToString(hash[:]), nil
+}
+
+func GetResponseCache(key string) (*ResponseCacheEntry, bool) {
+	if common.RedisEnabled && common.RDB != nil {
+		data, err := common.RDB.Get(context.Background(), key).Bytes()
+		if err != nil {
+			if err != redis.Nil {
+				common.SysError("failed to get response cache: " + err.Error())
+			}
+			return nil, false
+		}
+		var entry ResponseCacheEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, false
+		}
+		return &entry, true
+	}
+	return responseCacheLRU.get(key)
+}
+
+func SetResponseCache(key string, entry *ResponseCacheEntry) {
+	if len(entry.Body) > constant.ResponseCacheMaxBytes*1024 {
+		return
+	}
+	ttl := time.Duration(constant.ResponseCacheTTL) * time.Second
+	entry.ExpiresAt = time.Now().Add(ttl).Unix()
+	if common.RedisEnabled && common.RDB != nil {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return
+		}
+		if err := common.RDB.Set(context.Background(), key, data, ttl).Err(); err != nil {
+			common.SysError("failed to set response cache: " + err.Error())
+		}
+		return
+	}
+	responseCacheLRU.set(key, entry)
+}
+
+// responseLRU 未启用 Redis 时使用的内存 LRU 缓存
+type responseLRU struct {
+	mutex sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type responseLRUItem struct {
+	key   string
+	entry *ResponseCacheEntry
+}
+
+var responseCacheLRU = &responseLRU{
+	ll:    list.New(),
+	items: make(map[string]*list.Element),
+}
+
+func (l *responseLRU) get(key string) (*ResponseCacheEntry, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	element, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := element.Value.(*responseLRUItem)
+	if item.entry.ExpiresAt < time.Now().Unix() {
+		l.ll.Remove(element)
+		delete(l.items, key)
+		return nil, false
+	}
+	l.ll.MoveToFront(element)
+	return item.entry, true
+}
+
+func (l *responseLRU) set(key string, entry *ResponseCacheEntry) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if element, ok := l.items[key]; ok {
+		element.Value.(*responseLRUItem).entry = entry
+		l.ll.MoveToFront(element)
+		return
+	}
+	l.items[key] = l.ll.PushFront(&responseLRUItem{key: key, entry: entry})
+	for l.ll.Len() > constant.ResponseCacheSize {
+		oldest := l.ll.Back()
+		l.ll.Remove(oldest)
+		delete(l.items, oldest.Value.(*responseLRUItem).key)
+	}
+}
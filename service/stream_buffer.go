@@ -0,0 +1,124 @@
+package service
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamBufferWriter 在流式响应产生第一个有效内容前缓存写给客户端的数据和状态码，
+// 这样上游在输出内容前出错或超时时客户端还没有收到任何响应，上层可以换一个渠道重试
+type streamBufferWriter struct {
+	gin.ResponseWriter
+	buffer    bytes.Buffer
+	status    int
+	committed bool
+}
+
+func (w *streamBufferWriter) WriteHeader(code int) {
+	if w.committed {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *streamBufferWriter) WriteHeaderNow() {
+	if w.committed {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *streamBufferWriter) Status() int {
+	if w.committed {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *streamBufferWriter) Size() int {
+	if w.committed {
+		return w.ResponseWriter.Size()
+	}
+	return -1
+}
+
+func (w *streamBufferWriter) Written() bool {
+	return w.committed && w.ResponseWriter.Written()
+}
+
+func (w *streamBufferWriter) Flush() {
+	if w.committed {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *streamBufferWriter) Write(data []byte) (int, error) {
+	if w.committed {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.buffer.Write(data)
+}
+
+func (w *streamBufferWriter) WriteString(s string) (int, error) {
+	if w.committed {
+		return w.ResponseWriter.WriteString(s)
+	}
+	return w.buffer.WriteString(s)
+}
+
+func (w *streamBufferWriter) commit() error {
+	if w.committed {
+		return nil
+	}
+	w.committed = true
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buffer.Len() > 0 {
+		if _, err := w.ResponseWriter.Write(w.buffer.Bytes()); err != nil {
+			return err
+		}
+		w.buffer.Reset()
+	}
+	w.ResponseWriter.Flush()
+	return nil
+}
+
+// BufferStreamUntilContent 开始缓存流式响应，直到调用 CommitStream 才把响应头和已缓存的数据写给客户端
+func BufferStreamUntilContent(c *gin.Context) {
+	if _, ok := c.Writer.(*streamBufferWriter); ok {
+		return
+	}
+	c.Writer = &streamBufferWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+}
+
+// CommitStream 上游已返回有效内容，写出缓存的响应，之后的数据直接写给客户端
+func CommitStream(c *gin.Context) error {
+	if w, ok := c.Writer.(*streamBufferWriter); ok {
+		return w.commit()
+	}
+	return nil
+}
+
+// StreamCommitted 流式响应是否已经开始写给客户端，未使用缓存时总是返回 true
+func StreamCommitted(c *gin.Context) bool {
+	if w, ok := c.Writer.(*streamBufferWriter); ok {
+		return w.committed
+	}
+	return true
+}
+
+// DiscardStream 丢弃尚未写出的流式响应并恢复原始的 Writer，使上层可以返回错误或重试其他渠道
+func DiscardStream(c *gin.Context) {
+	w, ok := c.Writer.(*streamBufferWriter)
+	if !ok || w.committed {
+		return
+	}
+	c.Writer = w.ResponseWriter
+	header := c.Writer.Header()
+	for _, key := range []string{"Content-Type", "Cache-Control", "Connection", "Transfer-Encoding", "X-Accel-Buffering"} {
+		header.Del(key)
+	}
+}
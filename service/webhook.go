@@ -0,0 +1,181 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// webhook 投递：请求体为 JSON 格式的事件，配置了密钥时带上签名头
+// X-Webhook-Signature: sha256=hex(HMAC-SHA256(secret, timestamp + "." + body))，
+// 接收方应校验签名并拒绝时间戳过旧的请求。非 2xx 响应会按指数退避重试 WEBHOOK_MAX_RETRIES 次
+var webhookMaxRetries = common.GetEnvOrDefault("WEBHOOK_MAX_RETRIES", 3)
+var webhookTimeout = common.GetEnvOrDefault("WEBHOOK_TIMEOUT", 10) // unit is second
+
+var webhookClient = &http.Client{
+	Timeout: time.Duration(webhookTimeout) * time.Second,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// userWebhookClient 用于投递用户配置的 webhook，连接时校验解析后的 IP，
+// 拒绝回环、内网和链路本地等地址，防止用户借 webhook 访问服务器所在的内网
+var userWebhookClient = &http.Client{
+	Timeout: time.Duration(webhookTimeout) * time.Second,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: time.Duration(webhookTimeout) * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+					return fmt.Errorf("webhook address %s is not allowed", host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// InitWebhook 订阅运维事件并投递到全局和用户配置的 webhook
+func InitWebhook() {
+	common.SubscribeEvent(deliverWebhookEvent)
+}
+
+func deliverWebhookEvent(event *common.Event) {
+	if constant.WebhookEnabled && constant.WebhookUrl != "" && constant.WebhookEventSubscribed(constant.WebhookEvents, event.Type) {
+		sendWebhookWithRetry(webhookClient, constant.WebhookUrl, constant.WebhookSecret, event)
+	}
+	if event.UserId == 0 || !common.IsUserEventType(event.Type) {
+		return
+	}
+	webhookUrl, secret, events, err := model.GetUserWebhook(event.UserId)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to get webhook of user %d: %s", event.UserId, err.Error()))
+		return
+	}
+	if webhookUrl != "" && constant.WebhookEventSubscribed(events, event.Type) {
+		sendWebhookWithRetry(userWebhookClient, webhookUrl, secret, event)
+	}
+}
+
+func sendWebhookWithRetry(client *http.Client, webhookUrl string, secret string, event *common.Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		common.SysError("failed to marshal webhook event: " + err.Error())
+		return
+	}
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		err = SendWebhook(client, webhookUrl, secret, event, body)
+		if err == nil {
+			return
+		}
+		if attempt >= webhookMaxRetries {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 4
+	}
+	common.SysError(fmt.Sprintf("failed to deliver webhook event %s (%s) to %s: %s", event.Id, event.Type, webhookUrl, err.Error()))
+}
+
+// WebhookSignature 计算 webhook 签名
+func WebhookSignature(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SendWebhook 投递一次事件，返回非 2xx 状态码时视为失败
+func SendWebhook(client *http.Client, webhookUrl string, secret string, event *common.Event, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, webhookUrl, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "one-api-webhook/"+common.Version)
+	req.Header.Set("X-Webhook-Id", event.Id)
+	req.Header.Set("X-Webhook-Event", event.Type)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	if secret != "" {
+		req.Header.Set("X-Webhook-Signature", WebhookSignature(secret, timestamp, body))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// CheckWebhookUrl 校验 webhook 地址，空地址表示不推送
+func CheckWebhookUrl(webhookUrl string) error {
+	if webhookUrl == "" {
+		return nil
+	}
+	u, err := url.Parse(webhookUrl)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.New("Webhook 地址必须是 http 或 https 链接")
+	}
+	return nil
+}
+
+// CheckUserWebhookUrl 校验用户配置的 webhook 地址，除 CheckWebhookUrl 的校验外不允许 localhost 和非公网 IP，
+// 域名解析到的地址在投递时校验
+func CheckUserWebhookUrl(webhookUrl string) error {
+	if err := CheckWebhookUrl(webhookUrl); err != nil || webhookUrl == "" {
+		return err
+	}
+	u, _ := url.Parse(webhookUrl)
+	host := u.Hostname()
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return errors.New("Webhook 地址不能是内网地址")
+	}
+	if ip := net.ParseIP(host); ip != nil && !isPublicIP(ip) {
+		return errors.New("Webhook 地址不能是内网地址")
+	}
+	return nil
+}
+
+// CheckWebhookEvents 校验逗号分隔的事件类型，userOnly 为 true 时只允许用户可订阅的事件
+func CheckWebhookEvents(events string, userOnly bool) error {
+	for _, event := range constant.ParseWebhookEvents(events) {
+		if userOnly && !common.IsUserEventType(event) || !common.IsEventType(event) {
+			return fmt.Errorf("不支持的事件类型：%s", event)
+		}
+	}
+	return nil
+}
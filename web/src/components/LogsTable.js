@@ -76,6 +76,8 @@ const LogsTable = () => {
         return <Tag color='orange' size='large'>{t('管理')}</Tag>;
       case 4:
         return <Tag color='purple' size='large'>{t('系统')}</Tag>;
+      case 5:
+        return <Tag color='red' size='large'>{t('错误')}</Tag>;
       default:
         return <Tag color='black' size='large'>{t('未知')}</Tag>;
     }
@@ -748,6 +750,7 @@ const LogsTable = () => {
             <Select.Option value='2'>{t('消费')}</Select.Option>
             <Select.Option value='3'>{t('管理')}</Select.Option>
             <Select.Option value='4'>{t('系统')}</Select.Option>
+            <Select.Option value='5'>{t('错误')}</Select.Option>
           </Select>
         </div>
         <Table